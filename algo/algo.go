@@ -0,0 +1,218 @@
+package algo
+
+import (
+	"fcoinExchange/model"
+	"fmt"
+	"time"
+)
+
+// 执行算法
+const (
+	TWAP    string = "twap"
+	VWAP    string = "vwap"
+	Iceberg string = "iceberg"
+)
+
+var (
+	// 默认的子订单数量
+	defaultSlices = 10
+	// 冰山订单没有限价时，子订单不在最优价超过此时间后撤单重新下单
+	defaultIcebergTimeout = 30 * time.Second
+)
+
+// 母订单。
+// TWAP在Horizon内按时间平均分成Slices个子订单，VWAP按Profile中的成交量分布分配每个时间段的数量，
+// 冰山订单每次只挂出Display数量。LimitPrice为0时不限价
+type Parent struct {
+	Account    string        `json:"account"`
+	Symbol     string        `json:"symbol"`
+	Side       string        `json:"side"`
+	Amount     float64       `json:"amount"`
+	LimitPrice float64       `json:"limit_price"`
+	Horizon    time.Duration `json:"horizon"`
+	Algo       string        `json:"algo"`
+	Slices     int           `json:"slices,omitempty"`
+	Profile    []float64     `json:"profile,omitempty"`
+	Display    float64       `json:"display,omitempty"`
+	// 子订单超过此时间未完全成交时撤单，剩余数量重新下单，为0时使用默认值
+	ChildTimeout time.Duration `json:"child_timeout,omitempty"`
+	// 下单时使用的策略名称，用于日志和统计
+	Strategy string `json:"strategy"`
+}
+
+//
+func (p *Parent) Validate() error {
+	if p.Side != "buy" && p.Side != "sell" {
+		return fmt.Errorf("side need be buy or sell")
+	}
+	if p.Amount <= 0 {
+		return fmt.Errorf("amount need be greater than 0")
+	}
+	if p.LimitPrice < 0 || p.Horizon < 0 || p.Slices < 0 || p.ChildTimeout < 0 {
+		return fmt.Errorf("limit_price, horizon, slices and child_timeout can not be negative")
+	}
+
+	switch p.Algo {
+	case TWAP:
+		if p.Horizon <= 0 {
+			return fmt.Errorf("twap need horizon greater than 0")
+		}
+	case VWAP:
+		if p.Horizon <= 0 {
+			return fmt.Errorf("vwap need horizon greater than 0")
+		}
+		var sum float64
+		for _, v := range p.Profile {
+			if v < 0 {
+				return fmt.Errorf("vwap profile can not be negative")
+			}
+			sum += v
+		}
+		if sum <= 0 {
+			return fmt.Errorf("vwap need profile with positive volume")
+		}
+	case Iceberg:
+		if p.Display <= 0 {
+			return fmt.Errorf("iceberg need display greater than 0")
+		}
+	default:
+		return fmt.Errorf("algo need be twap, vwap or iceberg")
+	}
+	return nil
+}
+
+// 按配置创建母订单，price为开始时的对手价，用于计算限价
+func NewParent(cfg *model.AlgoConfig, side string, amount, price float64) *Parent {
+	p := &Parent{
+		Side:         side,
+		Amount:       amount,
+		Horizon:      time.Duration(cfg.Horizon) * time.Millisecond,
+		Algo:         cfg.Type,
+		Slices:       cfg.Slices,
+		Profile:      cfg.Profile,
+		Display:      cfg.Display,
+		ChildTimeout: time.Duration(cfg.ChildTimeout) * time.Millisecond,
+	}
+	if cfg.PriceLimit > 0 {
+		if side == "buy" {
+			p.LimitPrice = price * (1 + cfg.PriceLimit/100)
+		} else {
+			p.LimitPrice = price * (1 - cfg.PriceLimit/100)
+		}
+	}
+	return p
+}
+
+// 到elapsed为止累计应该下单的比例，0 ~ 1
+func (p *Parent) target(elapsed time.Duration) float64 {
+	switch p.Algo {
+	case TWAP:
+		n := p.slices()
+		step := p.period(n)
+		if step <= 0 {
+			return 1
+		}
+		i := int(elapsed/step) + 1
+		if i >= n {
+			return 1
+		}
+		return float64(i) / float64(n)
+	case VWAP:
+		var (
+			n     = len(p.Profile)
+			step  = p.period(n)
+			sum   float64
+			total float64
+		)
+		if step <= 0 {
+			return 1
+		}
+		i := int(elapsed / step)
+		for k, v := range p.Profile {
+			if k <= i {
+				sum += v
+			}
+			total += v
+		}
+		if total <= 0 {
+			return 1
+		}
+		return sum / total
+	}
+	return 1
+}
+
+// 每个时间段的长度，没有时间段或者Horizon太短时为0
+func (p *Parent) period(n int) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return p.Horizon / time.Duration(n)
+}
+
+//
+func (p *Parent) slices() int {
+	if p.Slices > 0 {
+		return p.Slices
+	}
+	return defaultSlices
+}
+
+// 子订单的最大数量
+func (p *Parent) childAmount(want float64) float64 {
+	if p.Algo == Iceberg && want > p.Display {
+		return p.Display
+	}
+	return want
+}
+
+// TWAP和VWAP默认为一个时间段，冰山订单有限价时一直挂单
+func (p *Parent) childTimeout() time.Duration {
+	if p.ChildTimeout > 0 {
+		return p.ChildTimeout
+	}
+	switch p.Algo {
+	case TWAP:
+		return p.period(p.slices())
+	case VWAP:
+		return p.period(len(p.Profile))
+	}
+	if p.LimitPrice > 0 {
+		return 0
+	}
+	return defaultIcebergTimeout
+}
+
+// 子订单价格。TWAP和VWAP使用对手价尽快成交，冰山订单有限价时挂在限价，否则挂在己方最优价，
+// 价格都不超过限价
+func (p *Parent) childPrice(q *model.Quote) float64 {
+	var price float64
+	switch {
+	case p.Algo == Iceberg && p.LimitPrice > 0:
+		return p.LimitPrice
+	case p.Algo == Iceberg && p.Side == "buy", p.Algo != Iceberg && p.Side == "sell":
+		price = q.MaxBuyOnePrice
+	default:
+		price = q.MinSellOnePrice
+	}
+
+	if p.LimitPrice > 0 {
+		if p.Side == "buy" && price > p.LimitPrice {
+			return p.LimitPrice
+		}
+		if p.Side == "sell" && price < p.LimitPrice {
+			return p.LimitPrice
+		}
+	}
+	return price
+}
+
+// 执行子订单的交易对，价格和数量由实现按交易对精度格式化
+type Executor interface {
+	Place(side string, price, amount float64) (string, error)
+	Cancel(id string) error
+	Order(id string) (*model.OrderInfo, error)
+	Quote() (*model.Quote, error)
+	// 按数量精度向下取整
+	Round(amount float64) float64
+}
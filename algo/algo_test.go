@@ -0,0 +1,135 @@
+package algo
+
+import (
+	"fcoinExchange/model"
+	"math"
+	"testing"
+	"time"
+)
+
+//
+func TestParentValidate(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		parent Parent
+		ok     bool
+	}{
+		{"twap", Parent{Side: "buy", Amount: 1, Algo: TWAP, Horizon: time.Minute}, true},
+		{"vwap", Parent{Side: "sell", Amount: 1, Algo: VWAP, Horizon: time.Minute, Profile: []float64{1, 0, 2}}, true},
+		{"iceberg", Parent{Side: "buy", Amount: 1, Algo: Iceberg, Display: 0.1}, true},
+		{"bad side", Parent{Side: "hold", Amount: 1, Algo: TWAP, Horizon: time.Minute}, false},
+		{"zero amount", Parent{Side: "buy", Algo: TWAP, Horizon: time.Minute}, false},
+		{"negative limit", Parent{Side: "buy", Amount: 1, Algo: TWAP, Horizon: time.Minute, LimitPrice: -1}, false},
+		{"negative slices", Parent{Side: "buy", Amount: 1, Algo: TWAP, Horizon: time.Minute, Slices: -1}, false},
+		{"negative child timeout", Parent{Side: "buy", Amount: 1, Algo: Iceberg, Display: 1, ChildTimeout: -1}, false},
+		{"twap without horizon", Parent{Side: "buy", Amount: 1, Algo: TWAP}, false},
+		{"vwap without horizon", Parent{Side: "buy", Amount: 1, Algo: VWAP, Profile: []float64{1}}, false},
+		{"vwap without profile", Parent{Side: "buy", Amount: 1, Algo: VWAP, Horizon: time.Minute}, false},
+		{"vwap zero profile", Parent{Side: "buy", Amount: 1, Algo: VWAP, Horizon: time.Minute, Profile: []float64{0, 0}}, false},
+		{"vwap negative profile", Parent{Side: "buy", Amount: 1, Algo: VWAP, Horizon: time.Minute, Profile: []float64{2, -1}}, false},
+		{"iceberg without display", Parent{Side: "buy", Amount: 1, Algo: Iceberg}, false},
+		{"unknown algo", Parent{Side: "buy", Amount: 1, Algo: "pov", Horizon: time.Minute}, false},
+	} {
+		err := c.parent.Validate()
+		if (err == nil) != c.ok {
+			t.Errorf("%s: err = %v, want ok %v", c.name, err, c.ok)
+		}
+	}
+}
+
+// 累计进度在每个时间段开始时增加，结束后为1
+func TestParentTarget(t *testing.T) {
+	var (
+		twap = &Parent{Algo: TWAP, Horizon: 10 * time.Second, Slices: 5}
+		vwap = &Parent{Algo: VWAP, Horizon: 4 * time.Second, Profile: []float64{1, 3, 0, 4}}
+	)
+	for _, c := range []struct {
+		parent  *Parent
+		elapsed time.Duration
+		want    float64
+	}{
+		{twap, 0, 0.2},
+		{twap, 1999 * time.Millisecond, 0.2},
+		{twap, 2 * time.Second, 0.4},
+		{twap, 7 * time.Second, 0.8},
+		{twap, 9 * time.Second, 1},
+		{twap, time.Minute, 1},
+		{vwap, 0, 0.125},
+		{vwap, time.Second, 0.5},
+		{vwap, 2500 * time.Millisecond, 0.5},
+		{vwap, 3 * time.Second, 1},
+		{vwap, time.Minute, 1},
+		{&Parent{Algo: TWAP, Horizon: time.Second}, 150 * time.Millisecond, 0.2},
+		{&Parent{Algo: Iceberg, Display: 1}, 0, 1},
+		// 直接创建的母订单，时间段长度为0时不能除以0
+		{&Parent{Algo: TWAP, Horizon: 3, Slices: 5}, 0, 1},
+		{&Parent{Algo: TWAP}, time.Second, 1},
+		{&Parent{Algo: VWAP, Horizon: time.Minute}, 0, 1},
+		{&Parent{Algo: VWAP, Horizon: 2, Profile: []float64{1, 1, 1}}, 0, 1},
+		{&Parent{Algo: VWAP, Horizon: time.Minute, Profile: []float64{0, 0}}, 0, 1},
+	} {
+		if got := c.parent.target(c.elapsed); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s %+v at %s: target = %v, want %v", c.parent.Algo, c.parent, c.elapsed, got, c.want)
+		}
+	}
+}
+
+//
+func TestParentChildTimeout(t *testing.T) {
+	for _, c := range []struct {
+		parent *Parent
+		want   time.Duration
+	}{
+		{&Parent{Algo: TWAP, Horizon: 10 * time.Second, Slices: 5}, 2 * time.Second},
+		{&Parent{Algo: TWAP, Horizon: 10 * time.Second, ChildTimeout: time.Second}, time.Second},
+		{&Parent{Algo: VWAP, Horizon: 4 * time.Second, Profile: []float64{1, 1}}, 2 * time.Second},
+		{&Parent{Algo: Iceberg, Display: 1}, defaultIcebergTimeout},
+		{&Parent{Algo: Iceberg, Display: 1, LimitPrice: 100}, 0},
+		{&Parent{Algo: TWAP, Horizon: 3, Slices: 5}, 0},
+		{&Parent{Algo: VWAP, Horizon: time.Minute}, 0},
+	} {
+		if got := c.parent.childTimeout(); got != c.want {
+			t.Errorf("%+v: childTimeout = %s, want %s", c.parent, got, c.want)
+		}
+	}
+}
+
+// TWAP和VWAP使用对手价，冰山订单使用己方最优价或者限价，都不超过限价
+func TestParentChildPrice(t *testing.T) {
+	q := &model.Quote{MaxBuyOnePrice: 99, MinSellOnePrice: 101}
+	for _, c := range []struct {
+		name   string
+		parent Parent
+		want   float64
+	}{
+		{"twap buy", Parent{Algo: TWAP, Side: "buy"}, 101},
+		{"twap sell", Parent{Algo: TWAP, Side: "sell"}, 99},
+		{"vwap buy within limit", Parent{Algo: VWAP, Side: "buy", LimitPrice: 102}, 101},
+		{"twap buy clamped", Parent{Algo: TWAP, Side: "buy", LimitPrice: 100}, 100},
+		{"twap sell clamped", Parent{Algo: TWAP, Side: "sell", LimitPrice: 100}, 100},
+		{"twap sell within limit", Parent{Algo: TWAP, Side: "sell", LimitPrice: 98}, 99},
+		{"iceberg buy", Parent{Algo: Iceberg, Side: "buy"}, 99},
+		{"iceberg sell", Parent{Algo: Iceberg, Side: "sell"}, 101},
+		{"iceberg limit", Parent{Algo: Iceberg, Side: "buy", LimitPrice: 98.5}, 98.5},
+	} {
+		if got := c.parent.childPrice(q); got != c.want {
+			t.Errorf("%s: childPrice = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+//
+func TestNewParent(t *testing.T) {
+	cfg := &model.AlgoConfig{Type: TWAP, Horizon: 60000, Slices: 4, PriceLimit: 1, ChildTimeout: 5000}
+
+	p := NewParent(cfg, "buy", 2, 100)
+	if p.Horizon != time.Minute || p.ChildTimeout != 5*time.Second || p.Slices != 4 || math.Abs(p.LimitPrice-101) > 1e-9 {
+		t.Errorf("buy parent = %+v", p)
+	}
+	if p = NewParent(cfg, "sell", 2, 100); math.Abs(p.LimitPrice-99) > 1e-9 {
+		t.Errorf("sell limit price = %v, want 99", p.LimitPrice)
+	}
+	if p = NewParent(&model.AlgoConfig{Type: TWAP, Horizon: 60000}, "buy", 2, 100); p.LimitPrice != 0 {
+		t.Errorf("limit price = %v, want no limit", p.LimitPrice)
+	}
+}
@@ -0,0 +1,213 @@
+package algo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// 保留的已结束算法订单数量
+	keepFinished = 100
+
+	// 默认的算法订单管理，再平衡和控制接口提交的订单都由它管理
+	Default = NewManager()
+)
+
+// 按账户和交易对返回执行子订单的交易对
+type Resolver func(account, symbol, strategy string) (Executor, error)
+
+// 算法订单管理
+type Manager struct {
+	sync.Mutex
+	seq    int64
+	orders map[string]*Order
+}
+
+//
+func NewManager() *Manager {
+	return &Manager{orders: make(map[string]*Order)}
+}
+
+// 提交母订单并开始执行
+func (p *Manager) Submit(exec Executor, parent *Parent) (*Order, error) {
+	p.Lock()
+	p.seq++
+	id := fmt.Sprintf("%s-%d-%d", parent.Algo, time.Now().Unix(), p.seq)
+	p.Unlock()
+
+	o, err := Start(id, exec, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Lock()
+	p.orders[id] = o
+	p.prune()
+	p.Unlock()
+	return o, nil
+}
+
+// 超过keepFinished时删除最早结束的订单，需持有锁
+func (p *Manager) prune() {
+	var finished = make([]*Order, 0)
+	for _, o := range p.orders {
+		select {
+		case <-o.Done():
+			finished = append(finished, o)
+		default:
+		}
+	}
+	if len(finished) <= keepFinished {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].startedAt.Before(finished[j].startedAt)
+	})
+	for _, o := range finished[:len(finished)-keepFinished] {
+		delete(p.orders, o.Id)
+	}
+}
+
+//
+func (p *Manager) Get(id string) (*Order, bool) {
+	p.Lock()
+	defer p.Unlock()
+	o, ok := p.orders[id]
+	return o, ok
+}
+
+// 按开始时间排列的所有订单状态
+func (p *Manager) List() []*Status {
+	p.Lock()
+	var orders = make([]*Order, 0, len(p.orders))
+	for _, o := range p.orders {
+		orders = append(orders, o)
+	}
+	p.Unlock()
+
+	var list = make([]*Status, 0, len(orders))
+	for _, o := range orders {
+		list = append(list, o.Status())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedAt.Before(list[j].StartedAt)
+	})
+	return list
+}
+
+// 控制接口提交的母订单，horizon和child_timeout单位为毫秒
+type submitRequest struct {
+	Account      string    `json:"account"`
+	Symbol       string    `json:"symbol"`
+	Side         string    `json:"side"`
+	Amount       float64   `json:"amount"`
+	LimitPrice   float64   `json:"limit_price"`
+	Horizon      int64     `json:"horizon"`
+	Algo         string    `json:"algo"`
+	Slices       int       `json:"slices"`
+	Profile      []float64 `json:"profile"`
+	Display      float64   `json:"display"`
+	ChildTimeout int64     `json:"child_timeout"`
+}
+
+// 返回算法订单的http接口。
+// GET /algo 返回所有订单，POST /algo 提交母订单，
+// POST /algo/<id>/pause、/algo/<id>/resume、/algo/<id>/cancel 暂停、恢复和撤销订单
+func (p *Manager) Handler(resolve Resolver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/algo"), "/"), "/")
+		switch {
+		case r.Method == http.MethodGet && parts[0] == "":
+			writeJSON(w, p.List())
+		case r.Method == http.MethodGet && len(parts) == 1:
+			o, ok := p.Get(parts[0])
+			if !ok {
+				http.Error(w, "algo order not found", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, o.Status())
+		case r.Method == http.MethodPost && parts[0] == "":
+			p.submit(w, r, resolve)
+		case r.Method == http.MethodPost && len(parts) == 2:
+			o, ok := p.Get(parts[0])
+			if !ok {
+				http.Error(w, "algo order not found", http.StatusNotFound)
+				return
+			}
+			var err error
+			switch parts[1] {
+			case "pause":
+				err = o.Pause()
+			case "resume":
+				err = o.Resume()
+			case "cancel":
+				err = o.Cancel()
+			default:
+				http.Error(w, "action need be pause, resume or cancel", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			o.logger.Infow("algo order " + parts[1] + " by control api")
+			writeJSON(w, o.Status())
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	})
+}
+
+// 手动提交的订单使用manual策略名称
+func (p *Manager) submit(w http.ResponseWriter, r *http.Request, resolve Resolver) {
+	var req submitRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	parent := &Parent{
+		Account:      req.Account,
+		Symbol:       req.Symbol,
+		Side:         req.Side,
+		Amount:       req.Amount,
+		LimitPrice:   req.LimitPrice,
+		Horizon:      time.Duration(req.Horizon) * time.Millisecond,
+		Algo:         req.Algo,
+		Slices:       req.Slices,
+		Profile:      req.Profile,
+		Display:      req.Display,
+		ChildTimeout: time.Duration(req.ChildTimeout) * time.Millisecond,
+		Strategy:     "manual",
+	}
+	err = parent.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	exec, err := resolve(req.Account, req.Symbol, parent.Strategy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	o, err := p.Submit(exec, parent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, o.Status())
+}
+
+//
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
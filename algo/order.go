@@ -0,0 +1,394 @@
+package algo
+
+import (
+	"fcoinExchange/log"
+	"fcoinExchange/metrics"
+	"fcoinExchange/notify"
+	"fcoinExchange/venue"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 算法订单状态
+const (
+	StateRunning   string = "running"
+	StatePaused    string = "paused"
+	StateCanceled  string = "canceled"
+	StateCompleted string = "completed"
+	StateExpired   string = "expired"
+	StateFailed    string = "failed"
+)
+
+var (
+	// 检查子订单和下单的时间间隔
+	pollInterval = time.Second
+	// 连续下单失败达到此次数时停止
+	maxPlaceErrors = 5
+)
+
+// 子订单
+type Child struct {
+	Id     string  `json:"id"`
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"`
+	Filled float64 `json:"filled"`
+	Value  float64 `json:"value"`
+	// 下单结果不确定时为unknown，没有订单号
+	State    string    `json:"state"`
+	PlacedAt time.Time `json:"placed_at"`
+	// 已经发出撤单请求
+	canceling bool
+}
+
+// 子订单是否已经结束
+func (p *Child) done() bool {
+	switch p.State {
+	case "filled", "canceled", "partial_canceled":
+		return true
+	}
+	return false
+}
+
+// 算法订单的状态快照
+type Status struct {
+	Id     string  `json:"id"`
+	Parent *Parent `json:"parent"`
+	State  string  `json:"state"`
+	Filled float64 `json:"filled"`
+	// 下单结果不确定的子订单数量，可能已经在交易所成交
+	Unresolved float64   `json:"unresolved,omitempty"`
+	AvgPrice   float64   `json:"avg_price"`
+	Children   []*Child  `json:"children"`
+	StartedAt  time.Time `json:"started_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// 算法订单，同一时间最多只有一个未完成的子订单，避免重复成交超过母订单数量。
+// 下单结果不确定时子订单可能已经在交易所存在，暂停执行并把该数量计为已经下单，
+// 恢复后也不会再次下单，保证成交不超过母订单数量，实际结果由对账确认
+type Order struct {
+	Id     string
+	Parent *Parent
+
+	exec   Executor
+	logger *zap.SugaredLogger
+	wake   chan struct{}
+	done   chan struct{}
+
+	sync.Mutex
+	state    string
+	children []*Child
+	working  *Child
+	filled   float64
+	value    float64
+	// 下单结果不确定的数量
+	unresolved float64
+	startedAt  time.Time
+	err        error
+	errors     int
+}
+
+// 校验母订单后在后台开始执行
+func Start(id string, exec Executor, parent *Parent) (*Order, error) {
+	err := parent.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	o := newOrder(id, exec, parent)
+	o.logger.Infow("algo order started", log.FieldAmount, parent.Amount, "limit_price", parent.LimitPrice, "horizon", parent.Horizon)
+	metrics.Inc("algo", parent.Algo, "started")
+	go o.run()
+	return o, nil
+}
+
+//
+func newOrder(id string, exec Executor, parent *Parent) *Order {
+	return &Order{
+		Id:     id,
+		Parent: parent,
+		exec:   exec,
+		logger: log.Named("algo").With(
+			"algo_id", id,
+			"algo", parent.Algo,
+			log.FieldAccount, parent.Account,
+			log.FieldSymbol, parent.Symbol,
+			log.FieldSide, parent.Side,
+			log.FieldStrategy, parent.Strategy,
+		),
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		state:     StateRunning,
+		children:  make([]*Child, 0),
+		startedAt: time.Now(),
+	}
+}
+
+// 暂停时撤销未完成的子订单，恢复后按进度继续下单
+func (p *Order) Pause() error {
+	return p.setState(StateRunning, StatePaused)
+}
+
+//
+func (p *Order) Resume() error {
+	return p.setState(StatePaused, StateRunning)
+}
+
+// 撤销未完成的子订单后停止
+func (p *Order) Cancel() error {
+	p.Lock()
+	defer p.Unlock()
+	if p.finished() {
+		return fmt.Errorf("algo order %s is %s", p.Id, p.state)
+	}
+	p.state = StateCanceled
+	p.notify()
+	return nil
+}
+
+//
+func (p *Order) setState(from, to string) error {
+	p.Lock()
+	defer p.Unlock()
+	if p.state != from {
+		return fmt.Errorf("algo order %s is %s", p.Id, p.state)
+	}
+	p.state = to
+	p.logger.Infow("algo order " + to)
+	p.notify()
+	return nil
+}
+
+// 唤醒执行循环，需持有锁
+func (p *Order) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 执行结束后关闭
+func (p *Order) Done() <-chan struct{} {
+	return p.done
+}
+
+// 需持有锁
+func (p *Order) finished() bool {
+	switch p.state {
+	case StateCanceled, StateCompleted, StateExpired, StateFailed:
+		return true
+	}
+	return false
+}
+
+//
+func (p *Order) Status() *Status {
+	p.Lock()
+	defer p.Unlock()
+	s := &Status{
+		Id:         p.Id,
+		Parent:     p.Parent,
+		State:      p.state,
+		Filled:     p.filled,
+		Unresolved: p.unresolved,
+		Children:   make([]*Child, 0, len(p.children)),
+		StartedAt:  p.startedAt,
+	}
+	if p.filled > 0 {
+		s.AvgPrice = p.value / p.filled
+	}
+	if p.err != nil {
+		s.Error = p.err.Error()
+	}
+	for _, v := range p.children {
+		c := *v
+		s.Children = append(s.Children, &c)
+	}
+	return s
+}
+
+//
+func (p *Order) run() {
+	defer close(p.done)
+	defer notify.Recover(p.Parent.Account, "algo "+p.Id)
+
+	tk := time.NewTicker(pollInterval)
+	defer tk.Stop()
+	for {
+		if !p.step() {
+			break
+		}
+		select {
+		case <-tk.C:
+		case <-p.wake:
+		}
+	}
+
+	s := p.Status()
+	metrics.Inc("algo", p.Parent.Algo, s.State)
+	p.logger.Infow("algo order finished", "state", s.State, "filled", s.Filled, "unresolved", s.Unresolved, "avg_price", s.AvgPrice, "error", s.Error)
+}
+
+// 执行一次检查，订单结束并且没有未完成的子订单时返回false
+func (p *Order) step() bool {
+	p.refresh()
+
+	p.Lock()
+	var (
+		state   = p.state
+		working = p.working
+		// 结果不确定的数量按已经下单计算
+		filled = p.filled + p.unresolved
+	)
+	p.Unlock()
+
+	elapsed := time.Since(p.startedAt)
+	if state == StateRunning && p.exec.Round(p.Parent.Amount-filled) <= 0 {
+		state = p.finish(StateCompleted, nil)
+	}
+	if state == StateRunning && p.Parent.Horizon > 0 && elapsed >= p.Parent.Horizon {
+		state = p.finish(StateExpired, nil)
+	}
+
+	if working != nil {
+		timeout := p.Parent.childTimeout()
+		if state != StateRunning || (timeout > 0 && time.Since(working.PlacedAt) >= timeout) {
+			p.cancelChild(working)
+		}
+		// 等待子订单结束后再下新的子订单
+		return true
+	}
+
+	if state != StateRunning {
+		return state == StatePaused
+	}
+
+	want := p.exec.Round(p.Parent.childAmount(p.Parent.Amount*p.Parent.target(elapsed) - filled))
+	if want <= 0 {
+		return true
+	}
+	p.place(want)
+	return true
+}
+
+// 设置结束状态，已经被撤销时保持撤销状态
+func (p *Order) finish(state string, err error) string {
+	p.Lock()
+	defer p.Unlock()
+	if !p.finished() {
+		p.state = state
+		p.err = err
+	}
+	return p.state
+}
+
+// 查询未完成子订单的成交数量
+func (p *Order) refresh() {
+	p.Lock()
+	c := p.working
+	p.Unlock()
+	if c == nil {
+		return
+	}
+
+	info, err := p.exec.Order(c.Id)
+	if err != nil {
+		p.logger.Warnw("get child order failed", log.FieldOrderId, c.Id, "error", err)
+		return
+	}
+	filled, _ := strconv.ParseFloat(info.FilledAmount, 64)
+	value, _ := strconv.ParseFloat(info.ExecutedValue, 64)
+
+	p.Lock()
+	defer p.Unlock()
+	p.filled += filled - c.Filled
+	p.value += value - c.Value
+	c.Filled = filled
+	c.Value = value
+	c.State = info.State
+	if c.done() {
+		p.working = nil
+		p.logger.Infow("child order done", log.FieldOrderId, c.Id, "state", c.State, "filled", c.Filled, "total_filled", p.filled)
+	}
+}
+
+//
+func (p *Order) cancelChild(c *Child) {
+	p.Lock()
+	canceling := c.canceling
+	p.Unlock()
+	if canceling {
+		return
+	}
+
+	err := p.exec.Cancel(c.Id)
+	if err != nil {
+		// 子订单可能已经成交，下次检查时以查询结果为准
+		p.logger.Warnw("cancel child order failed", log.FieldOrderId, c.Id, "error", err)
+		return
+	}
+	p.Lock()
+	c.canceling = true
+	p.Unlock()
+}
+
+//
+func (p *Order) place(amount float64) {
+	q, err := p.exec.Quote()
+	if err != nil {
+		p.logger.Warnw("get quote failed", "error", err)
+		return
+	}
+	price := p.Parent.childPrice(q)
+	if price <= 0 {
+		p.logger.Warnw("no price to place child order")
+		return
+	}
+
+	id, err := p.exec.Place(p.Parent.Side, price, amount)
+
+	p.Lock()
+	defer p.Unlock()
+	if venue.IsAmbiguous(err) {
+		p.unresolved += amount
+		p.children = append(p.children, &Child{
+			Price:    price,
+			Amount:   amount,
+			State:    "unknown",
+			PlacedAt: time.Now(),
+		})
+		if p.state == StateRunning {
+			p.state = StatePaused
+			p.err = err
+		}
+		metrics.Inc("algo", p.Parent.Algo, "unresolved")
+		p.logger.Errorw("child order result unknown, algo order paused until reconciliation",
+			log.FieldPrice, price, log.FieldAmount, amount, "unresolved", p.unresolved, "error", err)
+		return
+	}
+	if err != nil {
+		p.errors++
+		p.logger.Warnw("place child order failed", log.FieldPrice, price, log.FieldAmount, amount, "error", err)
+		if p.errors >= maxPlaceErrors && !p.finished() {
+			p.state = StateFailed
+			p.err = err
+		}
+		return
+	}
+	p.errors = 0
+
+	c := &Child{
+		Id:       id,
+		Price:    price,
+		Amount:   amount,
+		State:    "submitted",
+		PlacedAt: time.Now(),
+	}
+	p.children = append(p.children, c)
+	p.working = c
+	p.logger.Infow("child order placed", log.FieldOrderId, id, log.FieldPrice, price, log.FieldAmount, amount)
+}
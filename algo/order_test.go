@@ -0,0 +1,332 @@
+package algo
+
+import (
+	"errors"
+	"fcoinExchange/model"
+	"fcoinExchange/venue"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试使用的Executor，记录子订单，成交由测试设置
+type fakeExecutor struct {
+	sync.Mutex
+	quote    *model.Quote
+	orders   map[string]*model.OrderInfo
+	placed   []float64
+	canceled []string
+	// 依次作为下单的结果，用完后下单成功
+	placeErrs []error
+}
+
+//
+func newFakeExecutor() *fakeExecutor {
+	return &fakeExecutor{
+		quote:  &model.Quote{MaxBuyOnePrice: 99, MinSellOnePrice: 101},
+		orders: make(map[string]*model.OrderInfo),
+	}
+}
+
+func (p *fakeExecutor) Place(side string, price, amount float64) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+	if len(p.placeErrs) > 0 {
+		err := p.placeErrs[0]
+		p.placeErrs = p.placeErrs[1:]
+		return "", err
+	}
+	p.placed = append(p.placed, amount)
+	id := strconv.Itoa(len(p.placed))
+	p.orders[id] = &model.OrderInfo{Id: id, State: "submitted", FilledAmount: "0", ExecutedValue: "0"}
+	return id, nil
+}
+
+func (p *fakeExecutor) Cancel(id string) error {
+	p.Lock()
+	defer p.Unlock()
+	p.canceled = append(p.canceled, id)
+	return nil
+}
+
+func (p *fakeExecutor) Order(id string) (*model.OrderInfo, error) {
+	p.Lock()
+	defer p.Unlock()
+	o, ok := p.orders[id]
+	if !ok {
+		return nil, fmt.Errorf("order %s not found", id)
+	}
+	c := *o
+	return &c, nil
+}
+
+func (p *fakeExecutor) Quote() (*model.Quote, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.quote, nil
+}
+
+func (p *fakeExecutor) Round(amount float64) float64 {
+	return math.Floor(amount*10000+1e-9) / 10000
+}
+
+// 设置子订单的成交数量和状态
+func (p *fakeExecutor) fill(id string, amount float64, state string) {
+	p.Lock()
+	defer p.Unlock()
+	o := p.orders[id]
+	o.FilledAmount = strconv.FormatFloat(amount, 'f', -1, 64)
+	o.ExecutedValue = strconv.FormatFloat(amount*100, 'f', -1, 64)
+	o.State = state
+}
+
+//
+func (p *fakeExecutor) placedAmounts() []float64 {
+	p.Lock()
+	defer p.Unlock()
+	return append([]float64(nil), p.placed...)
+}
+
+// 不启动执行循环的算法订单，测试直接调用step
+func newTestOrder(t *testing.T, exec Executor, parent *Parent) *Order {
+	if err := parent.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return newOrder("test", exec, parent)
+}
+
+//
+func twapParent() *Parent {
+	return &Parent{Side: "buy", Amount: 1, Algo: TWAP, Horizon: time.Hour, Slices: 2}
+}
+
+// 按进度下单，子订单结束后才下一个，全部成交后完成
+func TestOrderTWAPCompletes(t *testing.T) {
+	exec := newFakeExecutor()
+	o := newTestOrder(t, exec, twapParent())
+
+	if !o.step() {
+		t.Fatal("step stopped")
+	}
+	if got := exec.placedAmounts(); len(got) != 1 || got[0] != 0.5 {
+		t.Fatalf("placed %v, want first slice 0.5", got)
+	}
+
+	// 子订单未结束时不下新的子订单
+	exec.fill("1", 0.2, "partial_filled")
+	o.step()
+	if n := len(exec.placedAmounts()); n != 1 {
+		t.Errorf("placed %d children while one is working", n)
+	}
+
+	// 第一个时间段的数量已经成交，等待下一个时间段
+	exec.fill("1", 0.5, "filled")
+	o.step()
+	if n := len(exec.placedAmounts()); n != 1 {
+		t.Errorf("placed %d children ahead of schedule", n)
+	}
+
+	o.startedAt = time.Now().Add(-31 * time.Minute)
+	o.step()
+	if got := exec.placedAmounts(); len(got) != 2 || got[1] != 0.5 {
+		t.Fatalf("placed %v, want second slice 0.5", got)
+	}
+
+	exec.fill("2", 0.5, "filled")
+	if o.step() {
+		t.Errorf("step continues after parent filled")
+	}
+	s := o.Status()
+	if s.State != StateCompleted || s.Filled != 1 || s.AvgPrice != 100 || len(s.Children) != 2 {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+// 暂停时撤销子订单并不再下单，恢复后继续，撤销后子订单结束时停止
+func TestOrderPauseResumeCancel(t *testing.T) {
+	exec := newFakeExecutor()
+	o := newTestOrder(t, exec, twapParent())
+	o.step()
+
+	if err := o.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Pause(); err == nil {
+		t.Errorf("pause a paused order")
+	}
+	o.step()
+	if len(exec.canceled) != 1 || exec.canceled[0] != "1" {
+		t.Fatalf("canceled %v, want working child canceled on pause", exec.canceled)
+	}
+	// 撤单请求只发送一次
+	o.step()
+	if len(exec.canceled) != 1 {
+		t.Errorf("canceled %v, want one cancel request", exec.canceled)
+	}
+
+	exec.fill("1", 0.1, "partial_canceled")
+	if !o.step() {
+		t.Fatal("paused order stopped")
+	}
+	if n := len(exec.placedAmounts()); n != 1 {
+		t.Errorf("paused order placed %d children", n)
+	}
+
+	if err := o.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	o.step()
+	if got := exec.placedAmounts(); len(got) != 2 || math.Abs(got[1]-0.4) > 1e-9 {
+		t.Fatalf("placed %v, want remaining 0.4 of first slice", got)
+	}
+
+	if err := o.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+	if !o.step() {
+		t.Errorf("canceled order stopped before child done")
+	}
+	if len(exec.canceled) != 2 || exec.canceled[1] != "2" {
+		t.Errorf("canceled %v, want working child canceled", exec.canceled)
+	}
+	exec.fill("2", 0, "canceled")
+	if o.step() {
+		t.Errorf("canceled order continues after child done")
+	}
+	if s := o.Status(); s.State != StateCanceled || math.Abs(s.Filled-0.1) > 1e-9 {
+		t.Errorf("status = %+v", s)
+	}
+	if err := o.Cancel(); err == nil {
+		t.Errorf("cancel a canceled order")
+	}
+	if err := o.Resume(); err == nil {
+		t.Errorf("resume a canceled order")
+	}
+}
+
+// 超过执行时间后撤销子订单并结束
+func TestOrderExpires(t *testing.T) {
+	exec := newFakeExecutor()
+	o := newTestOrder(t, exec, twapParent())
+	o.step()
+
+	o.startedAt = time.Now().Add(-2 * time.Hour)
+	o.step()
+	if len(exec.canceled) != 1 {
+		t.Fatalf("canceled %v, want working child canceled on expiry", exec.canceled)
+	}
+	exec.fill("1", 0.3, "partial_canceled")
+	if o.step() {
+		t.Errorf("expired order continues")
+	}
+	if s := o.Status(); s.State != StateExpired || s.Filled != 0.3 {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+// 子订单超时后撤单，剩余数量重新下单
+func TestOrderChildTimeout(t *testing.T) {
+	exec := newFakeExecutor()
+	parent := twapParent()
+	parent.ChildTimeout = time.Minute
+	o := newTestOrder(t, exec, parent)
+	o.step()
+
+	o.working.PlacedAt = time.Now().Add(-2 * time.Minute)
+	o.step()
+	if len(exec.canceled) != 1 {
+		t.Fatalf("canceled %v, want timed out child canceled", exec.canceled)
+	}
+	exec.fill("1", 0.2, "partial_canceled")
+	o.step()
+	if got := exec.placedAmounts(); len(got) != 2 || math.Abs(got[1]-0.3) > 1e-9 {
+		t.Errorf("placed %v, want remaining 0.3", got)
+	}
+}
+
+// 下单结果不确定时暂停，该数量计为已经下单，恢复后不会超过母订单数量
+func TestOrderAmbiguousPlace(t *testing.T) {
+	exec := newFakeExecutor()
+	exec.placeErrs = []error{&venue.AmbiguousError{Err: errors.New("timeout")}}
+	o := newTestOrder(t, exec, twapParent())
+
+	o.step()
+	s := o.Status()
+	if s.State != StatePaused || s.Unresolved != 0.5 || s.Error == "" {
+		t.Fatalf("status after ambiguous place = %+v", s)
+	}
+	if len(s.Children) != 1 || s.Children[0].State != "unknown" {
+		t.Errorf("children = %+v", s.Children)
+	}
+	o.step()
+	if n := len(exec.placedAmounts()); n != 0 {
+		t.Errorf("paused order placed %d children", n)
+	}
+
+	if err := o.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	o.step()
+	if n := len(exec.placedAmounts()); n != 0 {
+		t.Errorf("placed %d children for a slice already submitted", n)
+	}
+
+	o.startedAt = time.Now().Add(-31 * time.Minute)
+	o.step()
+	if got := exec.placedAmounts(); len(got) != 1 || got[0] != 0.5 {
+		t.Fatalf("placed %v, want only the second slice", got)
+	}
+	exec.fill("1", 0.5, "filled")
+	if o.step() {
+		t.Errorf("step continues when filled and unresolved reach the parent amount")
+	}
+	if s := o.Status(); s.State != StateCompleted || s.Filled != 0.5 || s.Unresolved != 0.5 {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+// 连续下单失败达到次数时停止
+func TestOrderPlaceErrors(t *testing.T) {
+	exec := newFakeExecutor()
+	for i := 0; i < maxPlaceErrors; i++ {
+		exec.placeErrs = append(exec.placeErrs, errors.New("insufficient balance"))
+	}
+	o := newTestOrder(t, exec, twapParent())
+
+	for i := 0; i < maxPlaceErrors-1; i++ {
+		if !o.step() {
+			t.Fatalf("stopped after %d errors", i+1)
+		}
+	}
+	if o.Status().State != StateRunning {
+		t.Fatalf("state = %s before max errors", o.Status().State)
+	}
+	o.step()
+	if o.step() {
+		t.Errorf("step continues after max errors")
+	}
+	if s := o.Status(); s.State != StateFailed || s.Error == "" {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+// 冰山订单每次只挂出display数量
+func TestOrderIcebergDisplay(t *testing.T) {
+	exec := newFakeExecutor()
+	o := newTestOrder(t, exec, &Parent{Side: "sell", Amount: 1, Algo: Iceberg, Display: 0.3})
+
+	for i, want := range []float64{0.3, 0.3, 0.3, 0.1} {
+		o.step()
+		got := exec.placedAmounts()
+		if len(got) != i+1 || math.Abs(got[i]-want) > 1e-9 {
+			t.Fatalf("placed %v, want child %d of %v", got, i+1, want)
+		}
+		exec.fill(strconv.Itoa(i+1), want, "filled")
+	}
+	if o.step() {
+		t.Errorf("iceberg continues after filled")
+	}
+}
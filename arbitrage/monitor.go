@@ -0,0 +1,281 @@
+package arbitrage
+
+import (
+	"encoding/json"
+	"fcoinExchange/conf"
+	"fcoinExchange/event"
+	"fcoinExchange/health"
+	"fcoinExchange/log"
+	"fcoinExchange/metrics"
+	"fcoinExchange/model"
+	"fcoinExchange/notify"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// 最小检查间隔，单位毫秒
+	minInterval int64 = 500
+)
+
+// 在Buy以BuyPrice买入Amount，在Sell以SellPrice卖出。
+// Cost包含买入的手续费和提币费用，Proceeds为扣除手续费后的卖出收入，都以quote currency计
+type Opportunity struct {
+	Symbol     string    `json:"symbol"`
+	Buy        string    `json:"buy"`
+	Sell       string    `json:"sell"`
+	BuyPrice   float64   `json:"buy_price"`
+	SellPrice  float64   `json:"sell_price"`
+	Amount     float64   `json:"amount"`
+	Cost       float64   `json:"cost"`
+	Proceeds   float64   `json:"proceeds"`
+	Net        float64   `json:"net"`
+	NetPercent float64   `json:"net_percent"`
+	Time       time.Time `json:"time"`
+}
+
+// 计算在buy买入并在sell卖出的净收益，amount为0时使用两边盘口的较小数量，
+// 没有可成交数量时返回nil
+func Evaluate(buy, sell *Quote, bs, ss *model.ArbitrageSource, amount float64) *Opportunity {
+	if buy.Ask <= 0 || sell.Bid <= 0 {
+		return nil
+	}
+	if amount <= 0 {
+		amount = buy.AskAmount
+		if sell.BidAmount < amount {
+			amount = sell.BidAmount
+		}
+	}
+	if amount <= 0 {
+		return nil
+	}
+
+	var (
+		cost     = buy.Ask*amount*(1+bs.TakerFee) + bs.WithdrawFees[buy.Symbol]*buy.Ask
+		proceeds = sell.Bid * amount * (1 - ss.TakerFee)
+	)
+	return &Opportunity{
+		Symbol:     buy.Symbol,
+		Buy:        buy.Source,
+		Sell:       sell.Source,
+		BuyPrice:   buy.Ask,
+		SellPrice:  sell.Bid,
+		Amount:     amount,
+		Cost:       cost,
+		Proceeds:   proceeds,
+		Net:        proceeds - cost,
+		NetPercent: (proceeds - cost) / cost * 100,
+		Time:       time.Now(),
+	}
+}
+
+// 配置的来源和创建后的行情来源
+type source struct {
+	cfg *model.ArbitrageSource
+	Source
+}
+
+// 跨交易所价差监控。
+// 定时从所有来源查询相同交易对的行情，净收益超过阈值时记录日志、告警，
+// 并在事件总线上发布event.Opportunity，策略可以订阅后执行两边的交易
+type Monitor struct {
+	bus    *event.Bus
+	logger *zap.SugaredLogger
+
+	sync.RWMutex
+	sources []*source
+	// 创建sources使用的配置
+	sourceConfigs []*model.ArbitrageSource
+	timeout       int
+	quotes        map[string][]*Quote
+	opportunities []*Opportunity
+}
+
+//
+func NewMonitor(bus *event.Bus) *Monitor {
+	return &Monitor{
+		bus:    bus,
+		logger: log.Named("arbitrage"),
+		quotes: make(map[string][]*Quote),
+	}
+}
+
+// 按配置定时检查，interval不大于0时暂停，配置重新设置interval后恢复
+func (p *Monitor) Run(r *health.Run) {
+	defer notify.Recover("", "arbitrage")
+
+	p.logger.Infof("start arbitrage monitor task")
+	for {
+		cfg := conf.GetConfiguration()
+		interval := cfg.Arbitrage.Interval
+		if interval > 0 {
+			r.Beat()
+			p.Scan(cfg)
+		} else {
+			// 暂停期间不判断为卡住
+			r.Idle()
+		}
+
+		if !wait(r, interval) {
+			return
+		}
+	}
+}
+
+// 来源配置或者请求超时时间变化时重新创建行情来源
+func (p *Monitor) reload(cfg *model.Configuration) []*source {
+	p.Lock()
+	defer p.Unlock()
+	if p.sources != nil && p.timeout == cfg.RequestTimeout && reflect.DeepEqual(p.sourceConfigs, cfg.Arbitrage.Sources) {
+		return p.sources
+	}
+
+	var sources = make([]*source, 0, len(cfg.Arbitrage.Sources))
+	for _, v := range cfg.Arbitrage.Sources {
+		s, err := NewSource(v, cfg.RequestTimeout)
+		if err != nil {
+			p.logger.Errorf("create source failed. %s", err)
+			continue
+		}
+		sources = append(sources, &source{cfg: v, Source: s})
+	}
+	p.sources = sources
+	p.sourceConfigs = cfg.Arbitrage.Sources
+	p.timeout = cfg.RequestTimeout
+	return sources
+}
+
+// 查询所有交易对的行情并返回超过阈值的套利机会，按净收益百分比从高到低排列
+func (p *Monitor) Scan(cfg *model.Configuration) []*Opportunity {
+	var (
+		ac      = cfg.Arbitrage
+		sources = p.reload(cfg)
+		quotes  = make(map[string][]*Quote)
+		list    = make([]*Opportunity, 0)
+	)
+
+	for _, symbol := range ac.Symbols {
+		qs := p.fetch(sources, symbol, ac.MaxQuoteAge)
+		quotes[symbol] = qs
+
+		for i, buy := range qs {
+			for j, sell := range qs {
+				if i == j || buy == nil || sell == nil {
+					continue
+				}
+				o := Evaluate(buy, sell, sources[i].cfg, sources[j].cfg, ac.Amount)
+				if o == nil || o.Net <= 0 || o.NetPercent < ac.Threshold {
+					continue
+				}
+				list = append(list, o)
+			}
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].NetPercent > list[j].NetPercent
+	})
+
+	p.Lock()
+	p.quotes = quotes
+	p.opportunities = list
+	p.Unlock()
+
+	for _, o := range list {
+		p.report(o)
+	}
+	return list
+}
+
+// 同时查询所有来源，减少不同来源行情的时间差，查询失败或者过期的行情为nil
+func (p *Monitor) fetch(sources []*source, symbol string, maxAge int64) []*Quote {
+	var (
+		quotes = make([]*Quote, len(sources))
+		wg     sync.WaitGroup
+	)
+	for i, s := range sources {
+		wg.Add(1)
+		go func(i int, s *source) {
+			defer wg.Done()
+			q, err := s.Quote(symbol)
+			if err != nil {
+				metrics.Inc("arbitrage", "quote_error", s.Name())
+				p.logger.Warnw("get quote failed", "source", s.Name(), log.FieldSymbol, symbol, "error", err)
+				return
+			}
+			if maxAge > 0 && time.Since(q.Time) > time.Duration(maxAge)*time.Millisecond {
+				p.logger.Debugw("quote is stale", "source", s.Name(), log.FieldSymbol, symbol, "time", q.Time)
+				return
+			}
+			quotes[i] = q
+		}(i, s)
+	}
+	wg.Wait()
+	return quotes
+}
+
+//
+func (p *Monitor) report(o *Opportunity) {
+	metrics.Inc("arbitrage", "opportunity", o.Symbol, o.Buy, o.Sell)
+	p.logger.Infow("arbitrage opportunity",
+		log.FieldSymbol, o.Symbol,
+		"buy", o.Buy,
+		"sell", o.Sell,
+		"buy_price", o.BuyPrice,
+		"sell_price", o.SellPrice,
+		log.FieldAmount, o.Amount,
+		"net", o.Net,
+		"net_percent", o.NetPercent,
+	)
+
+	notify.Send(&notify.Event{
+		Kind:     notify.KindArbitrage,
+		Severity: notify.SeverityInfo,
+		Key:      o.Symbol + "/" + o.Buy + "/" + o.Sell,
+		Title:    fmt.Sprintf("%s arbitrage %.4f%%", o.Symbol, o.NetPercent),
+		Message: fmt.Sprintf("buy %.8f at %s %.8f, sell at %s %.8f, net %.8f",
+			o.Amount, o.Buy, o.BuyPrice, o.Sell, o.SellPrice, o.Net),
+	})
+
+	p.bus.Publish(&event.Opportunity{
+		Symbol:     o.Symbol,
+		Buy:        o.Buy,
+		Sell:       o.Sell,
+		BuyPrice:   o.BuyPrice,
+		SellPrice:  o.SellPrice,
+		Amount:     o.Amount,
+		NetPercent: o.NetPercent,
+		Time:       o.Time,
+	})
+}
+
+// 最近一次检查的套利机会
+func (p *Monitor) Opportunities() []*Opportunity {
+	p.RLock()
+	defer p.RUnlock()
+	return p.opportunities
+}
+
+// /arbitrage，返回最近一次检查的行情和套利机会
+func (p *Monitor) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.RLock()
+		data, err := json.Marshal(map[string]interface{}{
+			"quotes":        p.quotes,
+			"opportunities": p.opportunities,
+		})
+		p.RUnlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
@@ -0,0 +1,143 @@
+package arbitrage
+
+import (
+	"fcoinExchange/event"
+	"fcoinExchange/model"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// 测试使用的行情来源，返回设置的行情，没有设置时返回错误
+type fakeSource struct {
+	name   string
+	quotes map[string]*Quote
+}
+
+//
+func (p *fakeSource) Name() string {
+	return p.name
+}
+
+//
+func (p *fakeSource) Quote(symbol string) (*Quote, error) {
+	q, ok := p.quotes[symbol]
+	if !ok {
+		return nil, fmt.Errorf("%s has no %s quote", p.name, symbol)
+	}
+	return q, nil
+}
+
+//
+func TestEvaluateOpportunity(t *testing.T) {
+	var (
+		buy  = &Quote{Source: "a", Symbol: "btcusdt", Ask: 100, AskAmount: 2}
+		sell = &Quote{Source: "b", Symbol: "btcusdt", Bid: 101, BidAmount: 3}
+		free = &model.ArbitrageSource{}
+		fee  = &model.ArbitrageSource{TakerFee: 0.001}
+		// 提币0.01 btc
+		withdraw = &model.ArbitrageSource{WithdrawFees: map[string]float64{"btcusdt": 0.01}}
+	)
+
+	for _, c := range []struct {
+		name   string
+		buy    *Quote
+		sell   *Quote
+		bs     *model.ArbitrageSource
+		ss     *model.ArbitrageSource
+		amount float64
+		nil    bool
+		want   float64
+		net    float64
+	}{
+		{name: "no fee", buy: buy, sell: sell, bs: free, ss: free, amount: 1, want: 1, net: 1},
+		{name: "taker fee", buy: buy, sell: sell, bs: fee, ss: fee, amount: 1, want: 1, net: 101*0.999 - 100*1.001},
+		{name: "withdraw fee", buy: buy, sell: sell, bs: withdraw, ss: free, amount: 1, want: 1, net: 0},
+		{name: "withdraw fee of sell source", buy: buy, sell: sell, bs: free, ss: withdraw, amount: 1, want: 1, net: 1},
+		{name: "amount from depth", buy: buy, sell: sell, bs: free, ss: free, want: 2, net: 2},
+		{name: "no ask", buy: &Quote{Bid: 99}, sell: sell, bs: free, ss: free, nil: true},
+		{name: "no bid", buy: buy, sell: &Quote{Ask: 102}, bs: free, ss: free, nil: true},
+		{name: "no depth", buy: &Quote{Ask: 100}, sell: sell, bs: free, ss: free, nil: true},
+	} {
+		o := Evaluate(c.buy, c.sell, c.bs, c.ss, c.amount)
+		if c.nil {
+			if o != nil {
+				t.Errorf("%s: opportunity %+v, want nil", c.name, o)
+			}
+			continue
+		}
+		if o == nil {
+			t.Errorf("%s: opportunity is nil", c.name)
+			continue
+		}
+		if o.Amount != c.want || !almostEqual(o.Net, c.net) || !almostEqual(o.NetPercent, o.Net/o.Cost*100) {
+			t.Errorf("%s: amount %v net %v (%v%%), want %v and %v", c.name, o.Amount, o.Net, o.NetPercent, c.want, c.net)
+		}
+	}
+}
+
+// 使用设置的来源，不按配置重新创建
+func newTestMonitor(cfg *model.Configuration, sources ...*fakeSource) *Monitor {
+	m := NewMonitor(event.New(0))
+	m.sources = make([]*source, 0, len(sources))
+	for i, v := range sources {
+		m.sources = append(m.sources, &source{cfg: cfg.Arbitrage.Sources[i], Source: v})
+	}
+	m.sourceConfigs = cfg.Arbitrage.Sources
+	m.timeout = cfg.RequestTimeout
+	return m
+}
+
+//
+func TestMonitorScan(t *testing.T) {
+	var (
+		now   = time.Now()
+		stale = now.Add(-time.Hour)
+		// 在a买入并在b卖出，扣除手续费后收益约0.8%
+		cheap = map[string]*Quote{"btcusdt": {Source: "a", Symbol: "btcusdt", Bid: 99, BidAmount: 1, Ask: 100, AskAmount: 1, Time: now}}
+		dear  = map[string]*Quote{"btcusdt": {Source: "b", Symbol: "btcusdt", Bid: 101, BidAmount: 1, Ask: 102, AskAmount: 1, Time: now}}
+		old   = map[string]*Quote{"btcusdt": {Source: "b", Symbol: "btcusdt", Bid: 101, BidAmount: 1, Ask: 102, AskAmount: 1, Time: stale}}
+	)
+
+	for _, c := range []struct {
+		name      string
+		cfg       model.ArbitrageConfig
+		a         map[string]*Quote
+		b         map[string]*Quote
+		withdraw  float64
+		buy, sell string
+	}{
+		{name: "above threshold", cfg: model.ArbitrageConfig{Threshold: 0.5}, a: cheap, b: dear, buy: "a", sell: "b"},
+		{name: "below threshold", cfg: model.ArbitrageConfig{Threshold: 1}, a: cheap, b: dear},
+		{name: "withdraw fee", cfg: model.ArbitrageConfig{Threshold: 0.5}, a: cheap, b: dear, withdraw: 0.01},
+		{name: "stale quote", cfg: model.ArbitrageConfig{Threshold: 0.5, MaxQuoteAge: 1000}, a: cheap, b: old},
+		{name: "stale quote not checked", cfg: model.ArbitrageConfig{Threshold: 0.5}, a: cheap, b: old, buy: "a", sell: "b"},
+		{name: "quote error", cfg: model.ArbitrageConfig{Threshold: 0.5}, a: cheap, b: map[string]*Quote{}},
+	} {
+		cfg := &model.Configuration{Arbitrage: c.cfg}
+		cfg.Arbitrage.Symbols = []string{"btcusdt"}
+		cfg.Arbitrage.Sources = []*model.ArbitrageSource{
+			{Name: "a", TakerFee: 0.001, WithdrawFees: map[string]float64{"btcusdt": c.withdraw}},
+			{Name: "b", TakerFee: 0.001},
+		}
+		m := newTestMonitor(cfg, &fakeSource{name: "a", quotes: c.a}, &fakeSource{name: "b", quotes: c.b})
+
+		list := m.Scan(cfg)
+		if c.buy == "" {
+			if len(list) != 0 {
+				t.Errorf("%s: %d opportunities, want none", c.name, len(list))
+			}
+			continue
+		}
+		if len(list) != 1 {
+			t.Errorf("%s: %d opportunities, want 1", c.name, len(list))
+			continue
+		}
+		if o := list[0]; o.Buy != c.buy || o.Sell != c.sell || o.Amount != 1 {
+			t.Errorf("%s: opportunity %+v, want buy at %s and sell at %s", c.name, o, c.buy, c.sell)
+		}
+		if len(m.Opportunities()) != 1 {
+			t.Errorf("%s: %d opportunities kept", c.name, len(m.Opportunities()))
+		}
+	}
+}
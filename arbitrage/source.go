@@ -0,0 +1,134 @@
+package arbitrage
+
+import (
+	"encoding/json"
+	"fcoinExchange/fcoin"
+	"fcoinExchange/model"
+	"fcoinExchange/venue"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// 一个来源的买一卖一
+type Quote struct {
+	Source    string    `json:"source"`
+	Symbol    string    `json:"symbol"`
+	Bid       float64   `json:"bid"`
+	BidAmount float64   `json:"bid_amount"`
+	Ask       float64   `json:"ask"`
+	AskAmount float64   `json:"ask_amount"`
+	Time      time.Time `json:"time"`
+}
+
+// 行情来源
+type Source interface {
+	Name() string
+	Quote(symbol string) (*Quote, error)
+}
+
+// 按配置创建行情来源，交易所来源只使用公开接口，不需要账户密钥
+func NewSource(cfg *model.ArbitrageSource, timeout int) (Source, error) {
+	switch cfg.Venue {
+	case "fcoin":
+		return &venueSource{
+			name:  cfg.Name,
+			venue: venue.NewFCoin(fcoin.NewClient("", "", timeout)),
+		}, nil
+	case "binance":
+		return &venueSource{
+			name:  cfg.Name,
+			venue: venue.NewBinance(cfg.VenueURL, "", "", timeout),
+		}, nil
+	case "feed":
+		return &feedSource{name: cfg.Name, path: cfg.Path}, nil
+	}
+	return nil, fmt.Errorf("unknown venue %s of source %s", cfg.Venue, cfg.Name)
+}
+
+// 交易所的ticker
+type venueSource struct {
+	name  string
+	venue venue.Venue
+}
+
+//
+func (p *venueSource) Name() string {
+	return p.name
+}
+
+//
+func (p *venueSource) Quote(symbol string) (*Quote, error) {
+	q, err := p.venue.Ticker(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return &Quote{
+		Source:    p.name,
+		Symbol:    symbol,
+		Bid:       q.MaxBuyOnePrice,
+		BidAmount: q.MaxBuyNumber,
+		Ask:       q.MinSellOnePrice,
+		AskAmount: q.MinSellNumber,
+		Time:      time.Now(),
+	}, nil
+}
+
+// 本地json文件，由其他程序定时写入，每次查询时重新读取
+type feedSource struct {
+	name string
+	path string
+}
+
+// 文件中的一个交易对，time为毫秒时间戳
+type feedQuote struct {
+	Bid       float64 `json:"bid"`
+	BidAmount float64 `json:"bid_amount"`
+	Ask       float64 `json:"ask"`
+	AskAmount float64 `json:"ask_amount"`
+	Time      int64   `json:"time"`
+}
+
+//
+func (p *feedSource) Name() string {
+	return p.name
+}
+
+// 文件中没有time时使用文件的修改时间
+func (p *feedSource) Quote(symbol string) (*Quote, error) {
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var quotes = make(map[string]*feedQuote)
+	err = json.Unmarshal(data, &quotes)
+	if err != nil {
+		return nil, fmt.Errorf("parse feed %s failed. %s", p.path, err)
+	}
+
+	fq, ok := quotes[symbol]
+	if !ok || fq == nil {
+		return nil, fmt.Errorf("feed %s has no %s quote", p.path, symbol)
+	}
+
+	var t = time.Unix(0, fq.Time*int64(time.Millisecond))
+	if fq.Time == 0 {
+		fi, err := os.Stat(p.path)
+		if err != nil {
+			return nil, err
+		}
+		t = fi.ModTime()
+	}
+
+	return &Quote{
+		Source:    p.name,
+		Symbol:    symbol,
+		Bid:       fq.Bid,
+		BidAmount: fq.BidAmount,
+		Ask:       fq.Ask,
+		AskAmount: fq.AskAmount,
+		Time:      t,
+	}, nil
+}
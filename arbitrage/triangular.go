@@ -0,0 +1,459 @@
+package arbitrage
+
+import (
+	"encoding/json"
+	"fcoinExchange/conf"
+	"fcoinExchange/event"
+	"fcoinExchange/fcoin"
+	"fcoinExchange/health"
+	"fcoinExchange/log"
+	"fcoinExchange/metrics"
+	"fcoinExchange/model"
+	"fcoinExchange/notify"
+	"fcoinExchange/venue"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// 重新查询交易对列表的时间间隔
+	symbolsRefresh = time.Hour
+	// 同时查询行情的请求数量
+	fetchWorkers = 4
+	// interval不大于0时检查配置是否重新开启的时间间隔
+	pausedPoll = time.Minute
+)
+
+// 三角套利中的一笔交易，Amount为base currency数量
+type Leg struct {
+	Symbol string  `json:"symbol"`
+	Side   string  `json:"side"`
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"`
+}
+
+// 从Start币种出发经过三个交易对回到Start，
+// 已按交易对精度向下取整并扣除每一笔的手续费
+type Cycle struct {
+	Start         string    `json:"start"`
+	Currencies    []string  `json:"currencies"`
+	Legs          []*Leg    `json:"legs"`
+	StartAmount   float64   `json:"start_amount"`
+	EndAmount     float64   `json:"end_amount"`
+	Profit        float64   `json:"profit"`
+	ProfitPercent float64   `json:"profit_percent"`
+	Time          time.Time `json:"time"`
+}
+
+//
+func (p *Cycle) Key() string {
+	return strings.Join(p.Currencies, ">")
+}
+
+// 三个币种组成的环，symbols[i]连接currencies[i]和currencies[i+1]
+type path struct {
+	currencies []string
+	symbols    []*model.Symbol
+}
+
+// 交易对组成的币种图
+type graph map[string]map[string]*model.Symbol
+
+// 按交易对列表创建币种图，currencies不为空时只保留这些币种之间的交易对
+func newGraph(symbols []*model.Symbol, currencies []string) graph {
+	var allowed = make(map[string]bool)
+	for _, v := range currencies {
+		allowed[v] = true
+	}
+
+	var g = make(graph)
+	for _, s := range symbols {
+		if len(allowed) > 0 && (!allowed[s.BaseCurrency] || !allowed[s.QuoteCurrency]) {
+			continue
+		}
+		g.add(s.BaseCurrency, s.QuoteCurrency, s)
+		g.add(s.QuoteCurrency, s.BaseCurrency, s)
+	}
+	return g
+}
+
+//
+func (p graph) add(from, to string, s *model.Symbol) {
+	if p[from] == nil {
+		p[from] = make(map[string]*model.Symbol)
+	}
+	p[from][to] = s
+}
+
+// 从start出发的所有三角环，两个方向分别返回
+func (p graph) cycles(start string) []*path {
+	var list = make([]*path, 0)
+	for b, s1 := range p[start] {
+		for c, s2 := range p[b] {
+			if c == start {
+				continue
+			}
+			s3, ok := p[c][start]
+			if !ok {
+				continue
+			}
+			list = append(list, &path{
+				currencies: []string{start, b, c, start},
+				symbols:    []*model.Symbol{s1, s2, s3},
+			})
+		}
+	}
+	return list
+}
+
+// 按精度向下取整
+func floor(v float64, decimal int) float64 {
+	pow := math.Pow10(decimal)
+	return math.Floor(v*pow+1e-9) / pow
+}
+
+// 从amount个from币种兑换为to币种，返回这一笔交易、得到的数量和盘口可成交的base currency数量，
+// 取整后数量为0或者没有报价时返回nil
+func convert(from string, s *model.Symbol, q *Quote, amount, fee float64) (*Leg, float64, float64) {
+	if s.QuoteCurrency == from {
+		if q.Ask <= 0 {
+			return nil, 0, 0
+		}
+		base := floor(amount/q.Ask, s.AmountDecimal)
+		if base <= 0 {
+			return nil, 0, 0
+		}
+		return &Leg{Symbol: s.Name, Side: "buy", Price: q.Ask, Amount: base}, base * (1 - fee), q.AskAmount
+	}
+
+	if q.Bid <= 0 {
+		return nil, 0, 0
+	}
+	base := floor(amount, s.AmountDecimal)
+	if base <= 0 {
+		return nil, 0, 0
+	}
+	return &Leg{Symbol: s.Name, Side: "sell", Price: q.Bid, Amount: base}, base * q.Bid * (1 - fee), q.BidAmount
+}
+
+// 按顺序模拟三笔交易，返回结果和盘口允许的起始数量比例，小于1时需要减少起始数量
+func simulate(pt *path, quotes map[string]*Quote, amount, fee float64) (*Cycle, float64) {
+	var (
+		c = &Cycle{
+			Start:       pt.currencies[0],
+			Currencies:  pt.currencies,
+			Legs:        make([]*Leg, 0, len(pt.symbols)),
+			StartAmount: amount,
+		}
+		have  = amount
+		ratio = 1.0
+	)
+	for i, s := range pt.symbols {
+		q, ok := quotes[s.Name]
+		if !ok || q == nil {
+			return nil, 0
+		}
+		leg, got, depth := convert(pt.currencies[i], s, q, have, fee)
+		if leg == nil {
+			return nil, 0
+		}
+		if r := depth / leg.Amount; r < ratio {
+			ratio = r
+		}
+		c.Legs = append(c.Legs, leg)
+		have = got
+	}
+
+	// 取整剩余的零头不计入收益
+	c.EndAmount = have
+	c.Profit = have - amount
+	c.ProfitPercent = c.Profit / amount * 100
+	c.Time = time.Now()
+	return c, ratio
+}
+
+// 按盘口数量限制起始数量后计算收益
+func evaluate(pt *path, quotes map[string]*Quote, amount, fee float64) *Cycle {
+	c, ratio := simulate(pt, quotes, amount, fee)
+	if c == nil || ratio >= 1 {
+		return c
+	}
+	c, _ = simulate(pt, quotes, amount*ratio, fee)
+	return c
+}
+
+// 单个交易所内的三角套利扫描。
+// 按交易对列表建立币种图，每次检查查询环上所有交易对的买一卖一，
+// 收益超过阈值时记录日志、告警，并在事件总线上发布event.TriangularOpportunity
+type Scanner struct {
+	bus    *event.Bus
+	logger *zap.SugaredLogger
+
+	sync.RWMutex
+	venue      venue.Venue
+	venueKey   string
+	paths      []*path
+	loadedAt   time.Time
+	quotes     map[string]*Quote
+	cycles     []*Cycle
+	currencies []string
+}
+
+//
+func NewScanner(bus *event.Bus) *Scanner {
+	return &Scanner{
+		bus:    bus,
+		logger: log.Named("triangular"),
+		quotes: make(map[string]*Quote),
+	}
+}
+
+// 按配置定时检查，interval不大于0时暂停，配置重新设置interval后恢复
+func (p *Scanner) Run(r *health.Run) {
+	defer notify.Recover("", "triangular")
+
+	p.logger.Infof("start triangular arbitrage scanner task")
+	for {
+		cfg := conf.GetConfiguration()
+		interval := cfg.Arbitrage.Triangular.Interval
+		if interval > 0 {
+			r.Beat()
+			_, err := p.Scan(cfg)
+			if err != nil {
+				p.logger.Errorf("scan failed. %s", err)
+			}
+		} else {
+			// 暂停期间不判断为卡住
+			r.Idle()
+		}
+
+		if !wait(r, interval) {
+			return
+		}
+	}
+}
+
+// 等待下次检查，interval不大于0时等待pausedPoll后重新读取配置，任务被取消时返回false
+func wait(r *health.Run, interval int64) bool {
+	var d = pausedPoll
+	if interval > 0 {
+		if interval < minInterval {
+			interval = minInterval
+		}
+		d = time.Duration(interval) * time.Millisecond
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Done():
+		return false
+	}
+}
+
+// 交易所配置变化、币种变化或者超过symbolsRefresh时重新查询交易对并查找所有三角环
+func (p *Scanner) load(cfg *model.Configuration) (venue.Venue, []*path, error) {
+	tc := cfg.Arbitrage.Triangular
+
+	p.Lock()
+	defer p.Unlock()
+	key := fmt.Sprintf("%s/%s/%d", tc.Venue, tc.VenueURL, cfg.RequestTimeout)
+	if key != p.venueKey {
+		switch tc.Venue {
+		case "binance":
+			p.venue = venue.NewBinance(tc.VenueURL, "", "", cfg.RequestTimeout)
+		default:
+			p.venue = venue.NewFCoin(fcoin.NewClient("", "", cfg.RequestTimeout))
+		}
+		p.venueKey = key
+		p.paths = nil
+	}
+
+	var starts = make([]string, 0, len(tc.MaxAmounts))
+	for k := range tc.MaxAmounts {
+		starts = append(starts, k)
+	}
+	sort.Strings(starts)
+	var currencies = append(append([]string{}, starts...), tc.Currencies...)
+
+	if p.paths != nil && time.Since(p.loadedAt) < symbolsRefresh && strings.Join(currencies, ",") == strings.Join(p.currencies, ",") {
+		return p.venue, p.paths, nil
+	}
+
+	symbols, err := p.venue.Symbols()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 只配置了起始币种时使用所有币种
+	var allowed []string
+	if len(tc.Currencies) > 0 {
+		allowed = currencies
+	}
+	g := newGraph(symbols, allowed)
+
+	var paths = make([]*path, 0)
+	for _, start := range starts {
+		paths = append(paths, g.cycles(start)...)
+	}
+	p.logger.Infow("currency graph loaded", "symbols", len(symbols), "cycles", len(paths))
+
+	p.paths = paths
+	p.loadedAt = time.Now()
+	p.currencies = currencies
+	return p.venue, paths, nil
+}
+
+// 查询所有环上交易对的行情并返回收益超过阈值的三角环，按收益百分比从高到低排列
+func (p *Scanner) Scan(cfg *model.Configuration) ([]*Cycle, error) {
+	tc := cfg.Arbitrage.Triangular
+	v, paths, err := p.load(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var names = make(map[string]bool)
+	for _, pt := range paths {
+		for _, s := range pt.symbols {
+			names[s.Name] = true
+		}
+	}
+	quotes := p.fetch(v, names)
+
+	var list = make([]*Cycle, 0)
+	for _, pt := range paths {
+		c := evaluate(pt, quotes, tc.MaxAmounts[pt.currencies[0]], tc.TakerFee)
+		if c == nil || c.Profit <= 0 || c.ProfitPercent < tc.Threshold {
+			continue
+		}
+		list = append(list, c)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ProfitPercent > list[j].ProfitPercent
+	})
+
+	p.Lock()
+	p.quotes = quotes
+	p.cycles = list
+	p.Unlock()
+
+	for _, c := range list {
+		p.report(v.Name(), c)
+	}
+	return list, nil
+}
+
+// 使用fetchWorkers个并发请求查询行情，查询失败的交易对不在结果中
+func (p *Scanner) fetch(v venue.Venue, names map[string]bool) map[string]*Quote {
+	var (
+		quotes = make(map[string]*Quote)
+		ch     = make(chan string)
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+	)
+	for i := 0; i < fetchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for symbol := range ch {
+				q, err := v.Ticker(symbol)
+				if err != nil {
+					metrics.Inc("triangular", "quote_error", symbol)
+					p.logger.Warnw("get quote failed", log.FieldSymbol, symbol, "error", err)
+					continue
+				}
+				mu.Lock()
+				quotes[symbol] = &Quote{
+					Source:    v.Name(),
+					Symbol:    symbol,
+					Bid:       q.MaxBuyOnePrice,
+					BidAmount: q.MaxBuyNumber,
+					Ask:       q.MinSellOnePrice,
+					AskAmount: q.MinSellNumber,
+					Time:      time.Now(),
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for symbol := range names {
+		ch <- symbol
+	}
+	close(ch)
+	wg.Wait()
+	return quotes
+}
+
+//
+func (p *Scanner) report(name string, c *Cycle) {
+	metrics.Inc("triangular", "opportunity", strings.Join(c.Currencies, "_"))
+
+	var legs = make([]string, 0, len(c.Legs))
+	for _, v := range c.Legs {
+		legs = append(legs, fmt.Sprintf("%s %s %.8f at %.8f", v.Side, v.Symbol, v.Amount, v.Price))
+	}
+	p.logger.Infow("triangular arbitrage opportunity",
+		"cycle", c.Key(),
+		"legs", legs,
+		"start_amount", c.StartAmount,
+		"end_amount", c.EndAmount,
+		"profit_percent", c.ProfitPercent,
+	)
+
+	notify.Send(&notify.Event{
+		Kind:     notify.KindArbitrage,
+		Severity: notify.SeverityInfo,
+		Key:      "triangular/" + c.Key(),
+		Title:    fmt.Sprintf("%s triangular arbitrage %s %.4f%%", name, c.Key(), c.ProfitPercent),
+		Message: fmt.Sprintf("%s, %.8f %s -> %.8f %s",
+			strings.Join(legs, ", "), c.StartAmount, c.Start, c.EndAmount, c.Start),
+	})
+
+	var legEvents = make([]event.Leg, 0, len(c.Legs))
+	for _, v := range c.Legs {
+		legEvents = append(legEvents, event.Leg{Symbol: v.Symbol, Side: v.Side, Price: v.Price, Amount: v.Amount})
+	}
+	p.bus.Publish(&event.TriangularOpportunity{
+		Venue:         name,
+		Currencies:    c.Currencies,
+		Legs:          legEvents,
+		StartAmount:   c.StartAmount,
+		ProfitPercent: c.ProfitPercent,
+		Time:          c.Time,
+	})
+}
+
+// 最近一次检查的三角环
+func (p *Scanner) Cycles() []*Cycle {
+	p.RLock()
+	defer p.RUnlock()
+	return p.cycles
+}
+
+// /arbitrage/triangular，返回最近一次检查的行情和三角环
+func (p *Scanner) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.RLock()
+		data, err := json.Marshal(map[string]interface{}{
+			"quotes": p.quotes,
+			"cycles": p.cycles,
+		})
+		p.RUnlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
@@ -0,0 +1,223 @@
+package arbitrage
+
+import (
+	"fcoinExchange/log"
+	"fcoinExchange/model"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	log.Logger = log.Named("test")
+	os.Exit(m.Run())
+}
+
+var (
+	btcusdt = &model.Symbol{Name: "btcusdt", BaseCurrency: "btc", QuoteCurrency: "usdt", PriceDecimal: 2, AmountDecimal: 4}
+	ethbtc  = &model.Symbol{Name: "ethbtc", BaseCurrency: "eth", QuoteCurrency: "btc", PriceDecimal: 6, AmountDecimal: 3}
+	ethusdt = &model.Symbol{Name: "ethusdt", BaseCurrency: "eth", QuoteCurrency: "usdt", PriceDecimal: 2, AmountDecimal: 3}
+	// 不在任何三角环上
+	ftusdt = &model.Symbol{Name: "ftusdt", BaseCurrency: "ft", QuoteCurrency: "usdt", PriceDecimal: 6, AmountDecimal: 2}
+)
+
+//
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// 所有环的币种顺序，排序后用于比较
+func cycleKeys(paths []*path) []string {
+	var keys = make([]string, 0, len(paths))
+	for _, v := range paths {
+		keys = append(keys, strings.Join(v.currencies, ">"))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//
+func TestGraphCycles(t *testing.T) {
+	symbols := []*model.Symbol{btcusdt, ethbtc, ethusdt, ftusdt}
+
+	for _, c := range []struct {
+		name       string
+		currencies []string
+		start      string
+		want       []string
+	}{
+		{name: "both directions", start: "usdt", want: []string{"usdt>btc>eth>usdt", "usdt>eth>btc>usdt"}},
+		{name: "start from base", start: "eth", want: []string{"eth>btc>usdt>eth", "eth>usdt>btc>eth"}},
+		{name: "allowed currencies", currencies: []string{"usdt", "btc", "eth"}, start: "usdt", want: []string{"usdt>btc>eth>usdt", "usdt>eth>btc>usdt"}},
+		{name: "currency not allowed", currencies: []string{"usdt", "btc", "ft"}, start: "usdt", want: []string{}},
+		{name: "no cycle", start: "ft", want: []string{}},
+	} {
+		g := newGraph(symbols, c.currencies)
+		got := cycleKeys(g.cycles(c.start))
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s: cycles %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// 每一步的交易对连接相邻的两个币种
+	for _, pt := range newGraph(symbols, nil).cycles("usdt") {
+		for i, s := range pt.symbols {
+			a, b := pt.currencies[i], pt.currencies[i+1]
+			if !(s.BaseCurrency == a && s.QuoteCurrency == b) && !(s.BaseCurrency == b && s.QuoteCurrency == a) {
+				t.Errorf("symbol %s does not connect %s and %s", s.Name, a, b)
+			}
+		}
+	}
+}
+
+//
+func TestConvert(t *testing.T) {
+	var quote = &Quote{Bid: 99, BidAmount: 3, Ask: 100, AskAmount: 2}
+
+	for _, c := range []struct {
+		name   string
+		from   string
+		quote  *Quote
+		amount float64
+		fee    float64
+		leg    *Leg
+		got    float64
+		depth  float64
+	}{
+		{
+			name: "buy with quote currency", from: "usdt", quote: quote, amount: 150,
+			leg: &Leg{Symbol: "btcusdt", Side: "buy", Price: 100, Amount: 1.5}, got: 1.5, depth: 2,
+		},
+		{
+			name: "buy pays fee in base", from: "usdt", quote: quote, amount: 150, fee: 0.001,
+			leg: &Leg{Symbol: "btcusdt", Side: "buy", Price: 100, Amount: 1.5}, got: 1.4985, depth: 2,
+		},
+		{
+			name: "buy amount rounded down", from: "usdt", quote: quote, amount: 123.456789,
+			leg: &Leg{Symbol: "btcusdt", Side: "buy", Price: 100, Amount: 1.2345}, got: 1.2345, depth: 2,
+		},
+		{
+			name: "sell base currency", from: "btc", quote: quote, amount: 1.23456, fee: 0.001,
+			leg: &Leg{Symbol: "btcusdt", Side: "sell", Price: 99, Amount: 1.2345}, got: 1.2345 * 99 * 0.999, depth: 3,
+		},
+		{name: "buy rounded to zero", from: "usdt", quote: quote, amount: 0.001},
+		{name: "sell rounded to zero", from: "btc", quote: quote, amount: 0.00009},
+		{name: "no ask", from: "usdt", quote: &Quote{Bid: 99, BidAmount: 3}, amount: 150},
+		{name: "no bid", from: "btc", quote: &Quote{Ask: 100, AskAmount: 2}, amount: 1},
+	} {
+		leg, got, depth := convert(c.from, btcusdt, c.quote, c.amount, c.fee)
+		if c.leg == nil {
+			if leg != nil {
+				t.Errorf("%s: leg %+v, want nil", c.name, leg)
+			}
+			continue
+		}
+		if leg == nil {
+			t.Errorf("%s: leg is nil", c.name)
+			continue
+		}
+		if leg.Symbol != c.leg.Symbol || leg.Side != c.leg.Side || leg.Price != c.leg.Price || !almostEqual(leg.Amount, c.leg.Amount) {
+			t.Errorf("%s: leg %+v, want %+v", c.name, leg, c.leg)
+		}
+		if !almostEqual(got, c.got) || depth != c.depth {
+			t.Errorf("%s: got %v depth %v, want %v and %v", c.name, got, depth, c.got, c.depth)
+		}
+	}
+}
+
+//
+func TestEvaluate(t *testing.T) {
+	var (
+		// usdt -> btc -> eth -> usdt
+		pt = &path{
+			currencies: []string{"usdt", "btc", "eth", "usdt"},
+			symbols:    []*model.Symbol{btcusdt, ethbtc, ethusdt},
+		}
+		// 1000 usdt买入10 btc，买入200 eth，卖出1040 usdt
+		quotes = map[string]*Quote{
+			"btcusdt": {Bid: 99, BidAmount: 100, Ask: 100, AskAmount: 100},
+			"ethbtc":  {Bid: 0.049, BidAmount: 1000, Ask: 0.05, AskAmount: 1000},
+			"ethusdt": {Bid: 5.2, BidAmount: 1000, Ask: 5.3, AskAmount: 1000},
+		}
+		// ethbtc卖一只有100 eth，只能使用一半的起始数量
+		shallow = map[string]*Quote{
+			"btcusdt": quotes["btcusdt"],
+			"ethbtc":  {Bid: 0.049, BidAmount: 1000, Ask: 0.05, AskAmount: 100},
+			"ethusdt": quotes["ethusdt"],
+		}
+		missing = map[string]*Quote{
+			"btcusdt": quotes["btcusdt"],
+			"ethusdt": quotes["ethusdt"],
+		}
+	)
+
+	for _, c := range []struct {
+		name    string
+		quotes  map[string]*Quote
+		amount  float64
+		fee     float64
+		nil     bool
+		start   float64
+		end     float64
+		amounts []float64
+	}{
+		{name: "no fee", quotes: quotes, amount: 1000, start: 1000, end: 1040, amounts: []float64{10, 200, 200}},
+		{
+			// 9.99 btc -> 199.8 eth，扣除手续费后199.6002取整为199.6
+			name: "fee and rounding", quotes: quotes, amount: 1000, fee: 0.001,
+			start: 1000, end: 199.6 * 5.2 * 0.999, amounts: []float64{10, 199.8, 199.6},
+		},
+		{name: "limited by depth", quotes: shallow, amount: 1000, start: 500, end: 520, amounts: []float64{5, 100, 100}},
+		{name: "enough depth", quotes: shallow, amount: 400, start: 400, end: 416, amounts: []float64{4, 80, 80}},
+		{name: "missing quote", quotes: missing, amount: 1000, nil: true},
+		{name: "amount too small", quotes: quotes, amount: 0.001, nil: true},
+	} {
+		cy := evaluate(pt, c.quotes, c.amount, c.fee)
+		if c.nil {
+			if cy != nil {
+				t.Errorf("%s: cycle %+v, want nil", c.name, cy)
+			}
+			continue
+		}
+		if cy == nil {
+			t.Errorf("%s: cycle is nil", c.name)
+			continue
+		}
+		if !almostEqual(cy.StartAmount, c.start) || !almostEqual(cy.EndAmount, c.end) {
+			t.Errorf("%s: start %v end %v, want %v and %v", c.name, cy.StartAmount, cy.EndAmount, c.start, c.end)
+		}
+		if !almostEqual(cy.Profit, c.end-c.start) || !almostEqual(cy.ProfitPercent, (c.end-c.start)/c.start*100) {
+			t.Errorf("%s: profit %v (%v%%)", c.name, cy.Profit, cy.ProfitPercent)
+		}
+		if len(cy.Legs) != len(c.amounts) {
+			t.Errorf("%s: %d legs, want %d", c.name, len(cy.Legs), len(c.amounts))
+			continue
+		}
+		for i, v := range c.amounts {
+			if !almostEqual(cy.Legs[i].Amount, v) {
+				t.Errorf("%s: leg %d amount %v, want %v", c.name, i, cy.Legs[i].Amount, v)
+			}
+		}
+	}
+}
+
+//
+func TestSimulateRatio(t *testing.T) {
+	pt := &path{
+		currencies: []string{"usdt", "btc", "eth", "usdt"},
+		symbols:    []*model.Symbol{btcusdt, ethbtc, ethusdt},
+	}
+	quotes := map[string]*Quote{
+		"btcusdt": {Ask: 100, AskAmount: 5},
+		"ethbtc":  {Ask: 0.05, AskAmount: 1000},
+		"ethusdt": {Bid: 5.2, BidAmount: 50},
+	}
+
+	// btcusdt允许一半，ethusdt只允许四分之一，取较小的比例
+	_, ratio := simulate(pt, quotes, 1000, 0)
+	if !almostEqual(ratio, 0.25) {
+		t.Errorf("ratio %v, want 0.25", ratio)
+	}
+}
@@ -0,0 +1,81 @@
+// 生成credential.source为keystore时使用的加密密钥文件
+//
+//	go run ./cmd/keystore -out /path/to/fcoin.keystore
+//
+// 在终端输入appkey、appsecret和口令，口令需输入两次
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fcoinExchange/secret"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+func main() {
+	out := flag.String("out", "", "keystore file to write, must not exist")
+	flag.Parse()
+
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "usage: keystore -out <file>")
+		os.Exit(2)
+	}
+	if err := run(*out); err != nil {
+		fmt.Fprintf(os.Stderr, "seal keystore failed. %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("keystore written to %s\n", *out)
+}
+
+//
+func run(out string) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("stdin is not a terminal")
+	}
+
+	fmt.Printf("appkey: ")
+	key, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	appSecret, err := readPassword("appsecret: ")
+	if err != nil {
+		return err
+	}
+	passphrase, err := readPassword("keystore passphrase: ")
+	if err != nil {
+		return err
+	}
+	confirm, err := readPassword("repeat passphrase: ")
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case strings.TrimSpace(key) == "" || len(appSecret) == 0:
+		return fmt.Errorf("appkey and appsecret must not be empty")
+	case len(passphrase) == 0:
+		return fmt.Errorf("passphrase must not be empty")
+	case !bytes.Equal(passphrase, confirm):
+		return fmt.Errorf("passphrases do not match")
+	}
+
+	c := &secret.Credential{
+		AppKey:    strings.TrimSpace(key),
+		AppSecret: string(appSecret),
+	}
+	return secret.WriteKeystore(out, c, passphrase)
+}
+
+// 在终端输入，不回显
+func readPassword(prompt string) ([]byte, error) {
+	fmt.Printf("%s", prompt)
+	defer fmt.Printf("\n")
+	return term.ReadPassword(int(os.Stdin.Fd()))
+}
@@ -2,13 +2,19 @@ package conf
 
 import (
 	"fcoinExchange/model"
+	"fcoinExchange/schedule"
+	"fcoinExchange/secret"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"gopkg.in/yaml.v2"
 )
 
@@ -16,8 +22,9 @@ import (
 
 //
 var (
-	defaultPath string = "fcoin.yaml"
-	cfg         *config
+	defaultPath    string = "fcoin.yaml"
+	defaultAccount string = "default"
+	cfg            *config
 )
 
 func Init() {
@@ -42,7 +49,7 @@ func NewConfiguration() *model.Configuration {
 	return &model.Configuration{}
 }
 
-//
+// 返回当前配置的快照，快照发布后不会再被修改，调用者不应修改其内容
 func GetConfiguration() *model.Configuration {
 	return cfg.configuration()
 }
@@ -56,8 +63,95 @@ func SetReloadInterval(i int) {
 	cfg.intervalChan <- i
 }
 
+// 返回每个账户合并顶层配置后的配置，未配置accounts时只返回顶层配置
+func Accounts(c *model.Configuration) []*model.Configuration {
+	if len(c.Accounts) == 0 {
+		a := *c
+		if a.Name == "" {
+			a.Name = defaultAccount
+		}
+		return []*model.Configuration{&a}
+	}
+
+	var list = make([]*model.Configuration, 0, len(c.Accounts))
+	for _, v := range c.Accounts {
+		list = append(list, mergeAccount(c, v))
+	}
+	return list
+}
+
+// 返回指定账户的配置，账户不存在时返回nil
+func Account(c *model.Configuration, name string) *model.Configuration {
+	for _, v := range Accounts(c) {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// 返回账户的交易对配置，未配置symbols时使用symbol，交易对中未设置的项使用账户配置的值
+func Symbols(c *model.Configuration) []*model.SymbolConfig {
+	if len(c.Symbols) == 0 {
+		return []*model.SymbolConfig{{
+			Symbol:         c.Symbol,
+			SellNumber:     c.SellNumber,
+			MakeUpPercent:  c.MakeUpPercent,
+			BalancePercent: c.BalancePercent,
+			ExpectValue:    c.ExpectValue,
+			OrderPolicies:  orderPolicies(c),
+			Rebalance:      c.Rebalance,
+		}}
+	}
+
+	var list = make([]*model.SymbolConfig, 0, len(c.Symbols))
+	for _, v := range c.Symbols {
+		sc := *v
+		if sc.SellNumber == 0 {
+			sc.SellNumber = c.SellNumber
+		}
+		if sc.MakeUpPercent == 0 {
+			sc.MakeUpPercent = c.MakeUpPercent
+		}
+		if sc.BalancePercent == 0 {
+			sc.BalancePercent = c.BalancePercent
+		}
+		if sc.ExpectValue == 0 {
+			sc.ExpectValue = c.ExpectValue
+		}
+		if len(sc.OrderPolicies) == 0 {
+			sc.OrderPolicies = orderPolicies(c)
+		}
+		if sc.Rebalance == nil {
+			sc.Rebalance = c.Rebalance
+		}
+		list = append(list, &sc)
+	}
+	return list
+}
+
+// 未配置订单处理策略时，订单存在时间超过revoke_order_time后撤单
+func orderPolicies(c *model.Configuration) []*model.OrderPolicyConfig {
+	if len(c.OrderPolicies) > 0 {
+		return c.OrderPolicies
+	}
+	return []*model.OrderPolicyConfig{{Type: "timeout", After: c.RevokeOrderTime}}
+}
+
+// 订阅配置变更，每次配置文件重新加载并且内容发生变化时，
+// 返回的channel会收到一次包含新旧配置的通知
+func Watch() <-chan *Change {
+	return cfg.watch()
+}
+
 //
 
+// 配置变更通知
+type Change struct {
+	Old *model.Configuration
+	New *model.Configuration
+}
+
 // 定义config用于操作配置文件
 type config struct {
 	intervalChan chan int
@@ -66,7 +160,17 @@ type config struct {
 	interval int
 	path     string
 	cfg      *model.Configuration
+	watchers []chan *Change
 	sync.RWMutex
+
+	// 密钥只在启动或者密钥来源配置变化时读取
+	creds map[string]*credential
+}
+
+// 已读取的账户密钥
+type credential struct {
+	cfg  model.CredentialConfig
+	cred *secret.Credential
 }
 
 //
@@ -74,6 +178,7 @@ func (p *config) init() {
 	p.intervalChan = make(chan int, 1)
 	p.errorChan = make(chan error, 1000)
 	p.cfg = NewConfiguration()
+	p.creds = make(map[string]*credential)
 }
 
 //
@@ -96,6 +201,15 @@ func (p *config) SetReloadInterval(i int) {
 }
 
 //
+func (p *config) watch() <-chan *Change {
+	p.Lock()
+	defer p.Unlock()
+	w := make(chan *Change, 1)
+	p.watchers = append(p.watchers, w)
+	return w
+}
+
+// 解析配置文件到新的配置对象，校验通过后再替换当前配置
 func (p *config) parse() error {
 	p.RLock()
 	path := p.path
@@ -105,51 +219,638 @@ func (p *config) parse() error {
 		return err
 	}
 
+	var c = NewConfiguration()
+	err = yaml.Unmarshal(data, c)
+	if err != nil {
+		return err
+	}
+
+	err = validate(c)
+	if err != nil {
+		return fmt.Errorf("invalid configuration %s. %s", path, err)
+	}
+
+	err = p.loadCredential(c)
+	if err != nil {
+		return err
+	}
+
 	p.Lock()
-	err = yaml.Unmarshal(data, p.cfg)
+	old := p.cfg
+	p.cfg = c
+	watchers := p.watchers
 	p.Unlock()
 
-	return err
+	if !reflect.DeepEqual(old, c) {
+		p.notify(watchers, &Change{Old: old, New: c})
+	}
+
+	return nil
 }
 
-// 定时读取配置文件
+// 从配置的密钥来源读取顶层和各账户的密钥并填入配置
+func (p *config) loadCredential(c *model.Configuration) error {
+	var err error
+	c.AppKey, c.AppSecret, err = p.credential(defaultAccount, &c.Credential, c.AppKey, c.AppSecret)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range c.Accounts {
+		v.AppKey, v.AppSecret, err = p.credential(v.Name, &v.Credential, v.AppKey, v.AppSecret)
+		if err != nil {
+			return fmt.Errorf("account %s: %s", v.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// 来源配置未变化时沿用已读取的密钥，避免每次重新加载配置都读取密钥
+func (p *config) credential(name string, cc *model.CredentialConfig, key, sec string) (string, string, error) {
+	if cc.Source == "" || cc.Source == secret.ConfigSource {
+		return key, sec, nil
+	}
+
+	p.RLock()
+	cached, ok := p.creds[name]
+	p.RUnlock()
+
+	if ok && reflect.DeepEqual(cached.cfg, *cc) {
+		return cached.cred.AppKey, cached.cred.AppSecret, nil
+	}
+
+	src, err := secret.NewSource(cc, key, sec)
+	if err != nil {
+		return "", "", err
+	}
+
+	cred, err := secret.Load(src)
+	if err != nil {
+		return "", "", err
+	}
+
+	p.Lock()
+	p.creds[name] = &credential{cfg: *cc, cred: cred}
+	p.Unlock()
+
+	return cred.AppKey, cred.AppSecret, nil
+}
+
+// 通知订阅者，订阅者未及时处理的变更会与本次变更合并
+func (p *config) notify(watchers []chan *Change, c *Change) {
+	for _, w := range watchers {
+		select {
+		case w <- c:
+		default:
+			var merged = c
+			select {
+			case pending := <-w:
+				merged = &Change{Old: pending.Old, New: c.New}
+			default:
+			}
+			select {
+			case w <- merged:
+			default:
+			}
+		}
+	}
+}
+
+// 定时或者在文件变化时读取配置文件
 func (p *config) AutoUpdate() {
-	if p.cfg.ReloadInterval == 0 {
+	current := p.configuration()
+	if current.ReloadInterval == 0 && !current.WatchFile {
 		return
 	}
 
-	p.interval = p.cfg.ReloadInterval
+	p.interval = current.ReloadInterval
 
 	var (
-		i   int
-		err error
-		t   *time.Ticker = time.NewTicker(time.Duration(p.interval) * time.Millisecond)
+		i      int
+		err    error
+		t      *time.Ticker
+		tick   <-chan time.Time
+		events <-chan fsnotify.Event
+		errs   <-chan error
 	)
+
+	if p.interval > 0 {
+		t = time.NewTicker(time.Duration(p.interval) * time.Millisecond)
+		tick = t.C
+	}
+
+	if current.WatchFile {
+		watcher, err := p.newFileWatcher()
+		if err != nil {
+			fmt.Printf("watch configuration file failed. %s\n", err)
+		} else {
+			defer watcher.Close()
+			events = watcher.Events
+			errs = watcher.Errors
+		}
+	}
+
+	if tick == nil && events == nil {
+		return
+	}
+
 	for {
 		select {
 		case err = <-p.errorChan:
-			fmt.Printf("%s", err)
+			fmt.Printf("%s\n", err)
+		case err = <-errs:
+			fmt.Printf("watch configuration file failed. %s\n", err)
 		case i = <-p.intervalChan:
-			if i == 0 {
+			if i == p.interval {
+				continue
+			}
+			fmt.Printf("reload interval set to %d from %d\n", i, p.interval)
+			p.interval = i
+			if t != nil {
+				t.Stop()
+				t, tick = nil, nil
+			}
+			if i > 0 {
+				t = time.NewTicker(time.Duration(i) * time.Millisecond)
+				tick = t.C
+			} else if events == nil {
 				fmt.Printf("reload interval set to 0, close auto reload\n")
 				return
 			}
+		case ev := <-events:
+			if !p.isConfigEvent(ev) {
+				continue
+			}
+			p.reload()
+		case <-tick:
+			p.reload()
+		}
+	}
+}
 
-			if i != p.interval {
-				fmt.Printf("reload interval set to %d from %d\n", i, p.interval)
-				p.interval = i
-				t.Stop()
-				t = time.NewTicker(time.Duration(p.interval) * time.Millisecond)
+//
+func (p *config) reload() {
+	err := p.parse()
+	if err != nil {
+		select {
+		case p.errorChan <- err:
+		default:
+		}
+		return
+	}
+
+	select {
+	case p.intervalChan <- p.configuration().ReloadInterval:
+	default:
+	}
+}
+
+// 监听配置文件所在目录，编辑器保存文件时通常会先删除再重建文件
+func (p *config) newFileWatcher() (*fsnotify.Watcher, error) {
+	p.RLock()
+	path := p.path
+	p.RUnlock()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	err = watcher.Add(filepath.Dir(path))
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	return watcher, nil
+}
+
+//
+func (p *config) isConfigEvent(ev fsnotify.Event) bool {
+	p.RLock()
+	path := p.path
+	p.RUnlock()
+
+	if filepath.Clean(ev.Name) != filepath.Clean(path) {
+		return false
+	}
+
+	return ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0
+}
+
+// 账户中未设置的项使用顶层配置的值
+func mergeAccount(c *model.Configuration, a *model.Account) *model.Configuration {
+	var ac = *c
+	ac.Name = a.Name
+	ac.Accounts = nil
+
+	if a.Mode != nil {
+		ac.Mode = *a.Mode
+	}
+	if a.AppKey != "" || a.AppSecret != "" || a.Credential.Source != "" {
+		ac.AppKey = a.AppKey
+		ac.AppSecret = a.AppSecret
+		ac.Credential = a.Credential
+	}
+	if a.Venue != "" {
+		ac.Venue = a.Venue
+		ac.VenueURL = a.VenueURL
+	}
+	if a.Symbol != "" {
+		ac.Symbol = a.Symbol
+		ac.Symbols = nil
+	}
+	if len(a.Symbols) > 0 {
+		ac.Symbols = a.Symbols
+	}
+	if a.SellNumber != nil {
+		ac.SellNumber = *a.SellNumber
+	}
+	if a.MakeUpPercent != nil {
+		ac.MakeUpPercent = *a.MakeUpPercent
+	}
+	if a.BalancePercent != nil {
+		ac.BalancePercent = *a.BalancePercent
+	}
+	if a.ExpectValue != nil {
+		ac.ExpectValue = *a.ExpectValue
+	}
+	if a.Risk != nil {
+		ac.Risk = *a.Risk
+	}
+	if a.Rebalance != nil {
+		ac.Rebalance = a.Rebalance
+	}
+	if a.Schedule != nil {
+		ac.Schedule = mergeSchedule(c.Schedule, a.Schedule)
+	}
+
+	return &ac
+}
+
+// 账户的交易时间安排只覆盖设置了的项，windows或blackouts设置为[]时清空顶层配置的时间段
+func mergeSchedule(c, a *model.ScheduleConfig) *model.ScheduleConfig {
+	var sc model.ScheduleConfig
+	if c != nil {
+		sc = *c
+	}
+	if a.TimeZone != "" {
+		sc.TimeZone = a.TimeZone
+	}
+	if a.Windows != nil {
+		sc.Windows = a.Windows
+	}
+	if a.Blackouts != nil {
+		sc.Blackouts = a.Blackouts
+	}
+	if a.CancelOnStop != nil {
+		sc.CancelOnStop = a.CancelOnStop
+	}
+	return &sc
+}
+
+// 校验配置项，配置了多个账户时分别校验每个账户合并后的配置
+func validate(c *model.Configuration) error {
+	err := validateLog(c)
+	if err != nil {
+		return err
+	}
+
+	err = validateNotify(&c.Notify)
+	if err != nil {
+		return err
+	}
+
+	if c.Watchdog.Interval < 0 || c.Watchdog.Timeout < 0 || c.Watchdog.MaxRestarts < 0 {
+		return fmt.Errorf("watchdog interval, timeout and max_restarts can not be negative")
+	}
+
+	if c.Reconcile.Interval < 0 {
+		return fmt.Errorf("reconcile interval can not be negative")
+	}
+	switch c.Reconcile.UnknownOrders {
+	case "", "adopt", "cancel":
+	default:
+		return fmt.Errorf("reconcile unknown_orders need be adopt or cancel")
+	}
+
+	err = validateArbitrage(&c.Arbitrage)
+	if err != nil {
+		return err
+	}
+
+	if len(c.Accounts) == 0 {
+		return validateAccount(c)
+	}
+
+	var names = make(map[string]bool)
+	for _, v := range c.Accounts {
+		if v.Name == "" {
+			return fmt.Errorf("account name is empty")
+		}
+		if names[v.Name] {
+			return fmt.Errorf("account name %s is duplicated", v.Name)
+		}
+		names[v.Name] = true
+	}
+
+	for _, v := range Accounts(c) {
+		err := validateAccount(v)
+		if err != nil {
+			return fmt.Errorf("account %s: %s", v.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// 日志配置只在顶层生效
+func validateLog(c *model.Configuration) error {
+	if !isLogLevel(c.LogLevel) {
+		return fmt.Errorf("log_level need be debug, info, warn or error")
+	}
+	for k, v := range c.LogLevels {
+		if !isLogLevel(v) {
+			return fmt.Errorf("log level of module %s need be debug, info, warn or error", k)
+		}
+	}
+
+	if c.LogMaxSize < 0 || c.LogMaxBackups < 0 || c.LogMaxAge < 0 {
+		return fmt.Errorf("log_max_size, log_max_backups and log_max_age can not be negative")
+	}
+
+	switch c.LogRotate {
+	case "", "hourly", "daily":
+	default:
+		return fmt.Errorf("log_rotate need be hourly or daily")
+	}
+
+	return nil
+}
+
+// 告警通知配置只在顶层生效
+func validateNotify(c *model.NotifyConfig) error {
+	if c.DedupWindow < 0 || c.RateLimit < 0 || c.APIErrorThreshold < 0 || c.StuckOrderAfter < 0 {
+		return fmt.Errorf("notify dedup_window, rate_limit, api_error_threshold and stuck_order_after can not be negative")
+	}
+
+	if c.DailySummaryAt != "" {
+		if _, err := time.Parse("15:04", c.DailySummaryAt); err != nil {
+			return fmt.Errorf("notify daily_summary_at need be format as 15:04")
+		}
+	}
+
+	if c.SMTP != nil && (c.SMTP.Host == "" || c.SMTP.From == "" || len(c.SMTP.To) == 0) {
+		return fmt.Errorf("notify smtp host, from and to are required")
+	}
+	if c.Webhook != nil && c.Webhook.URL == "" {
+		return fmt.Errorf("notify webhook url is empty")
+	}
+	if c.Telegram != nil && (c.Telegram.TokenEnv == "" || c.Telegram.ChatId == "") {
+		return fmt.Errorf("notify telegram token_env and chat_id are required")
+	}
+	if c.Slack != nil && c.Slack.URL == "" {
+		return fmt.Errorf("notify slack url is empty")
+	}
+
+	return nil
+}
+
+// 价差监控配置只在顶层生效
+func validateArbitrage(c *model.ArbitrageConfig) error {
+	if c.Interval < 0 || c.Threshold < 0 || c.Amount < 0 || c.MaxQuoteAge < 0 {
+		return fmt.Errorf("arbitrage interval, threshold, amount and max_quote_age can not be negative")
+	}
+
+	var names = make(map[string]bool)
+	for _, v := range c.Sources {
+		if v.Name == "" {
+			return fmt.Errorf("arbitrage source name is empty")
+		}
+		if names[v.Name] {
+			return fmt.Errorf("arbitrage source %s is duplicated", v.Name)
+		}
+		names[v.Name] = true
+
+		switch v.Venue {
+		case "fcoin", "binance":
+		case "feed":
+			if v.Path == "" {
+				return fmt.Errorf("arbitrage source %s path is empty", v.Name)
 			}
 		default:
-			<-t.C
-			err = p.parse()
-			if err != nil {
-				p.errorChan <- err
-			} else {
-				p.SetReloadInterval(p.cfg.ReloadInterval)
+			return fmt.Errorf("arbitrage source %s venue need be fcoin, binance or feed", v.Name)
+		}
+
+		if v.TakerFee < 0 || v.TakerFee >= 1 {
+			return fmt.Errorf("arbitrage source %s taker_fee need be between 0 and 1", v.Name)
+		}
+		for k, f := range v.WithdrawFees {
+			if f < 0 {
+				return fmt.Errorf("arbitrage source %s withdraw fee of %s can not be negative", v.Name, k)
 			}
+		}
+	}
+
+	if c.Interval > 0 && (len(c.Sources) < 2 || len(c.Symbols) == 0) {
+		return fmt.Errorf("arbitrage need at least two sources and one symbol")
+	}
+
+	return validateTriangular(&c.Triangular)
+}
 
+//
+func validateTriangular(c *model.TriangularConfig) error {
+	if c.Interval < 0 || c.Threshold < 0 {
+		return fmt.Errorf("arbitrage triangular interval and threshold can not be negative")
+	}
+
+	switch c.Venue {
+	case "", "fcoin", "binance":
+	default:
+		return fmt.Errorf("arbitrage triangular venue need be fcoin or binance")
+	}
+
+	if c.TakerFee < 0 || c.TakerFee >= 1 {
+		return fmt.Errorf("arbitrage triangular taker_fee need be between 0 and 1")
+	}
+
+	for k, v := range c.MaxAmounts {
+		if v <= 0 {
+			return fmt.Errorf("arbitrage triangular max amount of %s need be greater than 0", k)
 		}
 	}
+	if c.Interval > 0 && len(c.MaxAmounts) == 0 {
+		return fmt.Errorf("arbitrage triangular need at least one start currency in max_amounts")
+	}
+
+	return nil
+}
+
+//
+func validateAlgo(c *model.AlgoConfig) error {
+	if c.Horizon < 0 || c.Slices < 0 || c.Display < 0 || c.ChildTimeout < 0 || c.PriceLimit < 0 {
+		return fmt.Errorf("horizon, slices, display, child_timeout and price_limit can not be negative")
+	}
+
+	switch c.Type {
+	case "twap":
+		if c.Horizon == 0 {
+			return fmt.Errorf("twap need horizon greater than 0")
+		}
+	case "vwap":
+		if c.Horizon == 0 || len(c.Profile) == 0 {
+			return fmt.Errorf("vwap need horizon and profile")
+		}
+		for _, v := range c.Profile {
+			if v < 0 {
+				return fmt.Errorf("vwap profile can not be negative")
+			}
+		}
+	case "iceberg":
+		if c.Display == 0 {
+			return fmt.Errorf("iceberg need display greater than 0")
+		}
+	default:
+		return fmt.Errorf("type need be twap, vwap or iceberg")
+	}
+	return nil
+}
+
+// hasAlgo为是否配置了makeup_algo
+func validateRebalance(c *model.RebalanceConfig, hasAlgo bool) error {
+	if c.TargetRatio != nil && (*c.TargetRatio < 0 || *c.TargetRatio > 1) {
+		return fmt.Errorf("target_ratio need be between 0 and 1")
+	}
+	if c.Band != nil && (*c.Band < 0 || *c.Band >= 1) {
+		return fmt.Errorf("band need be between 0 and 1")
+	}
+	if c.MinClip < 0 || c.MaxClip < 0 || c.Cooldown < 0 || c.DailyLimit < 0 || c.DailyOrders < 0 {
+		return fmt.Errorf("min_clip, max_clip, cooldown, daily_limit and daily_orders can not be negative")
+	}
+	if c.MaxClip > 0 && c.MaxClip < c.MinClip {
+		return fmt.Errorf("max_clip can not be less than min_clip")
+	}
+
+	switch c.OrderType {
+	case "", "limit", "market", "ioc":
+	case "algo":
+		if !hasAlgo {
+			return fmt.Errorf("order_type algo need makeup_algo")
+		}
+	default:
+		return fmt.Errorf("order_type need be limit, market, ioc or algo")
+	}
+	return nil
+}
+
+// 兼容旧配置中的warnning
+func isLogLevel(s string) bool {
+	switch strings.ToLower(s) {
+	case "", "debug", "info", "warn", "warning", "warnning", "error":
+		return true
+	}
+	return false
+}
+
+//
+func validateAccount(c *model.Configuration) error {
+	if c.Mode != model.TaskMode && c.Mode != model.ScheduleMode {
+		return fmt.Errorf("mode need be 0 or 1")
+	}
+
+	if c.ReloadInterval < 0 {
+		return fmt.Errorf("reload_interval can not be negative")
+	}
+
+	switch c.Venue {
+	case "", "fcoin", "binance":
+	default:
+		return fmt.Errorf("venue need be fcoin or binance")
+	}
+
+	switch c.MakeUpOrderType {
+	case "", "limit", "market", "ioc":
+	default:
+		return fmt.Errorf("makeup_order_type need be limit, market or ioc")
+	}
+
+	if c.MakeUpAlgo != nil {
+		err := validateAlgo(c.MakeUpAlgo)
+		if err != nil {
+			return fmt.Errorf("makeup_algo: %s", err)
+		}
+	}
+
+	if _, err := schedule.New(c.Schedule); err != nil {
+		return fmt.Errorf("schedule: %s", err)
+	}
+
+	source := c.Credential.Source
+	if source != "" && source != secret.ConfigSource && (c.AppKey != "" || c.AppSecret != "") {
+		return fmt.Errorf("appkey and appsecret must be empty when credential source is %s", source)
+	}
+
+	var symbols = make(map[string]bool)
+	for _, v := range Symbols(c) {
+		if v.Symbol == "" {
+			return fmt.Errorf("symbol is empty")
+		}
+		if symbols[v.Symbol] {
+			return fmt.Errorf("symbol %s is duplicated", v.Symbol)
+		}
+		symbols[v.Symbol] = true
+
+		if v.SellNumber <= 0 {
+			return fmt.Errorf("%s sell_number need be greater than 0", v.Symbol)
+		}
+
+		if v.MakeUpPercent < 0 || v.MakeUpPercent > 100 {
+			return fmt.Errorf("%s makeup_percent need be between 0 and 100", v.Symbol)
+		}
+
+		if v.BalancePercent < 1 || v.BalancePercent > 100 {
+			return fmt.Errorf("%s balance_percent need be between 1 and 100", v.Symbol)
+		}
+
+		for _, op := range v.OrderPolicies {
+			switch op.Type {
+			case "timeout", "reprice":
+				if op.After <= 0 {
+					return fmt.Errorf("%s order policy %s need after greater than 0", v.Symbol, op.Type)
+				}
+			case "price_move":
+				if op.Ticks <= 0 {
+					return fmt.Errorf("%s order policy price_move need ticks greater than 0", v.Symbol)
+				}
+			case "keep_partial":
+			default:
+				return fmt.Errorf("%s unknown order policy %s", v.Symbol, op.Type)
+			}
+		}
+
+		if v.Rebalance != nil {
+			err := validateRebalance(v.Rebalance, c.MakeUpAlgo != nil)
+			if err != nil {
+				return fmt.Errorf("%s rebalance: %s", v.Symbol, err)
+			}
+		}
+	}
+
+	if c.RequestTimeout < 0 {
+		return fmt.Errorf("request_timeout can not be negative")
+	}
+
+	switch c.SelfTradePrevention {
+	case "", "cancel_oldest", "cancel_newest", "reject", "adjust":
+	default:
+		return fmt.Errorf("self_trade_prevention need be cancel_oldest, cancel_newest, reject or adjust")
+	}
+
+	if c.Risk.MaxOrderAmount < 0 || c.Risk.MaxOrderValue < 0 || c.Risk.MaxDailyOrders < 0 {
+		return fmt.Errorf("risk limits can not be negative")
+	}
+
+	return nil
 }
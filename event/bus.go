@@ -0,0 +1,232 @@
+package event
+
+import (
+	"fcoinExchange/metrics"
+	"sync"
+)
+
+// 订阅者缓冲区满时的处理方式
+type Policy int
+
+const (
+	// 丢弃最早的事件
+	PolicyDrop Policy = iota
+	// 合并标识相同的事件，只保留最新的一个，缓冲区仍然满时丢弃最早的事件
+	PolicyCoalesce
+)
+
+var (
+	// 默认的订阅者缓冲区大小
+	defaultBuffer = 64
+)
+
+// 订阅选项
+type Options struct {
+	// 订阅的事件类型，为空时订阅所有类型
+	Types  []Type
+	Buffer int
+	Policy Policy
+	// 返回false的事件不投递
+	Filter func(e Event) bool
+	// 订阅时先投递历史中符合条件的事件
+	Replay bool
+}
+
+// 只投递属于账户的事件，不属于任何账户的事件也投递
+func ForAccount(name string) func(e Event) bool {
+	return func(e Event) bool {
+		a := Account(e)
+		return a == "" || a == name
+	}
+}
+
+// 进程内的事件总线。
+// 每个订阅者有独立的缓冲区，发布事件不会因为某个订阅者处理过慢而阻塞
+type Bus struct {
+	subs map[*Subscription]bool
+
+	// 最近发布的事件，用于回放
+	history []Event
+	size    int
+	sync.RWMutex
+}
+
+// history为保留的历史事件数量，设置为0则不保留
+func New(history int) *Bus {
+	return &Bus{
+		subs:    make(map[*Subscription]bool),
+		history: make([]Event, 0),
+		size:    history,
+	}
+}
+
+// 发布事件到所有订阅者
+func (p *Bus) Publish(e Event) {
+	p.Lock()
+	if p.size > 0 {
+		if len(p.history) >= p.size {
+			p.history = append(p.history[:0], p.history[1:]...)
+		}
+		p.history = append(p.history, e)
+	}
+	var subs = make([]*Subscription, 0, len(p.subs))
+	for s := range p.subs {
+		subs = append(subs, s)
+	}
+	p.Unlock()
+
+	metrics.Inc("event", "published", string(e.Type()))
+	for _, s := range subs {
+		s.push(e)
+	}
+}
+
+// 重新发布事件，用于测试和问题复现
+func (p *Bus) Replay(events []Event) {
+	for _, e := range events {
+		p.Publish(e)
+	}
+}
+
+// 返回历史事件的副本
+func (p *Bus) History() []Event {
+	p.RLock()
+	defer p.RUnlock()
+	return append([]Event(nil), p.history...)
+}
+
+// 订阅事件，name用于统计丢弃的事件数量
+func (p *Bus) Subscribe(name string, opts Options) *Subscription {
+	var s = &Subscription{
+		name:   name,
+		bus:    p,
+		types:  make(map[Type]bool),
+		buffer: opts.Buffer,
+		policy: opts.Policy,
+		filter: opts.Filter,
+		queue:  make([]Event, 0),
+		signal: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+	if s.buffer <= 0 {
+		s.buffer = defaultBuffer
+	}
+	for _, t := range opts.Types {
+		s.types[t] = true
+	}
+
+	p.Lock()
+	p.subs[s] = true
+	var history []Event
+	if opts.Replay {
+		history = append(history, p.history...)
+	}
+	p.Unlock()
+
+	for _, e := range history {
+		s.push(e)
+	}
+	return s
+}
+
+//
+func (p *Bus) unsubscribe(s *Subscription) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.subs, s)
+}
+
+// 订阅者
+type Subscription struct {
+	name   string
+	bus    *Bus
+	types  map[Type]bool
+	buffer int
+	policy Policy
+	filter func(e Event) bool
+
+	queue  []Event
+	signal chan struct{}
+	closed chan struct{}
+	once   sync.Once
+	sync.Mutex
+}
+
+//
+func (p *Subscription) accept(e Event) bool {
+	if len(p.types) > 0 && !p.types[e.Type()] {
+		return false
+	}
+	if p.filter != nil && !p.filter(e) {
+		return false
+	}
+	return true
+}
+
+// 放入缓冲区，缓冲区满时按订阅的处理方式丢弃或者合并
+func (p *Subscription) push(e Event) {
+	if !p.accept(e) {
+		return
+	}
+
+	p.Lock()
+	if p.policy == PolicyCoalesce && e.Key() != "" {
+		for i, v := range p.queue {
+			if v.Type() == e.Type() && v.Key() == e.Key() {
+				p.queue[i] = e
+				p.Unlock()
+				metrics.Inc("event", "coalesced", p.name)
+				return
+			}
+		}
+	}
+	if len(p.queue) >= p.buffer {
+		p.queue = append(p.queue[:0], p.queue[1:]...)
+		metrics.Inc("event", "dropped", p.name)
+	}
+	p.queue = append(p.queue, e)
+	p.Unlock()
+
+	select {
+	case p.signal <- struct{}{}:
+	default:
+	}
+}
+
+// 等待下一个事件，done关闭或者取消订阅时返回false
+func (p *Subscription) Next(done <-chan struct{}) (Event, bool) {
+	for {
+		p.Lock()
+		if len(p.queue) > 0 {
+			e := p.queue[0]
+			p.queue[0] = nil
+			p.queue = p.queue[1:]
+			p.Unlock()
+			return e, true
+		}
+		p.Unlock()
+
+		select {
+		case <-p.signal:
+		case <-done:
+			return nil, false
+		case <-p.closed:
+			return nil, false
+		}
+	}
+}
+
+// 缓冲区中等待处理的事件数量
+func (p *Subscription) Len() int {
+	p.Lock()
+	defer p.Unlock()
+	return len(p.queue)
+}
+
+// 取消订阅，等待中的Next返回false
+func (p *Subscription) Close() {
+	p.once.Do(func() {
+		p.bus.unsubscribe(p)
+		close(p.closed)
+	})
+}
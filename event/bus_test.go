@@ -0,0 +1,152 @@
+package event
+
+import (
+	"fcoinExchange/metrics"
+	"fcoinExchange/model"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//
+func quote(account, symbol string, seq int64) *QuoteUpdated {
+	return &QuoteUpdated{Account: account, Symbol: symbol, Quote: &model.Quote{Seq: seq}}
+}
+
+// 取出缓冲区中的所有事件
+func drain(s *Subscription) []Event {
+	var list []Event
+	for s.Len() > 0 {
+		e, _ := s.Next(nil)
+		list = append(list, e)
+	}
+	return list
+}
+
+// 缓冲区满时丢弃最早的事件
+func TestBusDropOldest(t *testing.T) {
+	bus := New(0)
+	s := bus.Subscribe("drop_test", Options{Buffer: 3, Policy: PolicyDrop})
+	defer s.Close()
+
+	dropped := metrics.Get("event", "dropped", "drop_test")
+	for i := int64(1); i <= 5; i++ {
+		bus.Publish(quote("a", "btcusdt", i))
+	}
+
+	list := drain(s)
+	if len(list) != 3 {
+		t.Fatalf("got %d events, want 3", len(list))
+	}
+	for i, e := range list {
+		if seq := e.(*QuoteUpdated).Quote.Seq; seq != int64(i+3) {
+			t.Errorf("event %d seq = %d, want %d", i, seq, i+3)
+		}
+	}
+	if n := metrics.Get("event", "dropped", "drop_test") - dropped; n != 2 {
+		t.Errorf("dropped = %d, want 2", n)
+	}
+}
+
+// 标识相同的事件只保留最新的一个，保持第一次出现的位置
+func TestBusCoalesce(t *testing.T) {
+	bus := New(0)
+	s := bus.Subscribe("coalesce_test", Options{Buffer: 2, Policy: PolicyCoalesce})
+	defer s.Close()
+
+	bus.Publish(quote("a", "btcusdt", 1))
+	bus.Publish(quote("a", "ethusdt", 2))
+	bus.Publish(quote("a", "btcusdt", 3))
+	bus.Publish(quote("a", "btcusdt", 4))
+
+	list := drain(s)
+	if len(list) != 2 {
+		t.Fatalf("got %d events, want 2", len(list))
+	}
+	if q := list[0].(*QuoteUpdated); q.Symbol != "btcusdt" || q.Quote.Seq != 4 {
+		t.Errorf("first event = %s seq %d, want btcusdt seq 4", q.Symbol, q.Quote.Seq)
+	}
+	if q := list[1].(*QuoteUpdated); q.Symbol != "ethusdt" || q.Quote.Seq != 2 {
+		t.Errorf("second event = %s seq %d, want ethusdt seq 2", q.Symbol, q.Quote.Seq)
+	}
+
+	// 标识不同的事件在缓冲区满时仍然丢弃最早的
+	bus.Publish(quote("a", "ftusdt", 5))
+	bus.Publish(quote("a", "xrpusdt", 6))
+	bus.Publish(quote("a", "ltcusdt", 7))
+	list = drain(s)
+	if len(list) != 2 || list[0].(*QuoteUpdated).Symbol != "xrpusdt" {
+		t.Errorf("got %v after overflow", list)
+	}
+}
+
+// 订阅时回放历史中符合条件的事件，历史只保留最近的事件
+func TestBusReplayOnSubscribe(t *testing.T) {
+	bus := New(3)
+	for i := int64(1); i <= 4; i++ {
+		bus.Publish(quote("a", "s"+strconv.FormatInt(i, 10), i))
+	}
+	bus.Publish(&BalanceUpdated{Account: "b"})
+
+	if h := bus.History(); len(h) != 3 {
+		t.Fatalf("history has %d events, want 3", len(h))
+	}
+
+	s := bus.Subscribe("replay_test", Options{Types: []Type{TypeQuoteUpdated}, Filter: ForAccount("a"), Replay: true})
+	defer s.Close()
+	list := drain(s)
+	if len(list) != 2 || list[0].(*QuoteUpdated).Quote.Seq != 3 || list[1].(*QuoteUpdated).Quote.Seq != 4 {
+		t.Errorf("replayed %v, want quotes 3 and 4", list)
+	}
+
+	none := bus.Subscribe("no_replay_test", Options{})
+	defer none.Close()
+	if n := none.Len(); n != 0 {
+		t.Errorf("subscription without replay got %d events", n)
+	}
+
+	// Bus.Replay按顺序重新发布
+	bus.Replay([]Event{quote("a", "x", 10), quote("b", "y", 11), quote("a", "z", 12)})
+	list = drain(s)
+	if len(list) != 2 || list[0].(*QuoteUpdated).Symbol != "x" || list[1].(*QuoteUpdated).Symbol != "z" {
+		t.Errorf("replayed %v, want x and z", list)
+	}
+}
+
+// Close使等待中的Next返回false，之后发布的事件不再投递
+func TestBusCloseUnblocksNext(t *testing.T) {
+	bus := New(0)
+	s := bus.Subscribe("close_test", Options{})
+
+	result := make(chan bool)
+	go func() {
+		_, ok := s.Next(nil)
+		result <- ok
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	s.Close()
+	select {
+	case ok := <-result:
+		if ok {
+			t.Errorf("Next returned an event after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next still blocked after Close")
+	}
+
+	bus.Publish(quote("a", "btcusdt", 1))
+	if n := s.Len(); n != 0 {
+		t.Errorf("closed subscription received %d events", n)
+	}
+	s.Close()
+
+	// done关闭时也返回
+	s2 := bus.Subscribe("done_test", Options{})
+	defer s2.Close()
+	done := make(chan struct{})
+	close(done)
+	if _, ok := s2.Next(done); ok {
+		t.Errorf("Next returned an event after done closed")
+	}
+}
@@ -0,0 +1,190 @@
+package event
+
+import (
+	"fcoinExchange/model"
+	"strings"
+	"time"
+)
+
+// 事件类型
+type Type string
+
+const (
+	TypeQuoteUpdated   Type = "quote_updated"
+	TypeBalanceUpdated Type = "balance_updated"
+	TypeOrderUpdated   Type = "order_updated"
+	TypeFillReceived   Type = "fill_received"
+	TypeConfigChanged  Type = "config_changed"
+	TypeOpportunity    Type = "opportunity"
+	TypeTriangular     Type = "triangular_opportunity"
+)
+
+// 事件，发布后不能再被修改
+type Event interface {
+	Type() Type
+	// 合并事件时使用的标识，标识相同的事件只保留最新的一个，为空时不合并
+	Key() string
+}
+
+// 交易对的行情更新
+type QuoteUpdated struct {
+	Account string
+	Symbol  string
+	Quote   *model.Quote
+	Time    time.Time
+}
+
+//
+func (p *QuoteUpdated) Type() Type {
+	return TypeQuoteUpdated
+}
+
+//
+func (p *QuoteUpdated) Key() string {
+	return p.Account + "/" + p.Symbol
+}
+
+// 账户余额刷新
+type BalanceUpdated struct {
+	Account  string
+	Balances []*model.BalanceContext
+	Time     time.Time
+}
+
+//
+func (p *BalanceUpdated) Type() Type {
+	return TypeBalanceUpdated
+}
+
+//
+func (p *BalanceUpdated) Key() string {
+	return p.Account
+}
+
+// 订单状态变化，State为submitted、partial_filled、canceled或者closed
+type OrderUpdated struct {
+	Account string
+	Symbol  string
+	OrderId string
+	Side    string
+	Price   string
+	Amount  string
+	State   string
+	Filled  float64
+	Time    time.Time
+}
+
+//
+func (p *OrderUpdated) Type() Type {
+	return TypeOrderUpdated
+}
+
+//
+func (p *OrderUpdated) Key() string {
+	return p.Account + "/" + p.OrderId
+}
+
+// 订单新成交的部分，成交事件不会被合并
+type FillReceived struct {
+	Account string
+	Symbol  string
+	OrderId string
+	Side    string
+	Price   float64
+	Amount  float64
+	Time    time.Time
+}
+
+//
+func (p *FillReceived) Type() Type {
+	return TypeFillReceived
+}
+
+//
+func (p *FillReceived) Key() string {
+	return ""
+}
+
+// 配置文件变化
+type ConfigChanged struct {
+	Old  *model.Configuration
+	New  *model.Configuration
+	Time time.Time
+}
+
+//
+func (p *ConfigChanged) Type() Type {
+	return TypeConfigChanged
+}
+
+//
+func (p *ConfigChanged) Key() string {
+	return "config"
+}
+
+// 跨交易所的套利机会，在Buy买入并在Sell卖出，
+// NetPercent为扣除手续费和提币费用后的收益百分比
+type Opportunity struct {
+	Symbol     string
+	Buy        string
+	Sell       string
+	BuyPrice   float64
+	SellPrice  float64
+	Amount     float64
+	NetPercent float64
+	Time       time.Time
+}
+
+//
+func (p *Opportunity) Type() Type {
+	return TypeOpportunity
+}
+
+//
+func (p *Opportunity) Key() string {
+	return p.Symbol + "/" + p.Buy + "/" + p.Sell
+}
+
+// 三角套利中的一笔交易，Amount为base currency数量
+type Leg struct {
+	Symbol string
+	Side   string
+	Price  float64
+	Amount float64
+}
+
+// 单个交易所内的三角套利机会，Currencies为兑换顺序，首尾相同
+type TriangularOpportunity struct {
+	Venue         string
+	Currencies    []string
+	Legs          []Leg
+	StartAmount   float64
+	ProfitPercent float64
+	Time          time.Time
+}
+
+//
+func (p *TriangularOpportunity) Type() Type {
+	return TypeTriangular
+}
+
+//
+func (p *TriangularOpportunity) Key() string {
+	return p.Venue + "/" + strings.Join(p.Currencies, ">")
+}
+
+// 事件所属的账户，ConfigChanged和套利机会不属于任何账户
+func Account(e Event) string {
+	switch v := e.(type) {
+	case *QuoteUpdated:
+		return v.Account
+	case *BalanceUpdated:
+		return v.Account
+	case *OrderUpdated:
+		return v.Account
+	case *FillReceived:
+		return v.Account
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,81 @@
+package exchange
+
+import (
+	"fcoinExchange/notify"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 记录请求结果，连续失败次数达到api_error_threshold时告警，之后每达到一次阈值的倍数再次告警
+func (p *Exchange) apiResult(op string, err error) {
+	if err == nil {
+		atomic.StoreInt32(&p.apiErrors, 0)
+		atomic.StoreInt64(&p.apiSucceededAt, time.Now().UnixNano())
+		return
+	}
+
+	n := atomic.AddInt32(&p.apiErrors, 1)
+	threshold := int32(p.configuration().Notify.APIErrorThreshold)
+	if threshold <= 0 || n%threshold != 0 {
+		return
+	}
+
+	notify.Send(&notify.Event{
+		Kind:     notify.KindAPIError,
+		Severity: notify.SeverityCritical,
+		Account:  p.Name,
+		Title:    fmt.Sprintf("%d consecutive api errors", n),
+		Message:  fmt.Sprintf("last failed request %s: %s", op, err),
+	})
+}
+
+// 可用余额低于low_balance中配置的阈值时告警
+func (p *Exchange) checkLowBalance() {
+	snapshot := p.state.Load()
+	for currency, threshold := range p.configuration().Notify.LowBalance {
+		b, err := snapshot.Balance(currency)
+		if err != nil || b.Available >= threshold {
+			continue
+		}
+
+		notify.Send(&notify.Event{
+			Kind:     notify.KindLowBalance,
+			Severity: notify.SeverityWarning,
+			Account:  p.Name,
+			Key:      currency,
+			Title:    fmt.Sprintf("%s balance below %v", currency, threshold),
+			Message:  fmt.Sprintf("available %.8f, frozen %.8f", b.Available, b.Frozen),
+		})
+	}
+}
+
+// 订单存在时间超过stuck_order_after时告警
+func (p *Exchange) checkStuckOrder(m *Market, id, side, price string, age int64) {
+	after := p.configuration().Notify.StuckOrderAfter
+	if after <= 0 || age <= after {
+		return
+	}
+
+	notify.Send(&notify.Event{
+		Kind:     notify.KindStuckOrder,
+		Severity: notify.SeverityWarning,
+		Account:  p.Name,
+		Key:      id,
+		Title:    fmt.Sprintf("%s order %s open for %s", m.Symbol, id, time.Duration(age)*time.Millisecond),
+		Message:  fmt.Sprintf("%s at %s", side, price),
+	})
+}
+
+// 达到当天订单数量上限后停止下单时告警，每天只告警一次
+func (p *Exchange) killSwitchTripped(day string, limit int) {
+	notify.Send(&notify.Event{
+		Kind:     notify.KindKillSwitch,
+		Severity: notify.SeverityCritical,
+		Account:  p.Name,
+		Key:      day,
+		Title:    "trading stopped by risk limits",
+		Message:  "daily orders reach max_daily_orders " + strconv.Itoa(limit),
+	})
+}
@@ -0,0 +1,95 @@
+package exchange
+
+import (
+	"fcoinExchange/algo"
+	"fcoinExchange/model"
+	"fmt"
+)
+
+// 在交易对上执行算法订单的子订单，子订单和普通订单一样经过自成交检查、风险限制和账本预留
+type marketExecutor struct {
+	ex       *Exchange
+	m        *Market
+	strategy string
+}
+
+//
+func (p *marketExecutor) Place(side string, price, amount float64) (string, error) {
+	return p.ex.createOrder(p.m, p.strategy, side, p.m.FormatPrice(price), p.m.FormatAmount(amount))
+}
+
+//
+func (p *marketExecutor) Cancel(id string) error {
+	return p.ex.CancelOrder(p.m, id)
+}
+
+//
+func (p *marketExecutor) Order(id string) (*model.OrderInfo, error) {
+	o, err := p.ex.client().Order(p.m.Symbol, id)
+	p.ex.apiResult("get order", err)
+	return o, err
+}
+
+//
+func (p *marketExecutor) Quote() (*model.Quote, error) {
+	return p.ex.GetCurrentQuote(p.m.Symbol)
+}
+
+//
+func (p *marketExecutor) Round(amount float64) float64 {
+	return p.m.FloorAmount(amount)
+}
+
+// 返回在交易对上执行算法订单的Executor
+func (p *Exchange) Executor(m *Market, strategy string) algo.Executor {
+	return &marketExecutor{ex: p, m: m, strategy: strategy}
+}
+
+// 按账户名称和交易对返回Executor，用于控制接口提交的算法订单
+func (p *Group) Executor(account, symbol, strategy string) (algo.Executor, error) {
+	for _, ex := range p.Exchanges() {
+		if ex.Name != account {
+			continue
+		}
+		m, err := ex.Market(symbol)
+		if err != nil {
+			return nil, err
+		}
+		return ex.Executor(m, strategy), nil
+	}
+	return nil, fmt.Errorf("account %s not found", account)
+}
+
+// 使用makeup_algo配置的算法订单再平衡，同一交易对同时只执行一个再平衡的算法订单
+func (p *Exchange) makeUpWithAlgo(m *Market, side string, amount, price float64) error {
+	cfg := p.configuration().MakeUpAlgo
+	if cfg == nil {
+		return fmt.Errorf("makeup_algo is not configured")
+	}
+
+	parent := algo.NewParent(cfg, side, amount, price)
+	parent.Account = p.Name
+	parent.Symbol = m.Symbol
+	parent.Strategy = "rebalance"
+
+	o, err := algo.Default.Submit(p.Executor(m, parent.Strategy), parent)
+	if err != nil {
+		return err
+	}
+	m.setMakeUpOrder(o)
+	return nil
+}
+
+// 再平衡的算法订单是否正在执行
+func (p *Market) makeUpRunning() bool {
+	o := p.makeUpOrder()
+	if o == nil {
+		return false
+	}
+	select {
+	case <-o.Done():
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,76 @@
+package exchange
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrBalanceNotLoaded = errors.New("account balance not yet loaded")
+)
+
+// 币种余额
+type Balance struct {
+	Currency  string
+	Available float64
+	Frozen    float64
+	Total     float64
+	// 交易所返回的余额中是否包含该币种，账户从未持有过的币种不会出现在返回结果中
+	Present bool
+	// 余额请求发起的时间
+	UpdatedAt time.Time
+}
+
+// 余额距离现在的时间
+func (p *Balance) Age() time.Duration {
+	return time.Since(p.UpdatedAt)
+}
+
+// 返回币种余额。账户余额尚未加载时返回ErrBalanceNotLoaded，
+// 已加载但交易所未返回的币种余额为0
+func (p *Snapshot) Balance(currency string) (*Balance, error) {
+	if p.BalanceUpdatedAt.IsZero() {
+		return nil, ErrBalanceNotLoaded
+	}
+
+	var b = &Balance{
+		Currency:  currency,
+		UpdatedAt: p.BalanceUpdatedAt,
+	}
+
+	v, ok := p.Balances[currency]
+	if !ok {
+		return b, nil
+	}
+
+	var err error
+	b.Present = true
+	b.Available, err = strconv.ParseFloat(v.Available, 64)
+	if err != nil {
+		return nil, err
+	}
+	b.Frozen, err = strconv.ParseFloat(v.Frozen, 64)
+	if err != nil {
+		return nil, err
+	}
+	b.Total, err = strconv.ParseFloat(v.Balance, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// 返回账户余额距离现在的时间，余额尚未加载时第二个返回值为false
+func (p *Snapshot) BalanceAge() (time.Duration, bool) {
+	if p.BalanceUpdatedAt.IsZero() {
+		return 0, false
+	}
+	return time.Since(p.BalanceUpdatedAt), true
+}
+
+// 返回账户当前的币种余额
+func (p *Exchange) Balance(currency string) (*Balance, error) {
+	return p.state.Load().Balance(currency)
+}
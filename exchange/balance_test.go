@@ -0,0 +1,90 @@
+package exchange
+
+import (
+	"fcoinExchange/model"
+	"testing"
+	"time"
+)
+
+// 交易所返回的余额缺少交易对的一个币种
+func TestBalanceMissingCurrency(t *testing.T) {
+	v := newFakeVenue()
+	v.setBalances(&model.BalanceContext{Currency: "btc", Available: "1.5", Frozen: "0.5", Balance: "2"})
+	ex := newTestExchange(t, v, nil)
+	m, err := ex.Market("btcusdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 余额尚未加载
+	for _, currency := range []string{"btc", "usdt"} {
+		if _, err := ex.Balance(currency); err != ErrBalanceNotLoaded {
+			t.Errorf("%s before load: err = %v, want ErrBalanceNotLoaded", currency, err)
+		}
+	}
+	if ex.canTrade(m, 100, 0.01) {
+		t.Errorf("canTrade before balance loaded")
+	}
+
+	before := time.Now()
+	if err = ex.UpdateBalance(); err != nil {
+		t.Fatal(err)
+	}
+
+	btc, err := ex.Balance("btc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !btc.Present || btc.Available != 1.5 || btc.Frozen != 0.5 || btc.Total != 2 {
+		t.Errorf("btc = %+v", btc)
+	}
+	if btc.UpdatedAt.Before(before) || btc.Age() < 0 || btc.Age() > time.Minute {
+		t.Errorf("btc updated at %s, age %s", btc.UpdatedAt, btc.Age())
+	}
+
+	// 未返回的币种余额为0，但与交易所返回的0余额区分
+	usdt, err := ex.Balance("usdt")
+	if err != nil {
+		t.Fatalf("usdt after load: %v", err)
+	}
+	if usdt.Present || usdt.Available != 0 || usdt.Total != 0 {
+		t.Errorf("usdt = %+v, want absent zero balance", usdt)
+	}
+	if !usdt.UpdatedAt.Equal(btc.UpdatedAt) {
+		t.Errorf("usdt updated at %s, want %s", usdt.UpdatedAt, btc.UpdatedAt)
+	}
+	if ex.canTrade(m, 100, 0.01) {
+		t.Errorf("canTrade without quote currency")
+	}
+
+	v.setBalances(
+		&model.BalanceContext{Currency: "btc", Available: "1.5", Frozen: "0", Balance: "1.5"},
+		&model.BalanceContext{Currency: "usdt", Available: "0", Frozen: "0", Balance: "0"},
+	)
+	if err := ex.UpdateBalance(); err != nil {
+		t.Fatal(err)
+	}
+	usdt, err = ex.Balance("usdt")
+	if err != nil || !usdt.Present || usdt.Total != 0 {
+		t.Errorf("usdt = %+v, %v, want present zero balance", usdt, err)
+	}
+}
+
+//
+func TestBalanceAge(t *testing.T) {
+	s := NewState()
+	if _, ok := s.Load().BalanceAge(); ok {
+		t.Errorf("balance age reported before load")
+	}
+
+	at := time.Now().Add(-time.Minute)
+	s.SetBalances(nil, at)
+	age, ok := s.Load().BalanceAge()
+	if !ok || age < time.Minute || age > 2*time.Minute {
+		t.Errorf("balance age = %s, %v", age, ok)
+	}
+	b, err := s.Load().Balance("btc")
+	if err != nil || b.Present || !b.UpdatedAt.Equal(at) {
+		t.Errorf("balance = %+v, %v", b, err)
+	}
+}
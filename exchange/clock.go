@@ -0,0 +1,51 @@
+package exchange
+
+import (
+	"fcoinExchange/conf"
+	"fcoinExchange/health"
+	"fcoinExchange/log"
+	"fcoinExchange/venue"
+	"time"
+)
+
+var (
+	clockSyncSamples = 3
+)
+
+// 定时同步所有账户的交易所服务器时间，共用同一个时钟的账户只同步一次，
+// 本地时间与服务器时间相差超过max_clock_skew时告警。
+// clock_sync_interval不大于0时不同步，使用本地时间签名，配置重新设置后恢复同步
+func (p *Group) AutoSyncClock(r *health.Run) {
+	lg := log.Named("clock")
+	lg.Infow("start auto sync clock task")
+	periodic(r, lg, func() int64 {
+		return conf.GetConfiguration().ClockSyncInterval
+	}, p.syncClocks)
+}
+
+//
+func (p *Group) syncClocks() {
+	var synced = make(map[venue.Clock]bool)
+	for _, ex := range p.Exchanges() {
+		client := ex.client()
+		clock := client.Clock()
+		if synced[clock] {
+			continue
+		}
+		synced[clock] = true
+
+		err := client.SyncClock(clockSyncSamples)
+		if err != nil {
+			ex.logger.Errorw("sync clock failed", "venue", client.Name(), "error", err)
+			continue
+		}
+
+		offset := clock.Offset()
+		ex.logger.Debugw("clock synced", "venue", client.Name(), "offset", offset, "rtt", clock.RTT())
+
+		skew := time.Duration(conf.GetConfiguration().MaxClockSkew) * time.Millisecond
+		if skew > 0 && (offset > skew || -offset > skew) {
+			ex.logger.Warnf("local clock differs from %s server by %s, exceeds max_clock_skew %s", client.Name(), offset, skew)
+		}
+	}
+}
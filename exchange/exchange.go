@@ -2,264 +2,574 @@ package exchange
 
 import (
 	"fcoinExchange/conf"
+	"fcoinExchange/event"
 	"fcoinExchange/fcoin"
+	"fcoinExchange/health"
+	"fcoinExchange/journal"
 	"fcoinExchange/log"
 	"fcoinExchange/model"
+	"fcoinExchange/notify"
+	"fcoinExchange/rebalance"
+	"fcoinExchange/schedule"
+	"fcoinExchange/venue"
 	"fmt"
-	"math"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
+
+	"go.uber.org/zap"
 )
 
 //
 type Exchange struct {
-	Symbol        string
-	BaseCurrency  string
-	QuoteCurrency string
-	//	SellNumber    float64
-	ExpectValue float64
-	Balance     map[string]*model.BalanceContext
-	Quote       *model.Quote
+	Name string
+
+	// 交易对 -> 订单和参数，symbols保存配置中的顺序
+	markets map[string]*Market
+	symbols []string
 
-	fcclient *fcoin.Client
+	// 交易所接口
+	vclient venue.Venue
 
 	config *model.Configuration
 	sync.RWMutex
 
-	shuadanChan chan int
-	accountChan chan int
+	risk   *riskCounter
+	ledger *Ledger
+	state  *State
+
+	// 订单日志，未配置journal_file时为nil
+	journal *journal.Journal
+
+	// 各交易对的再平衡记录
+	rebalancer *rebalance.Tracker
+
+	// 交易时间安排和当前状态，schedule和schedCfg只在AutoSchedule中修改
+	schedule *schedule.Schedule
+	schedCfg *model.ScheduleConfig
+	sched    *schedule.State
+
+	// 行情、余额、订单和配置变化通过事件总线通知各个任务
+	bus *event.Bus
+
+	// 带有账户字段的日志
+	logger *zap.SugaredLogger
+
+	// 连续失败的请求次数和最后一次请求成功的时间
+	apiErrors      int32
+	apiSucceededAt int64
+
+	// 启动对账成功后为1，之前不下单
+	reconciled int32
 }
 
 //
-func NewExchange(cfg *model.Configuration) (*Exchange, error) {
-	client := fcoin.NewClient(cfg.AppKey, cfg.AppSecret, cfg.RequestTimeout)
-	list, err := client.GetCurrencies()
+func NewExchange(cfg *model.Configuration, bus *event.Bus) (*Exchange, error) {
+	return NewExchangeWithVenue(cfg, bus, newClient(cfg.AppKey, cfg.AppSecret, cfg))
+}
+
+// 使用指定的交易所接口创建账户，例如模拟交易所
+func NewExchangeWithVenue(cfg *model.Configuration, bus *event.Bus, client venue.Venue) (*Exchange, error) {
+	jn, err := journal.Open(cfg.JournalFile)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("open order journal %s failed. %s", cfg.JournalFile, err)
+	}
+
+	var ex = &Exchange{
+		Name:       cfg.Name,
+		markets:    make(map[string]*Market),
+		symbols:    make([]string, 0),
+		vclient:    client,
+		config:     cfg,
+		risk:       new(riskCounter),
+		ledger:     NewLedger(),
+		state:      NewState(),
+		journal:    jn,
+		rebalancer: rebalance.NewTracker(),
+		bus:        bus,
+		logger:     log.Named("exchange").With(log.FieldAccount, cfg.Name),
+	}
+	if jn == nil {
+		ex.logger.Warnw("journal_file is empty, orders with ambiguous results can not be reconciled")
 	}
 
-	if list.Status != 0 {
-		return nil, fmt.Errorf("get currencies but return status is not 0")
+	for _, sc := range conf.Symbols(cfg) {
+		m, err := newMarket(client, sc, ex.logger)
+		if err != nil {
+			return nil, err
+		}
+		ex.markets[sc.Symbol] = m
+		ex.symbols = append(ex.symbols, sc.Symbol)
+	}
+	// 启动时即按交易时间安排设置交易对参数
+	ex.updateSchedule(time.Now())
+
+	return ex, nil
+}
+
+// 任务模式，所有任务由watchdog监控
+func (p *Exchange) AutoUpdate(wd *health.Watchdog) {
+	p.watch(wd)
+	wd.Go(p.Name+"/watch_config", p.stallTimeout(nil), p.WatchConfig)
+	wd.Go(p.Name+"/schedule", p.stallTimeout(nil), p.AutoSchedule)
+	wd.Go(p.Name+"/reconcile", p.stallTimeout(func(c *model.Configuration) int64 {
+		return c.Reconcile.Interval
+	}), p.AutoReconcile)
+	if p.configuration().AutoCheckOrder {
+		wd.Go(p.Name+"/check_orders", p.stallTimeout(func(c *model.Configuration) int64 {
+			return c.CheckOrderInterval
+		}), p.AutoCheckOrders)
+	}
+	time.Sleep(time.Second)
+	wd.Go(p.Name+"/shuadan", p.stallTimeout(func(c *model.Configuration) int64 {
+		return c.ShuaDanInterval
+	}), p.AutoShuaDan)
+}
+
+// 按配置的venue创建交易所接口，默认为fcoin
+func newClient(key, secret string, cfg *model.Configuration) venue.Venue {
+	switch cfg.Venue {
+	case "binance":
+		return venue.NewBinance(cfg.VenueURL, key, secret, cfg.RequestTimeout)
+	}
+	return venue.NewFCoin(newFCoinClient(key, secret, cfg))
+}
+
+// 创建fcoin客户端，配置了record_cassette时录制所有请求和返回
+func newFCoinClient(key, secret string, cfg *model.Configuration) *fcoin.Client {
+	client := fcoin.NewClient(key, secret, cfg.RequestTimeout)
+	if cfg.RecordCassette == "" {
+		return client
+	}
+
+	rec, err := fcoin.RecordTo(cfg.RecordCassette)
+	if err != nil {
+		log.Logger.Errorf("open cassette %s failed, requests not recorded. %s", cfg.RecordCassette, err)
+		return client
+	}
+	client.SetTransport(rec.Wrap(client.Transport()))
+	return client
+}
+
+// 返回当前使用的配置快照
+func (p *Exchange) configuration() *model.Configuration {
+	p.RLock()
+	defer p.RUnlock()
+	return p.config
+}
+
+//
+func (p *Exchange) client() venue.Venue {
+	p.RLock()
+	defer p.RUnlock()
+	return p.vclient
+}
+
+// 按配置顺序返回所有交易对
+func (p *Exchange) Markets() []*Market {
+	p.RLock()
+	defer p.RUnlock()
+	var list = make([]*Market, 0, len(p.symbols))
+	for _, s := range p.symbols {
+		list = append(list, p.markets[s])
+	}
+	return list
+}
+
+//
+func (p *Exchange) Market(symbol string) (*Market, error) {
+	p.RLock()
+	defer p.RUnlock()
+	m, ok := p.markets[symbol]
+	if !ok {
+		return nil, fmt.Errorf("symbol %s is not traded by account %s", symbol, p.Name)
+	}
+	return m, nil
+}
+
+// 订阅配置变更，并在运行时重新应用本账户的交易对和账户密钥
+func (p *Exchange) WatchConfig(r *health.Run) {
+	sub := p.bus.Subscribe(p.Name+"/watch_config", event.Options{
+		Types:  []event.Type{event.TypeConfigChanged},
+		Policy: event.PolicyCoalesce,
+	})
+	defer sub.Close()
+
+	for {
+		r.Idle()
+		e, ok := sub.Next(r.Done())
+		if !ok {
+			return
+		}
+		r.Beat()
+
+		c := e.(*event.ConfigChanged)
+		cfg := conf.Account(c.New, p.Name)
+		if cfg == nil {
+			p.logger.Errorf("account removed from configuration, keep running with last configuration")
+			continue
+		}
+		p.ApplyConfig(p.configuration(), cfg)
+	}
+}
+
+// 应用新的配置，已有的交易对只更新参数，新增的交易对无法识别时忽略该交易对
+func (p *Exchange) ApplyConfig(old, cfg *model.Configuration) {
+	var client = p.client()
+	if old.AppKey != cfg.AppKey || old.AppSecret != cfg.AppSecret || old.RequestTimeout != cfg.RequestTimeout ||
+		old.RecordCassette != cfg.RecordCassette || old.Venue != cfg.Venue || old.VenueURL != cfg.VenueURL {
+		p.logger.Infof("account credentials, request timeout, record_cassette or venue changed, rebuild venue client")
+		client = newClient(cfg.AppKey, cfg.AppSecret, cfg)
+	}
+
+	var jn = p.orderJournal()
+	if old.JournalFile != cfg.JournalFile {
+		j, err := journal.Open(cfg.JournalFile)
+		if err != nil {
+			p.logger.Errorw("open order journal failed, keep current journal", "journal_file", cfg.JournalFile, "error", err)
+		} else {
+			p.logger.Infow("journal_file changed", "journal_file", cfg.JournalFile)
+			jn = j
+		}
 	}
 
 	var (
-		base  string
-		quote string
+		markets = make(map[string]*Market)
+		symbols = make([]string, 0)
 	)
-	for _, v := range list.Data {
-		if strings.HasPrefix(cfg.Symbol, v) {
-			base = v
-			quote = cfg.Symbol[len(v):]
-			break
+	for _, sc := range conf.Symbols(cfg) {
+		m, err := p.Market(sc.Symbol)
+		if err == nil {
+			m.setParams(sc)
+		} else {
+			m, err = newMarket(client, sc, p.logger)
+			if err != nil {
+				p.logger.Errorw("add symbol failed", log.FieldSymbol, sc.Symbol, "error", err)
+				continue
+			}
+			p.logger.Infow("add symbol", log.FieldSymbol, sc.Symbol)
 		}
+		markets[sc.Symbol] = m
+		symbols = append(symbols, sc.Symbol)
 	}
 
-	if !strings.Contains(strings.Join(list.Data, ","), quote) {
-		return nil, fmt.Errorf("symbol %s does not support", cfg.Symbol)
+	if len(symbols) == 0 {
+		p.logger.Errorf("no symbol available in new configuration, keep current symbols")
+		return
 	}
 
-	return &Exchange{
-		Symbol:        cfg.Symbol,
-		BaseCurrency:  base,
-		QuoteCurrency: quote,
-		fcclient:      client,
-		config:        cfg,
-		Balance:       make(map[string]*model.BalanceContext),
-		accountChan:   make(chan int, 1),
-		shuadanChan:   make(chan int, 1),
-	}, nil
+	p.Lock()
+	for _, s := range p.symbols {
+		if _, ok := markets[s]; !ok {
+			p.logger.Infow("remove symbol", log.FieldSymbol, s)
+		}
+	}
+	p.config = cfg
+	p.vclient = client
+	p.journal = jn
+	p.markets = markets
+	p.symbols = symbols
+	p.Unlock()
 }
 
-func (p *Exchange) AutoUpdate() {
-	//go p.AutoUpdateTicker()
-	//go p.AutoUpdateBalance()
-	if p.config.AutoCheckOrder {
-		go p.AutoCheckOrders()
+// 任务的执行间隔不小于500毫秒
+func checkInterval(interval int64) int64 {
+	if interval < 500 {
+		return 500
 	}
-	time.Sleep(time.Second)
-	go p.AutoShuaDan()
+	return interval
+}
+
+// 配置中的间隔发生变化时重置定时器
+func resetTicker(tk *time.Ticker, current *int64, interval int64) {
+	interval = checkInterval(interval)
+	if interval == *current {
+		return
+	}
+	log.Logger.Infof("interval changed from %d to %d", *current, interval)
+	*current = interval
+	tk.Reset(time.Duration(interval) * time.Millisecond)
 }
 
-// 自动更新行情信息
-func (p *Exchange) AutoUpdateTicker() {
-	log.Logger.Infof("start auto update ticker task")
+// 自动更新所有交易对的行情信息
+func (p *Exchange) AutoUpdateTicker(r *health.Run) {
+	defer notify.Recover(p.Name, "update ticker")
+	p.logger.Infof("start auto update ticker task")
 	var (
-		interval int64 = conf.GetConfiguration().UpdateTickerInterval
-		ticker   *model.Ticker
-		quote    *model.Quote
+		interval int64 = p.configuration().UpdateTickerInterval
 		err      error
 	)
 
 	if interval < 500 {
-		log.Logger.Infof("update ticker interval less than 500, set it to 500")
+		p.logger.Infof("update ticker interval less than 500, set it to 500")
 		interval = 500
 	}
 
 	tk := time.NewTicker(time.Duration(interval) * time.Millisecond)
+	defer tk.Stop()
 
 	for {
-		ticker, err = p.fcclient.GetTicker(p.Symbol)
-		if err != nil {
-			log.Logger.Errorf("get %s ticker failed. %s\n", p.Symbol, err)
-		} else {
-			if ticker.Status != 0 {
-				log.Logger.Errorf("get ticker but return status is %d", ticker.Status)
-			} else {
-				quote, err = fcoin.ParseTicker(ticker)
-				if err != nil {
-					log.Logger.Errorf("%s\n", err)
-				} else {
-					p.Quote = quote
-				}
-
+		r.Beat()
+		for _, m := range p.Markets() {
+			_, err = p.GetCurrentQuote(m.Symbol)
+			if err != nil {
+				m.logger.Errorw("get ticker failed", "error", err)
 			}
 		}
-		<-tk.C
+		select {
+		case <-tk.C:
+		case <-r.Done():
+			return
+		}
+		resetTicker(tk, &interval, p.configuration().UpdateTickerInterval)
 	}
 }
 
-func (p *Exchange) AutoCheckOrders() {
-	log.Logger.Infof("start auto check order task")
+func (p *Exchange) AutoCheckOrders(r *health.Run) {
+	defer notify.Recover(p.Name, "check orders")
+	p.logger.Infof("start auto check order task")
 	var (
-		interval int64 = conf.GetConfiguration().CheckOrderInterval
-		orders   *model.OrderList
-		err      error
-		querys   map[string]string = map[string]string{
-			"symbol": p.Symbol,
-			"limit":  "10",
-		}
-		states     []string = []string{"submitted", "partial_filled"}
-		serverTime *model.ServerTime
-		timeDValue int64
+		interval int64 = p.configuration().CheckOrderInterval
 	)
 
 	if interval < 500 {
-		log.Logger.Infof("check order interval less than 500, set it to 500")
+		p.logger.Infof("check order interval less than 500, set it to 500")
 		interval = 500
 	}
 
 	tk := time.NewTicker(time.Duration(interval) * time.Millisecond)
+	defer tk.Stop()
 
 	for {
-		for _, state := range states {
-			querys["states"] = state
-			orders, err = p.fcclient.ListOrders(querys)
-			if err != nil {
-				log.Logger.Errorf("get orders failed. %s\n", err)
-			} else {
-				if orders.Status != 0 {
-					log.Logger.Errorf("get orderlist but return status is %d", orders.Status)
-				} else {
-					serverTime, err = p.fcclient.GetServerTime()
-					if err != nil {
-						log.Logger.Errorf("get server time failed. %v", serverTime)
-						serverTime = new(model.ServerTime)
-						serverTime.Data = time.Now().UnixNano() / 1000000
-					}
-					for _, order := range orders.Data {
-						log.Logger.Infof("order id %s, created at: %d", order.Id, order.CreatedAt)
-						timeDValue = order.CreatedAt - serverTime.Data
-						log.Logger.Infof("time d_value: %d", timeDValue)
-						if math.Abs(float64(timeDValue)) > float64(p.config.RevokeOrderTime) {
-							// invoke order
-							log.Logger.Infof("cancel order id %s", order.Id)
-							var corder *model.CancelOrder
-							corder, err = p.fcclient.CancelOrder(order.Id)
-							if err != nil {
-								log.Logger.Infof("cancel order failed. %s", err)
-							}
-							if corder.Status != 0 {
-								log.Logger.Infof("cancel order failed. %v", corder)
-
-							}
-						}
-						time.Sleep(time.Second)
-					}
-				}
-			}
+		r.Beat()
+		for _, m := range p.Markets() {
+			p.ManageOrders(r, m)
+		}
+		select {
+		case <-tk.C:
+		case <-r.Done():
+			return
 		}
-		<-tk.C
+		resetTicker(tk, &interval, p.configuration().CheckOrderInterval)
 	}
 }
 
-func (p *Exchange) GetQuote() *model.Quote {
-	p.RLock()
-	defer p.RUnlock()
-	return p.Quote
+// strategy为下单的策略名称，记录在日志中
+func (p *Exchange) Buy(m *Market, strategy, price, amount string) (string, error) {
+	return p.createOrder(m, strategy, "buy", price, amount)
 }
 
-func (p *Exchange) Buy(price, amount string) (*model.Order, error) {
-	return p.fcclient.CreateOrder(p.Symbol, "buy", "limit", price, amount)
+//
+func (p *Exchange) Sell(m *Market, strategy, price, amount string) (string, error) {
+	return p.createOrder(m, strategy, "sell", price, amount)
 }
 
-func (p *Exchange) Sell(price, amount string) (*model.Order, error) {
-	return p.fcclient.CreateOrder(p.Symbol, "sell", "limit", price, amount)
+// 提交限价单，返回订单号
+func (p *Exchange) createOrder(m *Market, strategy, side, price, amount string) (string, error) {
+	return p.PlaceOrder(m, strategy, &venue.OrderRequest{
+		Symbol: m.Symbol,
+		Side:   side,
+		Type:   venue.TypeLimit,
+		Price:  price,
+		Amount: amount,
+	})
 }
 
-func (p *Exchange) GetAccountBalance() (*model.AccountBalance, error) {
-	return p.fcclient.GetBalance()
+// 提交订单前检查自成交、风险限制并在账本中预留资金，提交失败时释放预留的资金，返回订单号。
+// 市价单、IOC和FOK订单提交后立即查询成交结果，已经结束的订单不再跟踪
+func (p *Exchange) PlaceOrder(m *Market, strategy string, req *venue.OrderRequest) (string, error) {
+	lg := m.logger.With(
+		log.FieldRequestId, log.NewRequestId(),
+		log.FieldStrategy, strategy,
+		log.FieldSide, req.Side,
+		log.FieldPrice, req.Price,
+		log.FieldAmount, req.Amount,
+		"type", req.Type,
+		"time_in_force", req.TimeInForce,
+		"post_only", req.PostOnly,
+	)
+
+	err := req.Validate()
+	if err != nil {
+		lg.Errorw("invalid order", "error", err)
+		return "", err
+	}
+	if !p.Reconciled() {
+		lg.Infow("order rejected", "error", ErrNotReconciled)
+		return "", ErrNotReconciled
+	}
+
+	o, price, err := p.guard(m, lg, req.Side, req.Price, req.Amount)
+	if err != nil {
+		return "", err
+	}
+	if price != req.Price {
+		r := *req
+		r.Price = price
+		req = &r
+		lg = lg.With("adjusted_price", price)
+	}
+
+	id, err := p.submitOrder(m, lg, strategy, req)
+	if err != nil {
+		m.Orders.Abort(o)
+		lg.Errorw("create order failed", "error", err)
+		return "", err
+	}
+
+	m.Orders.Commit(o, id)
+	lg.Infow("order created", log.FieldOrderId, id)
+	p.bus.Publish(&event.OrderUpdated{
+		Account: p.Name,
+		Symbol:  m.Symbol,
+		OrderId: id,
+		Side:    req.Side,
+		Price:   req.Price,
+		Amount:  req.Amount,
+		State:   "submitted",
+		Time:    time.Now(),
+	})
+
+	if !req.Resting() {
+		p.settleImmediate(m, lg, id)
+	}
+	return id, nil
 }
 
-func (p *Exchange) CancelOrders() {
-	var (
-		orders *model.OrderList
-		err    error
-		querys map[string]string = map[string]string{
-			"symbol": p.Symbol,
-			"limit":  "10",
-		}
-		states     []string = []string{"submitted", "partial_filled"}
-		serverTime *model.ServerTime
-		timeDValue int64
-	)
+// 市价单按当前对手价估算价格和base currency数量，用于风险检查和预留资金
+func (p *Exchange) estimate(m *Market, req *venue.OrderRequest) (float64, float64, error) {
+	amount, err := strconv.ParseFloat(req.Amount, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if req.Type != venue.TypeMarket {
+		price, err := strconv.ParseFloat(req.Price, 64)
+		return price, amount, err
+	}
 
-	for _, state := range states {
-		querys["states"] = state
-		orders, err = p.fcclient.ListOrders(querys)
-		if err != nil {
-			log.Logger.Errorf("get orders failed. %s\n", err)
-		} else {
-			if orders.Status != 0 {
-				log.Logger.Errorf("get orderlist but return status is %d", orders.Status)
-			} else {
-				serverTime, err = p.fcclient.GetServerTime()
-				if err != nil {
-					log.Logger.Errorf("get server time failed. %v", serverTime)
-					serverTime = new(model.ServerTime)
-					serverTime.Data = time.Now().UnixNano() / 1000000
-				}
-				for _, order := range orders.Data {
-					log.Logger.Infof("order id %s, created at: %d", order.Id, order.CreatedAt)
-					timeDValue = order.CreatedAt - serverTime.Data
-					log.Logger.Infof("time d_value: %d", timeDValue)
-					if math.Abs(float64(timeDValue)) > float64(p.config.RevokeOrderTime) {
-						// invoke order
-						log.Logger.Infof("cancel order id %s", order.Id)
-						var corder *model.CancelOrder
-						corder, err = p.fcclient.CancelOrder(order.Id)
-						if err != nil {
-							log.Logger.Infof("cancel order failed. %s", err)
-						} else if corder.Status != 0 {
-							log.Logger.Infof("cancel order failed. %v", corder)
-						}
-					}
-					time.Sleep(time.Second)
-				}
-			}
-		}
+	quote, err := p.GetCurrentQuote(m.Symbol)
+	if err != nil {
+		return 0, 0, err
 	}
+	if req.Side == "sell" {
+		return quote.MaxBuyOnePrice, amount, nil
+	}
+	if quote.MinSellOnePrice <= 0 {
+		return 0, 0, fmt.Errorf("no ask price to estimate market buy order")
+	}
+	return quote.MinSellOnePrice, amount / quote.MinSellOnePrice, nil
 }
 
-func (p *Exchange) GetCurrentQuote() (*model.Quote, error) {
-	ticker, err := p.fcclient.GetTicker(p.Symbol)
+//
+func (p *Exchange) submitOrder(m *Market, lg *zap.SugaredLogger, strategy string, req *venue.OrderRequest) (string, error) {
+	pr, a, err := p.estimate(m, req)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	err = p.checkRisk(pr, a)
+	if err != nil {
+		lg.Errorw("order rejected by risk limits", "error", err)
+		return "", err
+	}
+
+	var currency = m.BaseCurrency
+	if req.Side == "buy" {
+		currency = m.QuoteCurrency
+	}
+	r, err := p.ledger.Reserve(currency, req.Side, pr, a)
+	if err != nil {
+		lg.Errorw("order rejected by ledger", "error", err)
+		return "", err
+	}
+
+	id, err := p.send(m, lg, strategy, req)
+	if err != nil {
+		p.ledger.Release(r)
+		return "", err
+	}
+
+	p.ledger.Bind(r, id)
+	return id, nil
+}
+
+// 查询不会留在订单簿上的订单的成交结果，订单已经结束时结算并停止跟踪，
+// 未结束或者查询失败时由订单检查任务处理
+func (p *Exchange) settleImmediate(m *Market, lg *zap.SugaredLogger, id string) {
+	order, err := p.client().Order(m.Symbol, id)
+	p.apiResult("get order", err)
+	if err != nil {
+		lg.Warnw("get order result failed", log.FieldOrderId, id, "error", err)
+		return
 	}
 
-	if ticker.Status != 0 {
+	filled, _ := strconv.ParseFloat(order.FilledAmount, 64)
+	p.ledger.Settle(id, filled)
+	p.publishOrder(m, order, filled)
+
+	switch order.State {
+	case "filled", "canceled", "partial_canceled":
+		p.ledger.Finish(id)
+		m.Orders.Untrack(id)
+		p.journalClose(id)
+		lg.Infow("order finished", log.FieldOrderId, id, "state", order.State, "filled", filled)
+	}
+}
+
+// 刷新账户余额并与本地账本对账
+func (p *Exchange) UpdateBalance() error {
+	requestedAt := time.Now()
+	balances, err := p.GetAccountBalance()
+	p.apiResult("get balance", err)
+	if err != nil {
+		return err
+	}
+
+	err = p.ledger.Reconcile(balances, requestedAt)
+	if err != nil {
+		return err
+	}
+
+	p.state.SetBalances(balances, requestedAt)
+	p.checkLowBalance()
+	p.bus.Publish(&event.BalanceUpdated{
+		Account:  p.Name,
+		Balances: balances,
+		Time:     requestedAt,
+	})
+	return nil
+}
+
+// 账本中可用于交易对一次买卖的资金是否足够，账户余额尚未加载时不进行交易
+func (p *Exchange) canTrade(m *Market, price, amount float64) bool {
+	age, ok := p.state.Load().BalanceAge()
+	if !ok {
+		m.logger.Infof("%s", ErrBalanceNotLoaded)
+		return false
+	}
+	m.logger.Debugf("balance age %s", age)
+
+	return p.ledger.Spendable(m.BaseCurrency) >= amount && p.ledger.Spendable(m.QuoteCurrency) >= price*amount
+}
+
+func (p *Exchange) GetAccountBalance() ([]*model.BalanceContext, error) {
+	return p.client().Balances()
+}
+
+// 获取交易对的最新行情并更新到State中
+func (p *Exchange) GetCurrentQuote(symbol string) (*model.Quote, error) {
+	quote, err := p.client().Ticker(symbol)
+	p.apiResult("get ticker", err)
+	if err != nil {
 		return nil, err
 	}
 
-	return fcoin.ParseTicker(ticker)
+	p.state.SetQuote(symbol, quote)
+	p.bus.Publish(&event.QuoteUpdated{
+		Account: p.Name,
+		Symbol:  symbol,
+		Quote:   quote,
+		Time:    time.Now(),
+	})
+	return quote, nil
 }
@@ -0,0 +1,255 @@
+package exchange
+
+import (
+	"fcoinExchange/conf"
+	"fcoinExchange/health"
+	"fcoinExchange/log"
+	"fcoinExchange/model"
+	"fcoinExchange/notify"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 多个账户的汇总视图
+type Group struct {
+	exchanges []*Exchange
+	// 账户第一次汇总时的价值，用于计算盈亏
+	start  map[string]float64
+	logger *zap.SugaredLogger
+	sync.Mutex
+}
+
+// 单个账户中以同一个quote currency计价的所有交易对的汇总
+type AccountSummary struct {
+	Name          string
+	QuoteCurrency string
+	Symbols       []string
+	Balance       map[string]*model.BalanceContext
+	// quote currency余额加上各交易对的base currency余额按中间价折算的价值，
+	// 多个交易对共用quote currency时quote currency余额只计算一次
+	Value float64
+	PnL   float64
+}
+
+// 所有账户的汇总
+type Summary struct {
+	Accounts []*AccountSummary
+	// 币种 -> 所有账户的余额合计
+	Balances map[string]float64
+	// quote currency -> 所有账户的盈亏合计
+	PnL map[string]float64
+}
+
+//
+func NewGroup() *Group {
+	return &Group{
+		exchanges: make([]*Exchange, 0),
+		start:     make(map[string]float64),
+		logger:    log.Named("group"),
+	}
+}
+
+//
+func (p *Group) Add(ex *Exchange) {
+	p.Lock()
+	defer p.Unlock()
+	p.exchanges = append(p.exchanges, ex)
+}
+
+//
+func (p *Group) Exchanges() []*Exchange {
+	p.Lock()
+	defer p.Unlock()
+	return append([]*Exchange(nil), p.exchanges...)
+}
+
+// 查询每个账户的余额和行情并汇总，单个账户查询失败时不影响其他账户
+func (p *Group) Summary() *Summary {
+	var sm = &Summary{
+		Accounts: make([]*AccountSummary, 0),
+		Balances: make(map[string]float64),
+		PnL:      make(map[string]float64),
+	}
+
+	for _, ex := range p.Exchanges() {
+		balances, err := ex.GetAccountBalance()
+		if err != nil {
+			ex.logger.Errorw("summarize account failed", "error", err)
+			continue
+		}
+
+		var bm = make(map[string]*model.BalanceContext)
+		for _, v := range balances {
+			bm[v.Currency] = v
+			total, err := strconv.ParseFloat(v.Balance, 64)
+			if err != nil {
+				continue
+			}
+			sm.Balances[v.Currency] += total
+		}
+
+		// 按quote currency分组，保持配置顺序
+		var (
+			quotes  = make([]string, 0)
+			markets = make(map[string][]*Market)
+		)
+		for _, m := range ex.Markets() {
+			if _, ok := markets[m.QuoteCurrency]; !ok {
+				quotes = append(quotes, m.QuoteCurrency)
+			}
+			markets[m.QuoteCurrency] = append(markets[m.QuoteCurrency], m)
+		}
+
+		for _, quote := range quotes {
+			as, err := p.summarize(ex, quote, markets[quote], bm)
+			if err != nil {
+				ex.logger.Errorw("summarize account failed", "quote_currency", quote, "error", err)
+				continue
+			}
+			sm.Accounts = append(sm.Accounts, as)
+			sm.PnL[as.QuoteCurrency] += as.PnL
+		}
+	}
+
+	return sm
+}
+
+// 所有交易对的行情都查询成功时才汇总，避免缺少部分币种的价值时盈亏跳变
+func (p *Group) summarize(ex *Exchange, quote string, markets []*Market, bm map[string]*model.BalanceContext) (*AccountSummary, error) {
+	var as = &AccountSummary{
+		Name:          ex.Name,
+		QuoteCurrency: quote,
+		Symbols:       make([]string, 0, len(markets)),
+		Balance:       bm,
+	}
+
+	var valued = make(map[string]bool)
+	for _, m := range markets {
+		as.Symbols = append(as.Symbols, m.Symbol)
+		if valued[m.BaseCurrency] {
+			continue
+		}
+		qt, err := ex.GetCurrentQuote(m.Symbol)
+		if err != nil {
+			return nil, fmt.Errorf("get %s ticker failed. %s", m.Symbol, err)
+		}
+		valued[m.BaseCurrency] = true
+		as.Value += balanceTotal(bm, m.BaseCurrency) * (qt.MaxBuyOnePrice + qt.MinSellOnePrice) / 2
+	}
+	as.Value += balanceTotal(bm, quote)
+
+	key := ex.Name + "/" + quote
+	p.Lock()
+	if _, ok := p.start[key]; !ok {
+		p.start[key] = as.Value
+	}
+	as.PnL = as.Value - p.start[key]
+	p.Unlock()
+
+	return as, nil
+}
+
+// 币种的总余额，没有该币种时为0
+func balanceTotal(bm map[string]*model.BalanceContext, currency string) float64 {
+	v, ok := bm[currency]
+	if !ok {
+		return 0
+	}
+	total, _ := strconv.ParseFloat(v.Balance, 64)
+	return total
+}
+
+// 定时输出所有账户的汇总信息，report_interval不大于0时暂停，配置重新设置后恢复
+func (p *Group) AutoReport(r *health.Run) {
+	p.logger.Infof("start auto report task")
+	periodic(r, p.logger, func() int64 {
+		return conf.GetConfiguration().ReportInterval
+	}, p.report)
+}
+
+//
+func (p *Group) report() {
+	sm := p.Summary()
+	for _, v := range sm.Accounts {
+		p.logger.Infow("account summary",
+			log.FieldAccount, v.Name,
+			"symbols", v.Symbols,
+			"quote_currency", v.QuoteCurrency,
+			"value", v.Value,
+			"pnl", v.PnL,
+		)
+	}
+	p.logger.Infow("all accounts summary", "balances", sm.Balances, "pnl", sm.PnL)
+}
+
+// 每天在daily_summary_at发送所有账户的盈亏汇总
+func (p *Group) AutoDailySummary(r *health.Run) {
+	defer notify.Recover("", "daily summary")
+
+	p.logger.Infof("start daily summary task")
+	for {
+		// 等待期间不判断为卡住
+		r.Idle()
+		at := conf.GetConfiguration().Notify.DailySummaryAt
+		if at == "" {
+			// 未配置时等待配置更新
+			if !sleep(r, time.Minute) {
+				return
+			}
+			continue
+		}
+
+		next, err := nextDaily(time.Now(), at)
+		if err != nil {
+			p.logger.Errorw("invalid daily_summary_at", "error", err)
+			if !sleep(r, time.Minute) {
+				return
+			}
+			continue
+		}
+		if !sleep(r, time.Until(next)) {
+			return
+		}
+		r.Beat()
+
+		// 等待期间配置可能已经变化
+		if conf.GetConfiguration().Notify.DailySummaryAt != at {
+			continue
+		}
+
+		sm := p.Summary()
+		var b strings.Builder
+		for _, v := range sm.Accounts {
+			fmt.Fprintf(&b, "%s %s value: %.8f %s, pnl: %.8f\n", v.Name, strings.Join(v.Symbols, ","), v.Value, v.QuoteCurrency, v.PnL)
+		}
+		fmt.Fprintf(&b, "pnl: %v", sm.PnL)
+
+		notify.Send(&notify.Event{
+			Kind:     notify.KindDailySummary,
+			Severity: notify.SeverityInfo,
+			Key:      next.Format("2006-01-02"),
+			Title:    "daily pnl summary " + next.Format("2006-01-02"),
+			Message:  b.String(),
+		})
+	}
+}
+
+// 返回now之后下一个at时刻，at格式为15:04
+func nextDaily(now time.Time, at string) (time.Time, error) {
+	t, err := time.ParseInLocation("15:04", at, now.Location())
+	if err != nil {
+		return now, fmt.Errorf("daily_summary_at %s need be format as 15:04", at)
+	}
+
+	y, m, d := now.Date()
+	next := time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
@@ -0,0 +1,61 @@
+package exchange
+
+import (
+	"fcoinExchange/model"
+	"math"
+	"testing"
+)
+
+// 多个交易对共用quote currency时quote currency余额只计算一次
+func TestGroupSummarySharedQuote(t *testing.T) {
+	v := newFakeVenue()
+	v.setQuote("btcusdt", 99, 101)
+	v.setQuote("ftusdt", 0.09, 0.11)
+	v.setBalances(
+		&model.BalanceContext{Currency: "btc", Balance: "1"},
+		&model.BalanceContext{Currency: "ft", Balance: "1000"},
+		&model.BalanceContext{Currency: "usdt", Balance: "500"},
+	)
+	ex := newTestExchange(t, v, &model.Configuration{
+		Symbols: []*model.SymbolConfig{{Symbol: "btcusdt"}, {Symbol: "ftusdt"}},
+	})
+
+	other := newFakeVenue()
+	other.setQuote("btcusdt", 99, 101)
+	other.setBalances(&model.BalanceContext{Currency: "usdt", Balance: "50"})
+	ex2 := newTestExchange(t, other, &model.Configuration{Name: "other"})
+
+	g := NewGroup()
+	g.Add(ex)
+	g.Add(ex2)
+
+	sm := g.Summary()
+	if len(sm.Accounts) != 2 {
+		t.Fatalf("%d account summaries, want one per account and quote currency", len(sm.Accounts))
+	}
+	as := sm.Accounts[0]
+	if as.Name != "test" || as.QuoteCurrency != "usdt" || len(as.Symbols) != 2 {
+		t.Errorf("summary = %+v", as)
+	}
+	// btc 1 * 100 + ft 1000 * 0.1 + usdt 500
+	if math.Abs(as.Value-700) > 1e-9 || as.PnL != 0 {
+		t.Errorf("value %v pnl %v, want 700 and 0", as.Value, as.PnL)
+	}
+	if sm.Balances["usdt"] != 550 {
+		t.Errorf("usdt balance %v, want 550", sm.Balances["usdt"])
+	}
+
+	v.setBalances(
+		&model.BalanceContext{Currency: "btc", Balance: "1"},
+		&model.BalanceContext{Currency: "ft", Balance: "1000"},
+		&model.BalanceContext{Currency: "usdt", Balance: "600"},
+	)
+	other.setBalances(&model.BalanceContext{Currency: "usdt", Balance: "60"})
+	sm = g.Summary()
+	if pnl := sm.Accounts[0].PnL; math.Abs(pnl-100) > 1e-9 {
+		t.Errorf("account pnl %v, want 100", pnl)
+	}
+	if pnl := sm.PnL["usdt"]; math.Abs(pnl-110) > 1e-9 {
+		t.Errorf("usdt pnl %v, want 110", pnl)
+	}
+}
@@ -0,0 +1,121 @@
+package exchange
+
+import (
+	"errors"
+	"fcoinExchange/metrics"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// 新订单会与本账户挂单成交时的处理方式
+const (
+	// 撤销会成交的挂单后提交新订单
+	STPCancelOldest string = "cancel_oldest"
+	// 撤销新订单，保留挂单，与reject相同
+	STPCancelNewest string = "cancel_newest"
+	// 拒绝新订单并返回错误
+	STPReject string = "reject"
+	// 限价单改为会成交的挂单价格外一个最小价格单位后提交，市价单拒绝
+	STPAdjust string = "adjust"
+)
+
+var (
+	ErrSelfTrade = errors.New("order would trade against own resting order")
+)
+
+// 提交订单前检查新订单是否会与本账户的挂单或者正在提交的订单成交，
+// 通过检查时返回已登记的订单和提交使用的价格，adjust时价格可能与请求不同，
+// 提交结束后需要调用Commit或者Abort。
+// 被拦截、撤销挂单和调整价格的订单分别计入self_trade.<account>.<symbol>.blocked、canceled和adjusted
+func (p *Exchange) guard(m *Market, lg *zap.SugaredLogger, side, price, amount string) (*TrackedOrder, string, error) {
+	mode := p.configuration().SelfTradePrevention
+	if mode == "" {
+		mode = STPReject
+	}
+
+	o, crossing := m.Orders.Begin(side, price, amount)
+	if o != nil {
+		return o, price, nil
+	}
+
+	var ids = make([]string, 0, len(crossing))
+	for _, v := range crossing {
+		ids = append(ids, v.Id)
+	}
+
+	switch mode {
+	case STPCancelOldest:
+		lg.Infow("new order would cross own orders, cancel them", "crossing", ids)
+		for _, v := range crossing {
+			// 正在提交的订单还没有订单号，无法撤销
+			if v.Id == "" {
+				lg.Infow("own order still submitting, block new order")
+				return p.blockSelfTrade(m)
+			}
+			if err := p.CancelOrder(m, v.Id); err != nil {
+				return p.blockSelfTrade(m)
+			}
+		}
+
+		o, crossing = m.Orders.Begin(side, price, amount)
+		if o != nil {
+			metrics.Inc("self_trade", p.Name, m.Symbol, "canceled")
+			return o, price, nil
+		}
+		lg.Errorw("new order still crosses own orders after cancel")
+	case STPAdjust:
+		adjusted, ok := adjustPrice(m, side, price, crossing)
+		if !ok {
+			lg.Infow("new order would cross own orders and can not be repriced, rejected", "crossing", ids)
+			break
+		}
+
+		o, crossing = m.Orders.Begin(side, adjusted, amount)
+		if o != nil {
+			lg.Infow("new order would cross own orders, repriced", "crossing", ids, "adjusted_price", adjusted)
+			metrics.Inc("self_trade", p.Name, m.Symbol, "adjusted")
+			return o, adjusted, nil
+		}
+		lg.Infow("repriced order still crosses own orders, rejected", "crossing", ids, "adjusted_price", adjusted)
+	default:
+		lg.Errorw("new order would cross own orders, rejected", "crossing", ids)
+	}
+
+	return p.blockSelfTrade(m)
+}
+
+//
+func (p *Exchange) blockSelfTrade(m *Market) (*TrackedOrder, string, error) {
+	metrics.Inc("self_trade", p.Name, m.Symbol, "blocked")
+	return nil, "", ErrSelfTrade
+}
+
+// 买单改为会成交的最低卖单价格减一个最小价格单位，卖单改为最高买单价格加一个最小价格单位。
+// 市价单、会成交的订单中有市价单或者调整后价格不大于0时返回false
+func adjustPrice(m *Market, side, price string, crossing []*TrackedOrder) (string, bool) {
+	if price == "" {
+		return "", false
+	}
+
+	var limit float64
+	for i, v := range crossing {
+		rp, err := strconv.ParseFloat(v.Price, 64)
+		if err != nil {
+			return "", false
+		}
+		if i == 0 || (side == "buy" && rp < limit) || (side == "sell" && rp > limit) {
+			limit = rp
+		}
+	}
+
+	if side == "buy" {
+		limit -= m.Tick()
+	} else {
+		limit += m.Tick()
+	}
+	if limit <= 0 {
+		return "", false
+	}
+	return m.FormatPrice(limit), true
+}
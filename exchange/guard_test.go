@@ -0,0 +1,60 @@
+package exchange
+
+import (
+	"fcoinExchange/metrics"
+	"fcoinExchange/model"
+	"testing"
+)
+
+//
+func TestGuardSelfTrade(t *testing.T) {
+	cases := []struct {
+		mode    string
+		side    string
+		price   string
+		want    string
+		blocked bool
+	}{
+		{mode: STPReject, side: "buy", price: "101.00", blocked: true},
+		{mode: STPCancelNewest, side: "sell", price: "99.00", blocked: true},
+		{mode: STPAdjust, side: "buy", price: "101.00", want: "99.99"},
+		{mode: STPAdjust, side: "sell", price: "99.00", want: "99.01"},
+		{mode: STPAdjust, side: "buy", price: "", blocked: true},
+		{mode: STPReject, side: "buy", price: "99.50", want: "99.50"},
+	}
+
+	for _, c := range cases {
+		ex := newTestExchange(t, newFakeVenue(), &model.Configuration{SelfTradePrevention: c.mode})
+		m, err := ex.Market("btcusdt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Orders.Track(&TrackedOrder{Id: "s1", Side: "sell", Price: "100.00", Amount: "1"})
+		m.Orders.Track(&TrackedOrder{Id: "s2", Side: "sell", Price: "100.50", Amount: "1"})
+		m.Orders.Track(&TrackedOrder{Id: "b1", Side: "buy", Price: "99.00", Amount: "1"})
+
+		blocked := metrics.Get("self_trade", ex.Name, m.Symbol, "blocked")
+		o, price, err := ex.guard(m, m.logger, c.side, c.price, "1")
+		if c.blocked {
+			if err != ErrSelfTrade || o != nil {
+				t.Errorf("%s %s %s: got %v, want ErrSelfTrade", c.mode, c.side, c.price, err)
+			}
+			if metrics.Get("self_trade", ex.Name, m.Symbol, "blocked") != blocked+1 {
+				t.Errorf("%s %s %s: blocked order not counted", c.mode, c.side, c.price)
+			}
+			continue
+		}
+
+		if err != nil || o == nil || price != c.want {
+			t.Errorf("%s %s %s: got %q, %v, want %q", c.mode, c.side, c.price, price, err, c.want)
+			continue
+		}
+		if o.Price != c.want {
+			t.Errorf("%s %s %s: registered price %q, want %q", c.mode, c.side, c.price, o.Price, c.want)
+		}
+		if metrics.Get("self_trade", ex.Name, m.Symbol, "blocked") != blocked {
+			t.Errorf("%s %s %s: allowed order counted as blocked", c.mode, c.side, c.price)
+		}
+		m.Orders.Abort(o)
+	}
+}
@@ -0,0 +1,120 @@
+package exchange
+
+import (
+	"fcoinExchange/conf"
+	"fcoinExchange/health"
+	"fcoinExchange/model"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// 默认的卡住判断时间
+	defaultStallTimeout int64 = 60000
+	// 连续失败达到此次数时账户未就绪
+	readyAPIErrors int32 = 3
+	// 配置的间隔不大于0时检查配置是否重新开启的时间间隔
+	pausedPoll = time.Minute
+)
+
+// 任务超过三个执行间隔并且超过watchdog.timeout没有心跳时判断为卡住，
+// interval为空时只使用watchdog.timeout
+func (p *Exchange) stallTimeout(interval func(c *model.Configuration) int64) func() time.Duration {
+	return func() time.Duration {
+		return stallTimeout(p.configuration(), interval)
+	}
+}
+
+//
+func stallTimeout(c *model.Configuration, interval func(c *model.Configuration) int64) time.Duration {
+	var timeout = conf.GetConfiguration().Watchdog.Timeout
+	if timeout <= 0 {
+		timeout = defaultStallTimeout
+	}
+	if interval != nil {
+		if i := 3 * checkInterval(interval(c)); i > timeout {
+			timeout = i
+		}
+	}
+	return time.Duration(timeout) * time.Millisecond
+}
+
+// 全局任务的卡住判断时间
+func StallTimeout(interval func(c *model.Configuration) int64) func() time.Duration {
+	return func() time.Duration {
+		return stallTimeout(conf.GetConfiguration(), interval)
+	}
+}
+
+// 注册账户的就绪检查
+func (p *Exchange) watch(wd *health.Watchdog) {
+	wd.AddCheck(p.Name+"/api", p.apiReady)
+	wd.AddCheck(p.Name+"/reconcile", p.reconcileReady)
+}
+
+// 至少有一次请求成功并且最近没有连续失败时交易所接口可以访问
+func (p *Exchange) apiReady() error {
+	if atomic.LoadInt64(&p.apiSucceededAt) == 0 {
+		return fmt.Errorf("no successful api request yet")
+	}
+	if n := atomic.LoadInt32(&p.apiErrors); n >= readyAPIErrors {
+		return fmt.Errorf("%d consecutive api errors", n)
+	}
+	return nil
+}
+
+// 按interval返回的间隔定时执行fn，每次执行前重新读取间隔，单位毫秒。
+// 间隔不大于0时暂停执行，每隔pausedPoll检查一次，配置重新设置间隔后恢复，任务不会结束
+func periodic(r *health.Run, lg *zap.SugaredLogger, interval func() int64, fn func()) {
+	var (
+		current time.Duration
+		paused  bool
+		tk      = time.NewTicker(pausedPoll)
+	)
+	defer tk.Stop()
+
+	for {
+		next := pausedPoll
+		if i := interval(); i > 0 {
+			if paused {
+				lg.Infow("task resumed", "interval", i)
+				paused = false
+			}
+			r.Beat()
+			fn()
+			next = time.Duration(checkInterval(i)) * time.Millisecond
+		} else {
+			if !paused {
+				lg.Infow("interval is not greater than 0, task paused until configuration changes")
+				paused = true
+			}
+			// 暂停期间不判断为卡住
+			r.Idle()
+		}
+
+		if next != current {
+			current = next
+			tk.Reset(next)
+		}
+		select {
+		case <-tk.C:
+		case <-r.Done():
+			return
+		}
+	}
+}
+
+// 等待d或者任务被取消，任务被取消时返回false
+func sleep(r *health.Run, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Done():
+		return false
+	}
+}
@@ -0,0 +1,70 @@
+package exchange
+
+import (
+	"fcoinExchange/health"
+	"fcoinExchange/log"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 等待cond成立，超时返回false
+func waitFor(d time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return cond()
+}
+
+// 间隔不大于0时任务暂停而不是结束，配置重新设置间隔后恢复执行
+func TestPeriodicResumesAfterConfigChange(t *testing.T) {
+	poll := pausedPoll
+	pausedPoll = 10 * time.Millisecond
+	defer func() { pausedPoll = poll }()
+
+	var (
+		interval int64
+		calls    int32
+		done     = make(chan struct{})
+		wd       = health.NewWatchdog(time.Hour, 0)
+	)
+	wd.Go("periodic", func() time.Duration { return time.Minute }, func(r *health.Run) {
+		defer close(done)
+		periodic(r, log.Named("test"), func() int64 { return atomic.LoadInt64(&interval) }, func() {
+			atomic.AddInt32(&calls, 1)
+		})
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatalf("paused task ran %d times", n)
+	}
+	if st := wd.Loops()[0]; !st.Idle {
+		t.Errorf("paused task status = %+v, want idle", st)
+	}
+	select {
+	case <-done:
+		t.Fatal("task ended while interval is 0")
+	default:
+	}
+
+	atomic.StoreInt64(&interval, 1)
+	if !waitFor(time.Second, func() bool { return atomic.LoadInt32(&calls) > 0 }) {
+		t.Fatal("task did not resume after interval set")
+	}
+	if st := wd.Loops()[0]; st.Idle {
+		t.Errorf("running task status = %+v, want not idle", st)
+	}
+
+	// 替换任务后旧的运行结束
+	wd.Go("periodic", func() time.Duration { return time.Minute }, func(r *health.Run) {})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("task did not stop when canceled")
+	}
+}
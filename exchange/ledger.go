@@ -0,0 +1,204 @@
+package exchange
+
+import (
+	"fcoinExchange/model"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 本地资金账本。
+// 提交订单前预留资金，撤单时释放，成交时结算。每次刷新账户余额后与交易所返回的余额对账，
+// 提交时间早于余额请求时间的订单已经被交易所冻结，不再需要本地预留
+type Ledger struct {
+	balances     map[string]*ledgerBalance
+	reservations map[*Reservation]bool
+	orders       map[string]*Reservation
+	updatedAt    time.Time
+	sync.Mutex
+}
+
+// 交易所返回的币种余额
+type ledgerBalance struct {
+	available float64
+	frozen    float64
+	total     float64
+}
+
+// 一笔订单预留的资金
+type Reservation struct {
+	Currency string
+	Side     string
+	Price    float64
+	Amount   float64
+	Filled   float64
+	OrderId  string
+	// 订单提交成功的时间
+	BoundAt time.Time
+}
+
+// 尚未使用的预留资金，买单预留quote currency，卖单预留base currency
+func (p *Reservation) remaining() float64 {
+	left := p.Amount - p.Filled
+	if left < 0 {
+		left = 0
+	}
+	if p.Side == "buy" {
+		return left * p.Price
+	}
+	return left
+}
+
+//
+func NewLedger() *Ledger {
+	return &Ledger{
+		balances:     make(map[string]*ledgerBalance),
+		reservations: make(map[*Reservation]bool),
+		orders:       make(map[string]*Reservation),
+	}
+}
+
+// 与交易所返回的余额对账，requestedAt为发起余额请求的时间
+func (p *Ledger) Reconcile(data []*model.BalanceContext, requestedAt time.Time) error {
+	var balances = make(map[string]*ledgerBalance)
+	for _, v := range data {
+		b, err := parseBalance(v)
+		if err != nil {
+			return fmt.Errorf("parse %s balance failed. %s", v.Currency, err)
+		}
+		balances[v.Currency] = b
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.balances = balances
+	p.updatedAt = requestedAt
+	for r := range p.reservations {
+		if r.OrderId != "" && r.BoundAt.Before(requestedAt) {
+			delete(p.reservations, r)
+			delete(p.orders, r.OrderId)
+		}
+	}
+
+	return nil
+}
+
+//
+func parseBalance(v *model.BalanceContext) (*ledgerBalance, error) {
+	var (
+		b   = new(ledgerBalance)
+		err error
+	)
+	b.available, err = strconv.ParseFloat(v.Available, 64)
+	if err != nil {
+		return nil, err
+	}
+	b.frozen, err = strconv.ParseFloat(v.Frozen, 64)
+	if err != nil {
+		return nil, err
+	}
+	b.total, err = strconv.ParseFloat(v.Balance, 64)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// 可以用于新订单的资金，即可用余额减去本地预留的资金
+func (p *Ledger) Spendable(currency string) float64 {
+	p.Lock()
+	defer p.Unlock()
+	return p.spendable(currency)
+}
+
+//
+func (p *Ledger) spendable(currency string) float64 {
+	var v float64
+	if b, ok := p.balances[currency]; ok {
+		v = b.available
+	}
+	for r := range p.reservations {
+		if r.Currency == currency {
+			v -= r.remaining()
+		}
+	}
+	return v
+}
+
+// 预留订单所需的资金，资金不足时返回错误
+func (p *Ledger) Reserve(currency, side string, price, amount float64) (*Reservation, error) {
+	var r = &Reservation{
+		Currency: currency,
+		Side:     side,
+		Price:    price,
+		Amount:   amount,
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if s := p.spendable(currency); s < r.remaining() {
+		return nil, fmt.Errorf("insufficient %s, spendable %.8f, need %.8f", currency, s, r.remaining())
+	}
+	p.reservations[r] = true
+	return r, nil
+}
+
+// 订单提交成功后关联订单号
+func (p *Ledger) Bind(r *Reservation, orderId string) {
+	p.Lock()
+	defer p.Unlock()
+	r.OrderId = orderId
+	r.BoundAt = time.Now()
+	p.orders[orderId] = r
+}
+
+// 订单提交失败时释放预留的资金
+func (p *Ledger) Release(r *Reservation) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.reservations, r)
+	if r.OrderId != "" {
+		delete(p.orders, r.OrderId)
+	}
+}
+
+// 撤单后释放订单预留的资金
+func (p *Ledger) ReleaseOrder(orderId string) {
+	p.Lock()
+	defer p.Unlock()
+	if r, ok := p.orders[orderId]; ok {
+		delete(p.reservations, r)
+		delete(p.orders, orderId)
+	}
+}
+
+// 按订单的累计成交数量结算，成交的部分不再预留并从本地可用余额中扣除
+func (p *Ledger) Settle(orderId string, filled float64) {
+	p.Lock()
+	defer p.Unlock()
+	r, ok := p.orders[orderId]
+	if !ok || filled <= r.Filled {
+		return
+	}
+
+	before := r.remaining()
+	r.Filled = filled
+	if b, ok := p.balances[r.Currency]; ok {
+		b.available -= before - r.remaining()
+	}
+}
+
+// 订单全部成交或者不再挂单时结束预留
+func (p *Ledger) Finish(orderId string) {
+	p.ReleaseOrder(orderId)
+}
+
+// 账本是否已经与交易所对账过，以及最后一次对账的时间
+func (p *Ledger) UpdatedAt() (time.Time, bool) {
+	p.Lock()
+	defer p.Unlock()
+	return p.updatedAt, !p.updatedAt.IsZero()
+}
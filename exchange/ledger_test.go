@@ -0,0 +1,110 @@
+package exchange
+
+import (
+	"fcoinExchange/model"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 并发预留、提交、成交和撤单，预留的资金不超过可用余额，全部结束后没有残留的预留
+func TestLedgerConcurrentReserve(t *testing.T) {
+	var (
+		l       = NewLedger()
+		workers = 8
+		rounds  = 200
+		wg      sync.WaitGroup
+	)
+	err := l.Reconcile([]*model.BalanceContext{
+		{Currency: "usdt", Available: "1000", Frozen: "0", Balance: "1000"},
+		{Currency: "btc", Available: "10", Frozen: "0", Balance: "10"},
+	}, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan string, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				id := strconv.Itoa(w) + "-" + strconv.Itoa(i)
+
+				buy, err := l.Reserve("usdt", "buy", 10, 1)
+				if err == nil {
+					l.Bind(buy, "b"+id)
+					l.Settle("b"+id, 0.5)
+					l.ReleaseOrder("b" + id)
+				}
+
+				sell, err := l.Reserve("btc", "sell", 10, 0.01)
+				if err == nil {
+					if i%2 == 0 {
+						l.Release(sell)
+					} else {
+						l.Bind(sell, "s"+id)
+						l.Finish("s" + id)
+					}
+				}
+
+				if s := l.Spendable("usdt"); s < -1e-9 {
+					errs <- "usdt spendable negative: " + strconv.FormatFloat(s, 'f', -1, 64)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Error(e)
+	}
+
+	// 每笔买单成交一半，每次扣除5 usdt
+	if n := len(l.reservations) + len(l.orders); n != 0 {
+		t.Errorf("%d reservations left after all orders finished", n)
+	}
+	if s := l.Spendable("usdt"); s < -1e-9 || math.Mod(1000-s, 5) > 1e-6 {
+		t.Errorf("usdt spendable = %v, want 1000 minus multiples of 5", s)
+	}
+	if s := l.Spendable("btc"); math.Abs(s-10) > 1e-9 {
+		t.Errorf("btc spendable = %v, want 10", s)
+	}
+}
+
+// 资金不足时拒绝预留，余额刷新后已提交的订单不再预留
+func TestLedgerReserveAndReconcile(t *testing.T) {
+	l := NewLedger()
+	balances := []*model.BalanceContext{{Currency: "usdt", Available: "100", Frozen: "0", Balance: "100"}}
+	if err := l.Reconcile(balances, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := l.Reserve("usdt", "buy", 10, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Reserve("usdt", "buy", 10, 5); err == nil {
+		t.Fatal("reserve beyond spendable succeeded")
+	}
+
+	l.Bind(r, "1")
+	// 成交的20 usdt从可用余额中扣除，剩余的40 usdt仍然预留
+	l.Settle("1", 2)
+	if s := l.Spendable("usdt"); math.Abs(s-40) > 1e-9 {
+		t.Errorf("spendable after partial fill = %v, want 40", s)
+	}
+
+	// 交易所已经冻结订单的资金
+	time.Sleep(time.Millisecond)
+	balances[0] = &model.BalanceContext{Currency: "usdt", Available: "40", Frozen: "40", Balance: "80"}
+	if err := l.Reconcile(balances, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if s := l.Spendable("usdt"); s != 40 {
+		t.Errorf("spendable after reconcile = %v, want 40", s)
+	}
+}
@@ -0,0 +1,127 @@
+package exchange
+
+import (
+	"fcoinExchange/algo"
+	"fcoinExchange/log"
+	"fcoinExchange/model"
+	"fcoinExchange/schedule"
+	"fcoinExchange/venue"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// 单个交易对的参数和订单，行情保存在账户的State中
+type Market struct {
+	Symbol        string
+	BaseCurrency  string
+	QuoteCurrency string
+	PriceDecimal  int
+	AmountDecimal int
+
+	Orders *OrderManager
+
+	// 带有账户和交易对字段的日志
+	logger *zap.SugaredLogger
+
+	params *model.SymbolConfig
+	// 当前交易时间段，替换params中的部分参数
+	window *model.WindowConfig
+	// 正在执行的再平衡算法订单
+	makeUp *algo.Order
+	sync.RWMutex
+}
+
+//
+func newMarket(client venue.Venue, sc *model.SymbolConfig, logger *zap.SugaredLogger) (*Market, error) {
+	sb, err := resolveSymbol(client, sc.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Market{
+		Symbol:        sc.Symbol,
+		BaseCurrency:  sb.BaseCurrency,
+		QuoteCurrency: sb.QuoteCurrency,
+		PriceDecimal:  sb.PriceDecimal,
+		AmountDecimal: sb.AmountDecimal,
+		params:        sc,
+		Orders:        NewOrderManager(),
+		logger:        logger.With(log.FieldSymbol, sc.Symbol),
+	}, nil
+}
+
+// 从交易对列表中查找交易对的base currency、quote currency和精度
+func resolveSymbol(client venue.Venue, symbol string) (*model.Symbol, error) {
+	list, err := client.Symbols()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range list {
+		if v.Name == symbol {
+			return v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("symbol %s does not support", symbol)
+}
+
+// 最小价格单位
+func (p *Market) Tick() float64 {
+	return math.Pow10(-p.PriceDecimal)
+}
+
+// 按交易对的价格精度格式化价格
+func (p *Market) FormatPrice(v float64) string {
+	return strconv.FormatFloat(v, 'f', p.PriceDecimal, 64)
+}
+
+// 按交易对的数量精度格式化数量
+func (p *Market) FormatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', p.AmountDecimal, 64)
+}
+
+// 按数量精度向下取整，避免格式化后超过可用余额
+func (p *Market) FloorAmount(v float64) float64 {
+	pow := math.Pow10(p.AmountDecimal)
+	return math.Floor(v*pow+1e-9) / pow
+}
+
+//
+func (p *Market) makeUpOrder() *algo.Order {
+	p.RLock()
+	defer p.RUnlock()
+	return p.makeUp
+}
+
+//
+func (p *Market) setMakeUpOrder(o *algo.Order) {
+	p.Lock()
+	defer p.Unlock()
+	p.makeUp = o
+}
+
+// 返回交易对参数的快照，在交易时间段内时已经替换为时间段的参数
+func (p *Market) Params() *model.SymbolConfig {
+	p.RLock()
+	defer p.RUnlock()
+	return schedule.Apply(p.params, p.window)
+}
+
+//
+func (p *Market) setWindow(w *model.WindowConfig) {
+	p.Lock()
+	defer p.Unlock()
+	p.window = w
+}
+
+//
+func (p *Market) setParams(sc *model.SymbolConfig) {
+	p.Lock()
+	defer p.Unlock()
+	p.params = sc
+}
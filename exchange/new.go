@@ -1,120 +1,144 @@
 package exchange
 
 import (
-	"fcoinExchange/conf"
-	"fcoinExchange/log"
+	"fcoinExchange/event"
+	"fcoinExchange/health"
 	"fcoinExchange/model"
-	"fmt"
+	"fcoinExchange/notify"
 	"math"
-	"strconv"
 	"time"
 )
 
-//
-func (p *Exchange) Start() {
-	go p.AutoUpdateTicker()
-	go p.AutoUpdateBalance()
-	go p.AutoExchange()
-	go p.AutoBalance()
-
+// 定时模式，所有任务由watchdog监控
+func (p *Exchange) Start(wd *health.Watchdog) {
+	p.watch(wd)
+	wd.Go(p.Name+"/watch_config", p.stallTimeout(nil), p.WatchConfig)
+	wd.Go(p.Name+"/schedule", p.stallTimeout(nil), p.AutoSchedule)
+	wd.Go(p.Name+"/reconcile", p.stallTimeout(func(c *model.Configuration) int64 {
+		return c.Reconcile.Interval
+	}), p.AutoReconcile)
+	wd.Go(p.Name+"/update_ticker", p.stallTimeout(func(c *model.Configuration) int64 {
+		return c.UpdateTickerInterval
+	}), p.AutoUpdateTicker)
+	wd.Go(p.Name+"/update_balance", p.stallTimeout(func(c *model.Configuration) int64 {
+		return c.UpdateAccountInterval
+	}), p.AutoUpdateBalance)
+	wd.Go(p.Name+"/exchange", p.stallTimeout(nil), p.AutoExchange)
+	wd.Go(p.Name+"/check_balance", p.stallTimeout(nil), p.AutoBalance)
 }
 
-func (p *Exchange) AutoUpdateBalance() {
-	log.Logger.Infof("start auto update balance task")
+func (p *Exchange) AutoUpdateBalance(r *health.Run) {
+	defer notify.Recover(p.Name, "update balance")
+	p.logger.Infof("start auto update balance task")
 	var (
-		interval int64 = conf.GetConfiguration().UpdateAccountInterval
-		balance  *model.AccountBalance
+		interval int64 = p.configuration().UpdateAccountInterval
 		err      error
 	)
 
 	if interval < 500 {
-		log.Logger.Infof("update account interval less than 500, set it to 500")
+		p.logger.Infof("update account interval less than 500, set it to 500")
 		interval = 500
 	}
 
 	tk := time.NewTicker(time.Duration(interval) * time.Millisecond)
+	defer tk.Stop()
 
 	for {
-		balance, err = p.fcclient.GetBalance()
+		r.Beat()
+		err = p.UpdateBalance()
 		if err != nil {
-			log.Logger.Errorf("get balance failed. %s\n", err)
-		} else {
-			if balance.Status != 0 {
-				log.Logger.Errorf("get balance but return status is %d", balance.Status)
-			} else {
-				for _, v := range balance.Data {
-					p.Balance[v.Currency] = v
-				}
-			}
+			p.logger.Errorw("update balance failed", "error", err)
 		}
-		p.accountChan <- 1
-		<-tk.C
+		select {
+		case <-tk.C:
+		case <-r.Done():
+			return
+		}
+		resetTicker(tk, &interval, p.configuration().UpdateAccountInterval)
 	}
 }
 
-func (p *Exchange) AutoExchange() {
-	log.Logger.Infof("start auto exchange")
+// 余额刷新后对余额足够的交易对下单
+func (p *Exchange) AutoExchange(r *health.Run) {
+	defer notify.Recover(p.Name, "exchange")
+	p.logger.Infof("start auto exchange")
 	var (
 		err    error
 		quote  *model.Quote
 		price  string
 		number string
 	)
-	for {
-		<-p.shuadanChan
-		log.Logger.Infof("start exchange")
 
-		quote, err = p.GetCurrentQuote()
-		if err != nil {
-			log.Logger.Errorf("get current quote failed. %s", err)
+	sub := p.bus.Subscribe(p.Name+"/exchange", event.Options{
+		Types:  []event.Type{event.TypeBalanceUpdated},
+		Policy: event.PolicyCoalesce,
+		Filter: event.ForAccount(p.Name),
+	})
+	defer sub.Close()
+
+	for {
+		r.Idle()
+		if _, ok := sub.Next(r.Done()); !ok {
+			return
+		}
+		r.Beat()
+		// 不在交易时间内时不下单
+		if !p.Trading() {
 			continue
 		}
 
-		price = fmt.Sprintf("%.8f", math.Abs(quote.MaxBuyOnePrice+p.config.ExpectValue))
-		number = fmt.Sprintf("%.2f", p.config.SellNumber)
-		p.BuyAndSell(price, number)
+		for _, m := range p.Markets() {
+			params := m.Params()
+
+			quote, err = p.GetCurrentQuote(m.Symbol)
+			if err != nil {
+				m.logger.Errorf("get current quote failed. %s", err)
+				continue
+			}
 
+			// 余额不足的交易对由AutoBalance再平衡
+			amount, ok := p.tradeAmount(m, quote.MinSellOnePrice)
+			if !ok {
+				continue
+			}
+
+			m.logger.Infof("start exchange")
+			price = m.FormatPrice(math.Abs(quote.MaxBuyOnePrice + params.ExpectValue))
+			number = m.FormatAmount(m.FloorAmount(amount))
+			p.BuyAndSell(m, price, number)
+		}
 	}
 }
 
-func (p *Exchange) AutoBalance() {
-	log.Logger.Infof("start auto check balance")
-	var (
-		quote       *model.Quote
-		err         error
-		baseNumber  float64
-		quoteNumber float64
-	)
+// 余额刷新后再平衡库存偏离目标比例的交易对
+func (p *Exchange) AutoBalance(r *health.Run) {
+	defer notify.Recover(p.Name, "check balance")
+	p.logger.Infof("start auto check balance")
 
-	for {
-		<-p.accountChan
+	sub := p.bus.Subscribe(p.Name+"/check_balance", event.Options{
+		Types:  []event.Type{event.TypeBalanceUpdated},
+		Policy: event.PolicyCoalesce,
+		Filter: event.ForAccount(p.Name),
+	})
+	defer sub.Close()
 
-		baseNumber, err = strconv.ParseFloat(p.Balance[p.BaseCurrency].Available, 10)
-		if err != nil {
-			log.Logger.Errorf("%s", err)
-			continue
-		}
-		quoteNumber, err = strconv.ParseFloat(p.Balance[p.QuoteCurrency].Available, 10)
-		if err != nil {
-			log.Logger.Errorf("%s", err)
-			continue
+	for {
+		r.Idle()
+		if _, ok := sub.Next(r.Done()); !ok {
+			return
 		}
-
-		// 获取行情
-		quote, err = p.GetCurrentQuote()
-		if err != nil {
-			log.Logger.Errorf("get current quote failed. %s", err)
+		r.Beat()
+		// 不在交易时间内时不再平衡
+		if !p.Trading() {
 			continue
 		}
 
-		// 判断可用账户余额
-		if quoteNumber < (quote.MinSellOnePrice*p.config.SellNumber) || baseNumber < p.config.SellNumber {
-			log.Logger.Infof("account balance not enough, go to make up balance")
-			p.MakeUpBalance()
-			continue
+		for _, m := range p.Markets() {
+			// 使用AutoUpdateTicker更新的行情，尚未获取到行情时跳过
+			if p.GetQuote(m.Symbol) == nil {
+				continue
+			}
+			p.rebalanceMarket(m)
 		}
-
-		p.shuadanChan <- 1
-
 	}
 }
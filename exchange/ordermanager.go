@@ -0,0 +1,161 @@
+package exchange
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 本地提交过的订单，Id为空表示订单正在提交
+type TrackedOrder struct {
+	Id        string
+	Side      string
+	Price     string
+	Amount    string
+	CreatedAt time.Time
+	// 最后一次查询到的累计成交数量
+	Filled float64
+}
+
+// 交易对的订单管理，记录本账户正在提交和仍在挂单的订单
+type OrderManager struct {
+	orders  map[string]*TrackedOrder
+	pending map[*TrackedOrder]bool
+	sync.RWMutex
+}
+
+//
+func NewOrderManager() *OrderManager {
+	return &OrderManager{
+		orders:  make(map[string]*TrackedOrder),
+		pending: make(map[*TrackedOrder]bool),
+	}
+}
+
+// 检查新订单是否会与本账户的挂单或者正在提交的订单成交，不会成交时登记为正在提交的订单。
+// 会成交时返回这些订单，不登记新订单
+func (p *OrderManager) Begin(side, price, amount string) (*TrackedOrder, []*TrackedOrder) {
+	p.Lock()
+	defer p.Unlock()
+
+	var o = &TrackedOrder{
+		Side:      side,
+		Price:     price,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	}
+
+	var crossing = make([]*TrackedOrder, 0)
+	for _, v := range p.orders {
+		if crosses(o, v) {
+			crossing = append(crossing, v)
+		}
+	}
+	for v := range p.pending {
+		if crosses(o, v) {
+			crossing = append(crossing, v)
+		}
+	}
+
+	if len(crossing) > 0 {
+		return nil, crossing
+	}
+
+	p.pending[o] = true
+	return o, nil
+}
+
+// 订单提交成功后记录订单号
+func (p *OrderManager) Commit(o *TrackedOrder, id string) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.pending, o)
+	o.Id = id
+	p.orders[id] = o
+}
+
+// 订单提交失败时取消登记
+func (p *OrderManager) Abort(o *TrackedOrder) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.pending, o)
+}
+
+// 记录交易所返回的挂单，例如启动前提交的订单
+func (p *OrderManager) Track(o *TrackedOrder) {
+	p.Lock()
+	defer p.Unlock()
+	if _, ok := p.orders[o.Id]; !ok {
+		p.orders[o.Id] = o
+	}
+}
+
+// 更新订单的累计成交数量，返回新成交的数量，订单未被跟踪时返回0
+func (p *OrderManager) Fill(id string, filled float64) float64 {
+	p.Lock()
+	defer p.Unlock()
+	o, ok := p.orders[id]
+	if !ok || filled <= o.Filled {
+		return 0
+	}
+	delta := filled - o.Filled
+	o.Filled = filled
+	return delta
+}
+
+//
+func (p *OrderManager) Untrack(id string) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.orders, id)
+}
+
+// 停止跟踪在before之前创建并且不在open中的订单，返回这些订单的id
+func (p *OrderManager) Prune(open map[string]bool, before time.Time) []string {
+	p.Lock()
+	defer p.Unlock()
+	var ids = make([]string, 0)
+	for id, o := range p.orders {
+		if !open[id] && o.CreatedAt.Before(before) {
+			delete(p.orders, id)
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// 返回所有挂单的副本
+func (p *OrderManager) List() []*TrackedOrder {
+	p.RLock()
+	defer p.RUnlock()
+	var list = make([]*TrackedOrder, 0, len(p.orders))
+	for _, v := range p.orders {
+		o := *v
+		list = append(list, &o)
+	}
+	return list
+}
+
+// 买单价格不低于卖单价格时两个订单会成交，市价单没有价格，与所有反方向的订单都会成交
+func crosses(o, resting *TrackedOrder) bool {
+	if o.Side == resting.Side {
+		return false
+	}
+	if o.Price == "" || resting.Price == "" {
+		return true
+	}
+
+	price, err := strconv.ParseFloat(o.Price, 64)
+	if err != nil {
+		return false
+	}
+	rp, err := strconv.ParseFloat(resting.Price, 64)
+	if err != nil {
+		return false
+	}
+
+	if o.Side == "buy" {
+		return price >= rp
+	}
+	return price <= rp
+}
@@ -0,0 +1,215 @@
+package exchange
+
+import (
+	"fcoinExchange/event"
+	"fcoinExchange/health"
+	"fcoinExchange/log"
+	"fcoinExchange/model"
+	"strconv"
+	"time"
+)
+
+var (
+	// 两次撤单或者重新下单之间的间隔，避免请求过于频繁
+	cancelPause = time.Second
+	// revoke_order_time未设置或者太小时，不在挂单列表中的订单至少经过此时间才停止跟踪
+	minPruneWindow = time.Minute
+)
+
+// 查询交易对所有未完成的订单
+func (p *Exchange) ListOpenOrders(m *Market) ([]*model.OrderInfo, error) {
+	return p.client().OpenOrders(m.Symbol)
+}
+
+// 按交易对配置的订单处理策略处理所有未完成的订单
+func (p *Exchange) ManageOrders(r *health.Run, m *Market) {
+	// 启动对账之前不处理挂单，未知挂单由对账按配置处理
+	if !p.Reconciled() {
+		m.logger.Debugf("%s, skip managing orders", ErrNotReconciled)
+		return
+	}
+
+	orders, err := p.ListOpenOrders(m)
+	p.apiResult("list orders", err)
+	if err != nil {
+		m.logger.Errorf("get open orders failed. %s", err)
+		return
+	}
+
+	var policies = make([]OrderPolicy, 0)
+	for _, v := range m.Params().OrderPolicies {
+		op, err := NewOrderPolicy(v)
+		if err != nil {
+			m.logger.Errorf("%s", err)
+			continue
+		}
+		policies = append(policies, op)
+	}
+
+	quote, err := p.GetCurrentQuote(m.Symbol)
+	if err != nil {
+		m.logger.Errorf("get quote failed, price based policies skipped. %s", err)
+	}
+
+	// 使用与服务器同步后的时间计算订单的存在时间
+	clock := p.client().Clock()
+	if _, ok := clock.SyncedAt(); !ok {
+		m.logger.Warnf("clock not synced with server yet, use local time")
+	}
+	now := clock.Millis()
+
+	var open = make(map[string]bool)
+	for _, order := range orders {
+		open[order.Id] = true
+		filled, _ := strconv.ParseFloat(order.FilledAmount, 64)
+		// 启动前提交的订单也需要纳入自成交检查，之前的成交不再通知
+		m.Orders.Track(&TrackedOrder{
+			Id:        order.Id,
+			Side:      order.Side,
+			Price:     order.Price,
+			Amount:    order.Amount,
+			CreatedAt: time.Unix(0, order.CreatedAt*1000000),
+			Filled:    filled,
+		})
+		p.ledger.Settle(order.Id, filled)
+		p.publishOrder(m, order, filled)
+
+		c := &PolicyContext{
+			Order: order,
+			Quote: quote,
+			Age:   now - order.CreatedAt,
+			Tick:  m.Tick(),
+		}
+		p.checkStuckOrder(m, order.Id, order.Side, order.Price, c.Age)
+
+		action, name := Decide(policies, c)
+		lg := m.logger.With(
+			log.FieldOrderId, order.Id,
+			log.FieldSide, order.Side,
+			log.FieldPrice, order.Price,
+			log.FieldAmount, order.Amount,
+			log.FieldStrategy, name,
+		)
+		lg.Debugw("order policy decided", "age_ms", c.Age, "action", action.String())
+
+		switch action {
+		case ActionCancel:
+			lg.Infow("cancel order by policy")
+			p.CancelOrder(m, order.Id)
+		case ActionReprice:
+			lg.Infow("reprice order by policy")
+			p.RepriceOrder(m, order, quote)
+		default:
+			continue
+		}
+		// 避免撤单请求过于频繁，等待期间继续发送心跳
+		r.Beat()
+		if !sleep(r, cancelPause) {
+			return
+		}
+		r.Beat()
+	}
+
+	// 超过撤单时间仍不在挂单列表中的订单已经成交或者撤销
+	for _, id := range m.Orders.Prune(open, time.Now().Add(-pruneWindow(p.configuration().RevokeOrderTime))) {
+		p.ledger.Finish(id)
+		p.journalClose(id)
+		p.bus.Publish(&event.OrderUpdated{
+			Account: p.Name,
+			Symbol:  m.Symbol,
+			OrderId: id,
+			State:   "closed",
+			Time:    time.Now(),
+		})
+	}
+}
+
+// 不在挂单列表中的订单超过此时间后停止跟踪，为撤单时间的两倍并且不小于minPruneWindow
+func pruneWindow(revoke int64) time.Duration {
+	d := 2 * time.Duration(revoke) * time.Millisecond
+	if d < minPruneWindow {
+		return minPruneWindow
+	}
+	return d
+}
+
+// 通知订单的最新状态，累计成交数量增加时通知新成交的部分
+func (p *Exchange) publishOrder(m *Market, order *model.OrderInfo, filled float64) {
+	now := time.Now()
+	if delta := m.Orders.Fill(order.Id, filled); delta > 0 {
+		price, _ := strconv.ParseFloat(order.Price, 64)
+		p.bus.Publish(&event.FillReceived{
+			Account: p.Name,
+			Symbol:  m.Symbol,
+			OrderId: order.Id,
+			Side:    order.Side,
+			Price:   price,
+			Amount:  delta,
+			Time:    now,
+		})
+	}
+
+	p.bus.Publish(&event.OrderUpdated{
+		Account: p.Name,
+		Symbol:  m.Symbol,
+		OrderId: order.Id,
+		Side:    order.Side,
+		Price:   order.Price,
+		Amount:  order.Amount,
+		State:   order.State,
+		Filled:  filled,
+		Time:    now,
+	})
+}
+
+// 撤销订单并释放账本中预留的资金
+func (p *Exchange) CancelOrder(m *Market, id string) error {
+	lg := m.logger.With(log.FieldOrderId, id, log.FieldRequestId, log.NewRequestId())
+	err := p.client().CancelOrder(m.Symbol, id)
+	p.apiResult("cancel order", err)
+	if err != nil {
+		lg.Infow("cancel order failed", "error", err)
+		return err
+	}
+
+	m.Orders.Untrack(id)
+	p.ledger.ReleaseOrder(id)
+	p.journalClose(id)
+	lg.Infow("order canceled")
+	p.bus.Publish(&event.OrderUpdated{
+		Account: p.Name,
+		Symbol:  m.Symbol,
+		OrderId: id,
+		State:   "canceled",
+		Time:    time.Now(),
+	})
+	return nil
+}
+
+// 撤单后按最优价对未成交的部分重新下单
+func (p *Exchange) RepriceOrder(m *Market, order *model.OrderInfo, quote *model.Quote) {
+	amount, err := strconv.ParseFloat(order.Amount, 64)
+	if err != nil {
+		m.logger.Errorw("parse order amount failed", log.FieldOrderId, order.Id, "error", err)
+		return
+	}
+	filled, _ := strconv.ParseFloat(order.FilledAmount, 64)
+
+	err = p.CancelOrder(m, order.Id)
+	if err != nil {
+		return
+	}
+
+	left := amount - filled
+	if left <= 0 {
+		return
+	}
+
+	price := m.FormatPrice(bestPrice(order.Side, quote))
+	id, err := p.createOrder(m, "reprice", order.Side, price, m.FormatAmount(left))
+	if err != nil {
+		m.logger.Errorw("reprice order failed", log.FieldOrderId, order.Id, "error", err)
+		return
+	}
+	m.logger.Infow("order repriced", log.FieldOrderId, order.Id, log.FieldPrice, price, "new_order_id", id)
+}
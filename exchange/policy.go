@@ -0,0 +1,170 @@
+package exchange
+
+import (
+	"fcoinExchange/model"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// 订单处理动作
+type Action int
+
+const (
+	// 没有处理结果，交给下一个策略
+	ActionNone Action = iota
+	ActionKeep
+	ActionCancel
+	ActionReprice
+)
+
+//
+func (p Action) String() string {
+	switch p {
+	case ActionKeep:
+		return "keep"
+	case ActionCancel:
+		return "cancel"
+	case ActionReprice:
+		return "reprice"
+	default:
+		return "none"
+	}
+}
+
+// 策略判断时使用的订单信息
+type PolicyContext struct {
+	Order *model.OrderInfo
+	Quote *model.Quote
+	// 订单存在时间，单位毫秒
+	Age  int64
+	Tick float64
+}
+
+// 订单处理策略
+type OrderPolicy interface {
+	Name() string
+	Decide(c *PolicyContext) Action
+}
+
+// 根据配置创建订单处理策略
+func NewOrderPolicy(cfg *model.OrderPolicyConfig) (OrderPolicy, error) {
+	switch cfg.Type {
+	case "timeout":
+		return &TimeoutPolicy{After: cfg.After}, nil
+	case "reprice":
+		return &RepricePolicy{After: cfg.After}, nil
+	case "keep_partial":
+		return new(KeepPartialPolicy), nil
+	case "price_move":
+		return &PriceMovePolicy{Ticks: cfg.Ticks}, nil
+	default:
+		return nil, fmt.Errorf("unknown order policy %s", cfg.Type)
+	}
+}
+
+// 按顺序执行策略，返回第一个给出处理结果的策略，都没有结果时保留订单
+func Decide(policies []OrderPolicy, c *PolicyContext) (Action, string) {
+	for _, v := range policies {
+		if a := v.Decide(c); a != ActionNone {
+			return a, v.Name()
+		}
+	}
+	return ActionKeep, ""
+}
+
+// 订单存在时间超过After毫秒后撤单
+type TimeoutPolicy struct {
+	After int64
+}
+
+//
+func (p *TimeoutPolicy) Name() string {
+	return "timeout"
+}
+
+//
+func (p *TimeoutPolicy) Decide(c *PolicyContext) Action {
+	if c.Age > p.After {
+		return ActionCancel
+	}
+	return ActionNone
+}
+
+// 订单存在时间超过After毫秒并且价格不是最优价时，撤单后按最优价重新下单
+type RepricePolicy struct {
+	After int64
+}
+
+//
+func (p *RepricePolicy) Name() string {
+	return "reprice"
+}
+
+//
+func (p *RepricePolicy) Decide(c *PolicyContext) Action {
+	if c.Age <= p.After || c.Quote == nil {
+		return ActionNone
+	}
+
+	price, err := strconv.ParseFloat(c.Order.Price, 64)
+	if err != nil {
+		return ActionNone
+	}
+
+	if math.Abs(price-bestPrice(c.Order.Side, c.Quote)) < c.Tick/2 {
+		return ActionNone
+	}
+	return ActionReprice
+}
+
+// 保留部分成交的订单
+type KeepPartialPolicy struct{}
+
+//
+func (p *KeepPartialPolicy) Name() string {
+	return "keep_partial"
+}
+
+//
+func (p *KeepPartialPolicy) Decide(c *PolicyContext) Action {
+	if c.Order.State == "partial_filled" {
+		return ActionKeep
+	}
+	return ActionNone
+}
+
+// 最优价偏离订单价格超过Ticks个最小价格单位时撤单
+type PriceMovePolicy struct {
+	Ticks int
+}
+
+//
+func (p *PriceMovePolicy) Name() string {
+	return "price_move"
+}
+
+//
+func (p *PriceMovePolicy) Decide(c *PolicyContext) Action {
+	if c.Quote == nil || c.Tick <= 0 {
+		return ActionNone
+	}
+
+	price, err := strconv.ParseFloat(c.Order.Price, 64)
+	if err != nil {
+		return ActionNone
+	}
+
+	if math.Abs(price-bestPrice(c.Order.Side, c.Quote))/c.Tick > float64(p.Ticks) {
+		return ActionCancel
+	}
+	return ActionNone
+}
+
+// 买单的最优价为买一价，卖单的最优价为卖一价
+func bestPrice(side string, q *model.Quote) float64 {
+	if side == "buy" {
+		return q.MaxBuyOnePrice
+	}
+	return q.MinSellOnePrice
+}
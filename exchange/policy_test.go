@@ -0,0 +1,181 @@
+package exchange
+
+import (
+	"fcoinExchange/health"
+	"fcoinExchange/model"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//
+func TestNewOrderPolicy(t *testing.T) {
+	for _, c := range []struct {
+		cfg  model.OrderPolicyConfig
+		name string
+	}{
+		{cfg: model.OrderPolicyConfig{Type: "timeout", After: 1000}, name: "timeout"},
+		{cfg: model.OrderPolicyConfig{Type: "reprice", After: 1000}, name: "reprice"},
+		{cfg: model.OrderPolicyConfig{Type: "keep_partial"}, name: "keep_partial"},
+		{cfg: model.OrderPolicyConfig{Type: "price_move", Ticks: 3}, name: "price_move"},
+		{cfg: model.OrderPolicyConfig{Type: "cancel_all"}},
+	} {
+		op, err := NewOrderPolicy(&c.cfg)
+		if c.name == "" {
+			if err == nil {
+				t.Errorf("%s: policy created", c.cfg.Type)
+			}
+			continue
+		}
+		if err != nil || op.Name() != c.name {
+			t.Errorf("%s: policy %v, %v", c.cfg.Type, op, err)
+		}
+	}
+}
+
+//
+func TestOrderPolicyDecide(t *testing.T) {
+	var (
+		quote   = &model.Quote{MaxBuyOnePrice: 100, MinSellOnePrice: 101}
+		atBest  = &model.OrderInfo{Side: "buy", Price: "100.00", State: "submitted"}
+		behind  = &model.OrderInfo{Side: "buy", Price: "99.98", State: "submitted"}
+		askOff  = &model.OrderInfo{Side: "sell", Price: "101.05", State: "submitted"}
+		partial = &model.OrderInfo{Side: "buy", Price: "99.00", State: "partial_filled"}
+	)
+
+	for _, c := range []struct {
+		name   string
+		policy OrderPolicy
+		order  *model.OrderInfo
+		quote  *model.Quote
+		age    int64
+		want   Action
+	}{
+		{name: "timeout not reached", policy: &TimeoutPolicy{After: 1000}, order: atBest, age: 1000, want: ActionNone},
+		{name: "timeout", policy: &TimeoutPolicy{After: 1000}, order: atBest, age: 1001, want: ActionCancel},
+		{name: "reprice not reached", policy: &RepricePolicy{After: 1000}, order: behind, quote: quote, age: 500, want: ActionNone},
+		{name: "reprice at best price", policy: &RepricePolicy{After: 1000}, order: atBest, quote: quote, age: 2000, want: ActionNone},
+		{name: "reprice behind best price", policy: &RepricePolicy{After: 1000}, order: behind, quote: quote, age: 2000, want: ActionReprice},
+		{name: "reprice sell", policy: &RepricePolicy{After: 1000}, order: askOff, quote: quote, age: 2000, want: ActionReprice},
+		{name: "reprice without quote", policy: &RepricePolicy{After: 1000}, order: behind, age: 2000, want: ActionNone},
+		{name: "keep partial", policy: new(KeepPartialPolicy), order: partial, want: ActionKeep},
+		{name: "keep partial not filled", policy: new(KeepPartialPolicy), order: atBest, want: ActionNone},
+		{name: "price move within ticks", policy: &PriceMovePolicy{Ticks: 3}, order: behind, quote: quote, want: ActionNone},
+		{name: "price move", policy: &PriceMovePolicy{Ticks: 3}, order: askOff, quote: quote, want: ActionCancel},
+		{name: "price move without quote", policy: &PriceMovePolicy{Ticks: 3}, order: askOff, want: ActionNone},
+	} {
+		got := c.policy.Decide(&PolicyContext{Order: c.order, Quote: c.quote, Age: c.age, Tick: 0.01})
+		if got != c.want {
+			t.Errorf("%s: %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+// 第一个给出结果的策略生效，都没有结果时保留
+func TestDecideOrder(t *testing.T) {
+	var (
+		policies = []OrderPolicy{new(KeepPartialPolicy), &TimeoutPolicy{After: 1000}}
+		partial  = &PolicyContext{Order: &model.OrderInfo{State: "partial_filled"}, Age: 2000}
+		expired  = &PolicyContext{Order: &model.OrderInfo{State: "submitted"}, Age: 2000}
+		fresh    = &PolicyContext{Order: &model.OrderInfo{State: "submitted"}, Age: 10}
+	)
+	for _, c := range []struct {
+		name   string
+		c      *PolicyContext
+		action Action
+		policy string
+	}{
+		{name: "partial kept", c: partial, action: ActionKeep, policy: "keep_partial"},
+		{name: "expired canceled", c: expired, action: ActionCancel, policy: "timeout"},
+		{name: "no decision", c: fresh, action: ActionKeep},
+	} {
+		action, name := Decide(policies, c.c)
+		if action != c.action || name != c.policy {
+			t.Errorf("%s: %s by %q, want %s by %q", c.name, action, name, c.action, c.policy)
+		}
+	}
+}
+
+//
+func TestPruneWindow(t *testing.T) {
+	for _, c := range []struct {
+		revoke int64
+		want   time.Duration
+	}{
+		{revoke: 0, want: minPruneWindow},
+		{revoke: 1000, want: minPruneWindow},
+		{revoke: 60000, want: 2 * time.Minute},
+	} {
+		if got := pruneWindow(c.revoke); got != c.want {
+			t.Errorf("revoke %d: %s, want %s", c.revoke, got, c.want)
+		}
+	}
+}
+
+// 在watchdog中执行一次ManageOrders
+func manageOrders(t *testing.T, ex *Exchange, m *Market) {
+	var (
+		done = make(chan struct{})
+		wd   = health.NewWatchdog(time.Hour, 0)
+	)
+	wd.Go("check_orders", func() time.Duration { return time.Minute }, func(r *health.Run) {
+		defer close(done)
+		ex.ManageOrders(r, m)
+	})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("manage orders did not finish")
+	}
+}
+
+//
+func TestManageOrders(t *testing.T) {
+	pause := cancelPause
+	cancelPause = 0
+	t.Cleanup(func() { cancelPause = pause })
+
+	var (
+		now = time.Now()
+		v   = newFakeVenue()
+	)
+	v.setQuote("btcusdt", 100, 101)
+	v.open = []*model.OrderInfo{
+		{Id: "1", Symbol: "btcusdt", Side: "buy", Type: "limit", Price: "100.00", Amount: "0.5", State: "submitted", CreatedAt: millis(now.Add(-time.Minute))},
+		{Id: "2", Symbol: "btcusdt", Side: "sell", Type: "limit", Price: "101.00", Amount: "0.5", State: "submitted", CreatedAt: millis(now)},
+	}
+	ex := newTestExchange(t, v, &model.Configuration{RevokeOrderTime: 1000})
+	m, err := ex.Market("btcusdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 对账之前不处理挂单
+	manageOrders(t, ex, m)
+	if len(v.canceled) != 0 || len(m.Orders.List()) != 0 {
+		t.Fatalf("orders managed before reconciliation")
+	}
+
+	atomic.StoreInt32(&ex.reconciled, 1)
+	// 刚从挂单列表消失的订单可能还没有返回，撤单时间很短时也至少保留minPruneWindow
+	m.Orders.Track(&TrackedOrder{Id: "3", Side: "buy", Price: "99.00", Amount: "0.5", CreatedAt: now.Add(-10 * time.Second)})
+	m.Orders.Track(&TrackedOrder{Id: "4", Side: "buy", Price: "98.00", Amount: "0.5", CreatedAt: now.Add(-2 * minPruneWindow)})
+	manageOrders(t, ex, m)
+
+	if len(v.canceled) != 1 || v.canceled[0] != "1" {
+		t.Errorf("canceled %v, want the expired order 1", v.canceled)
+	}
+	for _, c := range []struct {
+		id      string
+		tracked bool
+	}{
+		{id: "1"},
+		{id: "2", tracked: true},
+		{id: "3", tracked: true},
+		{id: "4"},
+	} {
+		if got := tracked(m, c.id); got != c.tracked {
+			t.Errorf("order %s tracked %v, want %v", c.id, got, c.tracked)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package exchange
+
+import (
+	"fcoinExchange/log"
+	"fcoinExchange/metrics"
+	"fcoinExchange/model"
+	"fcoinExchange/rebalance"
+	"fcoinExchange/venue"
+	"fmt"
+	"time"
+)
+
+// 交易对的再平衡配置，未配置max_clip时使用sell_number * makeup_percent / 100
+func (p *Exchange) rebalanceConfig(m *Market) *model.RebalanceConfig {
+	params := m.Params()
+	cfg := rebalance.Normalize(params.Rebalance, params.SellNumber*float64(params.MakeUpPercent)/100)
+	if cfg.OrderType == "" {
+		c := p.configuration()
+		switch {
+		case c.MakeUpAlgo != nil:
+			cfg.OrderType = rebalance.OrderAlgo
+		case c.MakeUpOrderType != "":
+			cfg.OrderType = c.MakeUpOrderType
+		default:
+			cfg.OrderType = rebalance.OrderLimit
+		}
+	}
+	return cfg
+}
+
+// 库存偏离目标比例时下单再平衡，返回是否提交了订单。
+// 不需要再平衡或者受冷却时间、每日限制约束时返回false和原因
+func (p *Exchange) Rebalance(m *Market) (bool, error) {
+	if m.makeUpRunning() {
+		m.logger.Debugf("rebalance algo order is running")
+		return false, nil
+	}
+
+	quote := p.GetQuote(m.Symbol)
+	if quote == nil {
+		return false, fmt.Errorf("no quote for %s yet", m.Symbol)
+	}
+
+	snapshot := p.state.Load()
+	base, err := snapshot.Balance(m.BaseCurrency)
+	if err != nil {
+		return false, err
+	}
+	quoteBalance, err := snapshot.Balance(m.QuoteCurrency)
+	if err != nil {
+		return false, err
+	}
+	inv := &rebalance.Inventory{
+		Base:      base.Total,
+		Quote:     quoteBalance.Total,
+		BaseFree:  p.ledger.Spendable(m.BaseCurrency),
+		QuoteFree: p.ledger.Spendable(m.QuoteCurrency),
+	}
+
+	cfg := p.rebalanceConfig(m)
+	now := time.Now()
+	d, err := p.rebalancer.Decide(m.Symbol, cfg, inv, quote, now)
+	if err != nil {
+		if d != nil {
+			m.logger.Debugw("no rebalance", "reason", err, "ratio", d.Ratio, "deviation", d.Deviation)
+		}
+		return false, err
+	}
+
+	d.Amount = m.FloorAmount(d.Amount)
+	if d.Amount <= 0 || d.Amount < cfg.MinClip {
+		return false, rebalance.ErrBelowMinClip
+	}
+
+	lg := m.logger.With(log.FieldSide, d.Side, log.FieldAmount, d.Amount, log.FieldPrice, d.Price, "order_type", cfg.OrderType)
+	lg.Infow("rebalance inventory", "ratio", d.Ratio, "target_ratio", *cfg.TargetRatio, "deviation", d.Deviation, "mid", d.Mid)
+
+	err = p.placeRebalance(m, cfg.OrderType, d)
+	if err != nil {
+		lg.Errorw("rebalance failed", "error", err)
+		return false, err
+	}
+	p.rebalancer.Record(m.Symbol, d.Amount, now)
+	metrics.Inc("rebalance", p.Name, m.Symbol, d.Side)
+	return true, nil
+}
+
+// 执行再平衡，不需要再平衡以外的错误输出日志，返回是否提交了订单
+func (p *Exchange) rebalanceMarket(m *Market) bool {
+	placed, err := p.Rebalance(m)
+	if err != nil && !rebalance.Skipped(err) {
+		m.logger.Errorf("rebalance failed. %s", err)
+	}
+	return placed
+}
+
+// 按订单类型提交再平衡订单，limit和ioc在对手价下单，market买单按数量乘以卖一价折算成quote currency数量
+func (p *Exchange) placeRebalance(m *Market, orderType string, d *rebalance.Decision) error {
+	if orderType == rebalance.OrderAlgo {
+		return p.makeUpWithAlgo(m, d.Side, d.Amount, d.Price)
+	}
+
+	var req = &venue.OrderRequest{
+		Symbol: m.Symbol,
+		Side:   d.Side,
+		Type:   venue.TypeLimit,
+		Price:  m.FormatPrice(d.Price),
+		Amount: m.FormatAmount(d.Amount),
+	}
+	switch orderType {
+	case rebalance.OrderMarket:
+		req.Type = venue.TypeMarket
+		req.Price = ""
+		if d.Side == "buy" {
+			req.Amount = m.FormatPrice(d.Amount * d.Price)
+		}
+	case rebalance.OrderIOC:
+		req.TimeInForce = venue.IOC
+	}
+
+	_, err := p.PlaceOrder(m, "rebalance", req)
+	return err
+}
+
+// 余额不足以按sell_number买卖时，按sell_number * balance_percent / 100减小本次的数量，
+// 减小后仍不足时返回false
+func (p *Exchange) tradeAmount(m *Market, price float64) (float64, bool) {
+	params := m.Params()
+	if p.canTrade(m, price, params.SellNumber) {
+		return params.SellNumber, true
+	}
+
+	reduced := params.SellNumber * float64(params.BalancePercent) / 100
+	if reduced < params.SellNumber && p.canTrade(m, price, reduced) {
+		m.logger.Infof("balance not enough for sell_number %v, trade %v this time", params.SellNumber, reduced)
+		return reduced, true
+	}
+	return 0, false
+}
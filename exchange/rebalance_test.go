@@ -0,0 +1,43 @@
+package exchange
+
+import (
+	"fcoinExchange/model"
+	"testing"
+)
+
+// 余额不足以按sell_number交易时按balance_percent减小数量
+func TestTradeAmount(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		percent int
+		btc     string
+		usdt    string
+		amount  float64
+		ok      bool
+	}{
+		{name: "enough balance", percent: 50, btc: "2", usdt: "1000", amount: 1, ok: true},
+		{name: "base reduced", percent: 50, btc: "0.6", usdt: "1000", amount: 0.5, ok: true},
+		{name: "quote reduced", percent: 50, btc: "2", usdt: "60", amount: 0.5, ok: true},
+		{name: "reduced still not enough", percent: 50, btc: "0.4", usdt: "1000", ok: false},
+		{name: "no reduction", percent: 100, btc: "0.6", usdt: "1000", ok: false},
+	} {
+		v := newFakeVenue()
+		v.setBalances(
+			&model.BalanceContext{Currency: "btc", Available: c.btc, Frozen: "0", Balance: c.btc},
+			&model.BalanceContext{Currency: "usdt", Available: c.usdt, Frozen: "0", Balance: c.usdt},
+		)
+		ex := newTestExchange(t, v, &model.Configuration{SellNumber: 1, BalancePercent: c.percent})
+		m, err := ex.Market("btcusdt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ex.UpdateBalance(); err != nil {
+			t.Fatal(err)
+		}
+
+		amount, ok := ex.tradeAmount(m, 100)
+		if ok != c.ok || amount != c.amount {
+			t.Errorf("%s: tradeAmount = %v, %v, want %v, %v", c.name, amount, ok, c.amount, c.ok)
+		}
+	}
+}
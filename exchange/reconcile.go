@@ -0,0 +1,449 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fcoinExchange/event"
+	"fcoinExchange/health"
+	"fcoinExchange/journal"
+	"fcoinExchange/log"
+	"fcoinExchange/metrics"
+	"fcoinExchange/model"
+	"fcoinExchange/notify"
+	"fcoinExchange/venue"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 未知挂单的处理方式
+const (
+	UnknownAdopt  string = "adopt"
+	UnknownCancel string = "cancel"
+)
+
+var (
+	ErrNotReconciled = errors.New("account not reconciled with exchange yet")
+
+	// 启动对账失败时的重试间隔
+	reconcileRetry = 10 * time.Second
+	// 定时对账时交易仍在进行，此时间内提交的订单可能还没有记录结果，本次对账不处理
+	reconcileSettle = time.Minute
+
+	// 多个账户共用报告文件
+	reportMu sync.Mutex
+)
+
+// 对账发现的差异
+type Discrepancy struct {
+	Symbol        string `json:"symbol"`
+	Kind          string `json:"kind"`
+	OrderId       string `json:"order_id,omitempty"`
+	ClientOrderId string `json:"client_order_id,omitempty"`
+	// 对账的处理结果
+	Action string `json:"action"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// 差异类型
+const (
+	// 交易所有挂单，订单日志和本地都没有记录
+	DiscrepancyUnknownOrder string = "unknown_order"
+	// 订单日志或者本地记录为挂单，交易所已经没有挂单
+	DiscrepancyClosedOrder string = "closed_order"
+	// 提交结果不确定的订单在交易所找到
+	DiscrepancyUnresolvedFound string = "unresolved_found"
+	// 提交结果不确定的订单在交易所不存在
+	DiscrepancyUnresolvedMissing string = "unresolved_missing"
+)
+
+// 一次对账的报告
+type ReconcileReport struct {
+	Account       string         `json:"account"`
+	Startup       bool           `json:"startup"`
+	StartedAt     time.Time      `json:"started_at"`
+	Duration      string         `json:"duration"`
+	OpenOrders    int            `json:"open_orders"`
+	Discrepancies []*Discrepancy `json:"discrepancies"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// 启动对账成功后才允许下单，之后按reconcile.interval定时对账，定时对账失败不停止交易
+func (p *Exchange) AutoReconcile(r *health.Run) {
+	defer notify.Recover(p.Name, "reconcile")
+
+	for !p.Reconciled() {
+		r.Beat()
+		_, err := p.Reconcile(true)
+		if err == nil {
+			p.logger.Infof("startup reconciliation succeeded, trading enabled")
+			break
+		}
+		p.logger.Errorf("startup reconciliation failed, retry in %s. %s", reconcileRetry, err)
+		if !sleep(r, reconcileRetry) {
+			return
+		}
+	}
+
+	for {
+		interval := p.configuration().Reconcile.Interval
+		if interval <= 0 {
+			// 没有定时对账，配置变化后重新检查
+			r.Idle()
+			if !sleep(r, time.Minute) {
+				return
+			}
+			continue
+		}
+
+		r.Beat()
+		if !sleep(r, time.Duration(checkInterval(interval))*time.Millisecond) {
+			return
+		}
+		r.Beat()
+		_, err := p.Reconcile(false)
+		if err != nil {
+			p.logger.Errorf("scheduled reconciliation failed. %s", err)
+		}
+	}
+}
+
+// 是否已经完成启动对账
+func (p *Exchange) Reconciled() bool {
+	return atomic.LoadInt32(&p.reconciled) == 1
+}
+
+// 就绪检查
+func (p *Exchange) reconcileReady() error {
+	if !p.Reconciled() {
+		return ErrNotReconciled
+	}
+	return nil
+}
+
+// 对所有交易对执行一次对账并写入报告，startup为true时成功后允许交易
+func (p *Exchange) Reconcile(startup bool) (*ReconcileReport, error) {
+	report := &ReconcileReport{
+		Account:       p.Name,
+		Startup:       startup,
+		StartedAt:     time.Now(),
+		Discrepancies: make([]*Discrepancy, 0),
+	}
+
+	err := p.reconcile(report)
+	report.Duration = time.Since(report.StartedAt).String()
+	if err != nil {
+		report.Error = err.Error()
+		metrics.Inc("reconcile", p.Name, "failed")
+	} else {
+		metrics.Inc("reconcile", p.Name, "succeeded")
+		if startup {
+			atomic.StoreInt32(&p.reconciled, 1)
+		}
+	}
+	p.writeReport(report)
+	return report, err
+}
+
+//
+func (p *Exchange) reconcile(report *ReconcileReport) error {
+	for _, m := range p.Markets() {
+		n, err := p.reconcileMarket(m, report)
+		if err != nil {
+			return fmt.Errorf("%s: %s", m.Symbol, err)
+		}
+		report.OpenOrders += n
+	}
+
+	// 撤销未知挂单后再刷新余额
+	return p.UpdateBalance()
+}
+
+// 对账一个交易对，返回交易所的挂单数量
+func (p *Exchange) reconcileMarket(m *Market, report *ReconcileReport) (int, error) {
+	listedAt := time.Now()
+	settled := listedAt
+	if !report.Startup {
+		settled = listedAt.Add(-reconcileSettle)
+	}
+	orders, err := p.ListOpenOrders(m)
+	p.apiResult("list orders", err)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		jn   = p.orderJournal()
+		open = make(map[string]*model.OrderInfo)
+		add  = func(d *Discrepancy) {
+			d.Symbol = m.Symbol
+			report.Discrepancies = append(report.Discrepancies, d)
+			m.logger.Warnw("reconciliation discrepancy", "kind", d.Kind, log.FieldOrderId, d.OrderId,
+				"client_order_id", d.ClientOrderId, "action", d.Action, "detail", d.Detail)
+		}
+	)
+	for _, o := range orders {
+		open[o.Id] = o
+	}
+
+	// 结果不确定的订单先确认，确认存在的订单不再视为未知挂单
+	err = p.resolveUnresolved(m, open, settled, add)
+	if err != nil {
+		return 0, err
+	}
+
+	tracked := make(map[string]bool)
+	for _, v := range m.Orders.List() {
+		tracked[v.Id] = true
+	}
+
+	for _, o := range orders {
+		if _, ok := jn.Order(o.Id); ok || tracked[o.Id] {
+			p.adoptOrder(m, o)
+			continue
+		}
+		// 通过客户端订单号找到的订单补记订单号
+		if e, ok := jn.Get(o.ClientOrderId); ok && o.ClientOrderId != "" && e.Account == p.Name {
+			p.journalUpdate(m.logger, e.ClientOrderId, o.Id, journal.StateSubmitted, nil)
+			p.adoptOrder(m, o)
+			continue
+		}
+
+		if time.Unix(0, o.CreatedAt*int64(time.Millisecond)).After(settled) {
+			continue
+		}
+
+		d := &Discrepancy{
+			Kind:          DiscrepancyUnknownOrder,
+			OrderId:       o.Id,
+			ClientOrderId: o.ClientOrderId,
+			Detail:        fmt.Sprintf("%s %s %s@%s created at %d", o.Type, o.Side, o.Amount, o.Price, o.CreatedAt),
+		}
+		if p.configuration().Reconcile.UnknownOrders == UnknownCancel {
+			d.Action = "canceled"
+			if err := p.CancelOrder(m, o.Id); err != nil {
+				d.Action = "cancel_failed"
+				d.Detail += ", " + err.Error()
+			}
+		} else {
+			d.Action = "adopted"
+			p.journalAdopt(m, o)
+			p.adoptOrder(m, o)
+		}
+		add(d)
+	}
+
+	// 订单日志中仍为挂单的订单已经结束
+	for _, e := range jn.List(p.Name, m.Symbol, journal.StateSubmitted) {
+		if _, ok := open[e.OrderId]; ok || e.SubmittedAt.After(settled) {
+			continue
+		}
+		p.journalClose(e.OrderId)
+		add(&Discrepancy{
+			Kind:          DiscrepancyClosedOrder,
+			OrderId:       e.OrderId,
+			ClientOrderId: e.ClientOrderId,
+			Action:        "closed",
+			Detail:        "journal",
+		})
+	}
+
+	// 本地跟踪的订单已经结束
+	for _, id := range m.Orders.Prune(idSet(open), settled) {
+		p.ledger.Finish(id)
+		p.journalClose(id)
+		p.bus.Publish(&event.OrderUpdated{
+			Account: p.Name,
+			Symbol:  m.Symbol,
+			OrderId: id,
+			State:   "closed",
+			Time:    time.Now(),
+		})
+		add(&Discrepancy{
+			Kind:    DiscrepancyClosedOrder,
+			OrderId: id,
+			Action:  "untracked",
+			Detail:  "local",
+		})
+	}
+
+	return len(orders), nil
+}
+
+// 确认订单日志中pending和unknown的订单，在交易所找到时补记订单号，不存在时记录为failed
+func (p *Exchange) resolveUnresolved(m *Market, open map[string]*model.OrderInfo, settled time.Time, add func(*Discrepancy)) error {
+	jn := p.orderJournal()
+	entries := jn.List(p.Name, m.Symbol, journal.StatePending, journal.StateUnknown)
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var (
+		since time.Time
+		used  = make(map[string]bool)
+	)
+	for _, e := range entries {
+		if since.IsZero() || e.SubmittedAt.Before(since) {
+			since = e.SubmittedAt
+		}
+	}
+	recent, err := p.client().RecentOrders(m.Symbol, since.Add(-resolveSkew))
+	p.apiResult("get recent orders", err)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.State == journal.StatePending && e.SubmittedAt.After(settled) {
+			continue
+		}
+
+		req := &venue.OrderRequest{
+			Symbol:        e.Symbol,
+			Side:          e.Side,
+			Type:          e.Type,
+			Price:         e.Price,
+			Amount:        e.Amount,
+			ClientOrderId: e.ClientOrderId,
+		}
+		var found *model.OrderInfo
+		for _, o := range recent {
+			if !used[o.Id] && p.matchOrder(m, req, o) && sinceEntry(o, e) {
+				found = o
+				break
+			}
+		}
+
+		d := &Discrepancy{ClientOrderId: e.ClientOrderId}
+		if found == nil {
+			d.Kind = DiscrepancyUnresolvedMissing
+			d.Action = "failed"
+			p.journalUpdate(m.logger, e.ClientOrderId, "", journal.StateFailed, errors.New("order not found by reconciliation"))
+			add(d)
+			continue
+		}
+
+		used[found.Id] = true
+		d.Kind = DiscrepancyUnresolvedFound
+		d.OrderId = found.Id
+		d.Detail = found.State
+		if _, ok := open[found.Id]; ok {
+			d.Action = "adopted"
+			p.journalUpdate(m.logger, e.ClientOrderId, found.Id, journal.StateSubmitted, nil)
+		} else {
+			d.Action = "closed"
+			p.journalUpdate(m.logger, e.ClientOrderId, found.Id, journal.StateClosed, nil)
+		}
+		add(d)
+	}
+	return nil
+}
+
+// 订单不早于记录的提交时间太多，避免把更早的同参数订单当作这次提交的订单
+func sinceEntry(o *model.OrderInfo, e *journal.Entry) bool {
+	return o.CreatedAt == 0 || time.Unix(0, o.CreatedAt*int64(time.Millisecond)).After(e.SubmittedAt.Add(-resolveSkew))
+}
+
+// 纳入本地跟踪，启动前的成交不再通知
+func (p *Exchange) adoptOrder(m *Market, o *model.OrderInfo) {
+	filled, _ := strconv.ParseFloat(o.FilledAmount, 64)
+	m.Orders.Track(&TrackedOrder{
+		Id:        o.Id,
+		Side:      o.Side,
+		Price:     o.Price,
+		Amount:    o.Amount,
+		CreatedAt: time.Unix(0, o.CreatedAt*int64(time.Millisecond)),
+		Filled:    filled,
+	})
+}
+
+// 未知挂单补记到订单日志，之后的对账不再视为未知
+func (p *Exchange) journalAdopt(m *Market, o *model.OrderInfo) {
+	clientId := o.ClientOrderId
+	if clientId == "" {
+		clientId = "adopted-" + o.Id
+	}
+	err := p.orderJournal().Append(&journal.Entry{
+		ClientOrderId: clientId,
+		OrderId:       o.Id,
+		Account:       p.Name,
+		Symbol:        m.Symbol,
+		Side:          o.Side,
+		Type:          o.Type,
+		Price:         o.Price,
+		Amount:        o.Amount,
+		Strategy:      "adopted",
+		State:         journal.StateSubmitted,
+		SubmittedAt:   time.Unix(0, o.CreatedAt*int64(time.Millisecond)),
+	})
+	if err != nil {
+		m.logger.Errorw("write order journal failed", log.FieldOrderId, o.Id, "error", err)
+	}
+}
+
+//
+func idSet(orders map[string]*model.OrderInfo) map[string]bool {
+	var set = make(map[string]bool, len(orders))
+	for id := range orders {
+		set[id] = true
+	}
+	return set
+}
+
+// 输出日志并追加到报告文件，有差异或者失败时告警
+func (p *Exchange) writeReport(report *ReconcileReport) {
+	p.logger.Infow("reconciliation finished", "startup", report.Startup, "open_orders", report.OpenOrders,
+		"discrepancies", len(report.Discrepancies), "duration", report.Duration, "error", report.Error)
+
+	if path := p.configuration().Reconcile.ReportFile; path != "" {
+		err := appendReport(path, report)
+		if err != nil {
+			p.logger.Errorf("write reconciliation report to %s failed. %s", path, err)
+		}
+	}
+
+	if len(report.Discrepancies) == 0 && report.Error == "" {
+		return
+	}
+
+	var (
+		severity = notify.SeverityWarning
+		lines    = make([]string, 0, len(report.Discrepancies)+1)
+	)
+	if report.Error != "" {
+		severity = notify.SeverityCritical
+		lines = append(lines, "error: "+report.Error)
+	}
+	for _, d := range report.Discrepancies {
+		lines = append(lines, fmt.Sprintf("%s %s order %s %s", d.Symbol, d.Kind, d.OrderId, d.Action))
+	}
+	notify.Send(&notify.Event{
+		Kind:     notify.KindReconcile,
+		Severity: severity,
+		Account:  p.Name,
+		Title:    fmt.Sprintf("reconciliation found %d discrepancies", len(report.Discrepancies)),
+		Message:  strings.Join(lines, "\n"),
+	})
+}
+
+//
+func appendReport(path string, report *ReconcileReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
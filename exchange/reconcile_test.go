@@ -0,0 +1,275 @@
+package exchange
+
+import (
+	"errors"
+	"fcoinExchange/journal"
+	"fcoinExchange/model"
+	"testing"
+	"time"
+)
+
+// 毫秒时间戳
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// 按差异类型和订单号查找
+func findDiscrepancy(report *ReconcileReport, kind, id string) *Discrepancy {
+	for _, v := range report.Discrepancies {
+		if v.Kind == kind && (v.OrderId == id || v.ClientOrderId == id) {
+			return v
+		}
+	}
+	return nil
+}
+
+//
+func tracked(m *Market, id string) bool {
+	for _, v := range m.Orders.List() {
+		if v.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+//
+func TestReconcileUnknownOrders(t *testing.T) {
+	var (
+		hourAgo = millis(time.Now().Add(-time.Hour))
+		unknown = &model.OrderInfo{Id: "9", Symbol: "btcusdt", Side: "buy", Type: "limit", Price: "100", Amount: "0.5", CreatedAt: hourAgo}
+	)
+
+	for _, c := range []struct {
+		name      string
+		action    string
+		cancelErr error
+		want      string
+		adopted   bool
+		canceled  bool
+	}{
+		{name: "default", want: "adopted", adopted: true},
+		{name: "adopt", action: UnknownAdopt, want: "adopted", adopted: true},
+		{name: "cancel", action: UnknownCancel, want: "canceled", canceled: true},
+		{name: "cancel failed", action: UnknownCancel, cancelErr: errors.New("rejected"), want: "cancel_failed"},
+	} {
+		v := newFakeVenue()
+		v.open = []*model.OrderInfo{unknown}
+		v.cancelErr = c.cancelErr
+		ex, m := newResolveExchange(t, v)
+		ex.configuration().Reconcile.UnknownOrders = c.action
+
+		report, err := ex.Reconcile(true)
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		if !ex.Reconciled() {
+			t.Errorf("%s: trading not enabled after startup reconciliation", c.name)
+		}
+		d := findDiscrepancy(report, DiscrepancyUnknownOrder, "9")
+		if d == nil || d.Action != c.want {
+			t.Errorf("%s: discrepancy %+v, want %s", c.name, d, c.want)
+		}
+		if got := tracked(m, "9"); got != c.adopted {
+			t.Errorf("%s: tracked %v, want %v", c.name, got, c.adopted)
+		}
+		if _, ok := ex.orderJournal().Order("9"); ok != c.adopted {
+			t.Errorf("%s: journaled %v, want %v", c.name, ok, c.adopted)
+		}
+		if got := len(v.canceled) == 1; got != c.canceled {
+			t.Errorf("%s: canceled %v, want %v", c.name, v.canceled, c.canceled)
+		}
+
+		// 接管后的订单不再是未知挂单
+		if c.adopted {
+			report, err = ex.Reconcile(false)
+			if err != nil || len(report.Discrepancies) != 0 {
+				t.Errorf("%s: second reconciliation %+v, %v", c.name, report.Discrepancies, err)
+			}
+		}
+	}
+}
+
+// 订单日志中的挂单和刚提交的订单不是未知挂单
+func TestReconcileKnownOrders(t *testing.T) {
+	var (
+		now     = time.Now()
+		hourAgo = millis(now.Add(-time.Hour))
+	)
+	v := newFakeVenue()
+	v.open = []*model.OrderInfo{
+		{Id: "7", Symbol: "btcusdt", Side: "buy", Type: "limit", Price: "100", Amount: "0.5", CreatedAt: hourAgo},
+		{Id: "8", Symbol: "btcusdt", Side: "sell", Type: "limit", Price: "110", Amount: "0.5", CreatedAt: hourAgo, ClientOrderId: "c8"},
+		// 定时对账时刚提交的订单可能还没有记录结果
+		{Id: "10", Symbol: "btcusdt", Side: "sell", Type: "limit", Price: "120", Amount: "0.5", CreatedAt: millis(now)},
+	}
+	ex, m := newResolveExchange(t, v)
+	ex.configuration().Reconcile.UnknownOrders = UnknownCancel
+
+	jn := ex.orderJournal()
+	for _, e := range []*journal.Entry{
+		{ClientOrderId: "c7", OrderId: "7", State: journal.StateSubmitted},
+		// 只有客户端订单号的记录补记订单号
+		{ClientOrderId: "c8", State: journal.StateSubmitted},
+	} {
+		e.Account, e.Symbol, e.Side, e.Type, e.Amount = ex.Name, "btcusdt", "buy", "limit", "0.5"
+		e.SubmittedAt = now.Add(-time.Hour)
+		if err := jn.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := ex.Reconcile(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Discrepancies) != 0 || len(v.canceled) != 0 {
+		t.Errorf("discrepancies %+v, canceled %v, want none", report.Discrepancies, v.canceled)
+	}
+	if report.OpenOrders != 3 {
+		t.Errorf("open orders %d, want 3", report.OpenOrders)
+	}
+	if !tracked(m, "7") || !tracked(m, "8") || tracked(m, "10") {
+		t.Errorf("tracked %+v, want 7 and 8", m.Orders.List())
+	}
+	if e, _ := jn.Get("c8"); e == nil || e.OrderId != "8" {
+		t.Errorf("journal entry %+v, want order 8", e)
+	}
+}
+
+// 结果不确定的订单在最近的订单中找到时补记订单号，没有找到时记录为failed
+func TestReconcileUnresolved(t *testing.T) {
+	var (
+		now     = time.Now()
+		created = millis(now.Add(-time.Minute))
+	)
+	v := newFakeVenue()
+	v.recent = []*model.OrderInfo{
+		{Id: "42", Symbol: "btcusdt", Side: "buy", Type: "limit", Price: "100", Amount: "0.5", CreatedAt: created},
+		{Id: "43", Symbol: "btcusdt", Side: "sell", Type: "limit", Price: "110", Amount: "0.5", CreatedAt: created, State: "filled"},
+	}
+	v.open = []*model.OrderInfo{v.recent[0]}
+	ex, m := newResolveExchange(t, v)
+
+	jn := ex.orderJournal()
+	for _, e := range []*journal.Entry{
+		{ClientOrderId: "open", Side: "buy", Price: "100.00", State: journal.StateUnknown},
+		{ClientOrderId: "filled", Side: "sell", Price: "110.00", State: journal.StateUnknown},
+		{ClientOrderId: "missing", Side: "buy", Price: "90.00", State: journal.StatePending},
+	} {
+		e.Account, e.Symbol, e.Type, e.Amount = ex.Name, "btcusdt", "limit", "0.5000"
+		e.SubmittedAt = now.Add(-2 * time.Minute)
+		if err := jn.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := ex.Reconcile(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		client  string
+		kind    string
+		action  string
+		state   string
+		orderId string
+	}{
+		{client: "open", kind: DiscrepancyUnresolvedFound, action: "adopted", state: journal.StateSubmitted, orderId: "42"},
+		{client: "filled", kind: DiscrepancyUnresolvedFound, action: "closed", state: journal.StateClosed, orderId: "43"},
+		{client: "missing", kind: DiscrepancyUnresolvedMissing, action: "failed", state: journal.StateFailed},
+	} {
+		d := findDiscrepancy(report, c.kind, c.client)
+		if d == nil || d.Action != c.action || d.OrderId != c.orderId {
+			t.Errorf("%s: discrepancy %+v, want %s %s", c.client, d, c.action, c.orderId)
+		}
+		if e, _ := jn.Get(c.client); e == nil || e.State != c.state || e.OrderId != c.orderId {
+			t.Errorf("%s: journal entry %+v, want %s %s", c.client, e, c.state, c.orderId)
+		}
+	}
+	if !tracked(m, "42") || findDiscrepancy(report, DiscrepancyUnknownOrder, "42") != nil {
+		t.Errorf("found open order not adopted silently")
+	}
+}
+
+// 订单日志和本地跟踪的挂单在交易所已经结束
+func TestReconcileClosedOrders(t *testing.T) {
+	v := newFakeVenue()
+	ex, m := newResolveExchange(t, v)
+
+	past := time.Now().Add(-time.Hour)
+	err := ex.orderJournal().Append(&journal.Entry{
+		ClientOrderId: "c5", OrderId: "5", Account: ex.Name, Symbol: "btcusdt",
+		Side: "buy", Type: "limit", Amount: "0.5", State: journal.StateSubmitted, SubmittedAt: past,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Orders.Track(&TrackedOrder{Id: "6", Side: "sell", Price: "110", Amount: "0.5", CreatedAt: past})
+
+	report, err := ex.Reconcile(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := findDiscrepancy(report, DiscrepancyClosedOrder, "5"); d == nil || d.Action != "closed" {
+		t.Errorf("journal order discrepancy %+v, want closed", d)
+	}
+	if e, _ := ex.orderJournal().Get("c5"); e == nil || e.State != journal.StateClosed {
+		t.Errorf("journal entry %+v, want closed", e)
+	}
+	if d := findDiscrepancy(report, DiscrepancyClosedOrder, "6"); d == nil || d.Action != "untracked" {
+		t.Errorf("local order discrepancy %+v, want untracked", d)
+	}
+	if tracked(m, "6") {
+		t.Errorf("closed order still tracked")
+	}
+}
+
+// 对账后余额以交易所为准
+func TestReconcileBalanceDrift(t *testing.T) {
+	v := newFakeVenue()
+	v.setBalances(&model.BalanceContext{Currency: "btc", Available: "1", Frozen: "0", Balance: "1"})
+	ex, _ := newResolveExchange(t, v)
+	if err := ex.UpdateBalance(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 进程停止期间的成交改变了余额
+	v.setBalances(
+		&model.BalanceContext{Currency: "btc", Available: "1.5", Frozen: "0.5", Balance: "2"},
+		&model.BalanceContext{Currency: "usdt", Available: "100", Frozen: "0", Balance: "100"},
+	)
+	if _, err := ex.Reconcile(true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		currency  string
+		available float64
+		total     float64
+	}{
+		{currency: "btc", available: 1.5, total: 2},
+		{currency: "usdt", available: 100, total: 100},
+	} {
+		b, err := ex.Balance(c.currency)
+		if err != nil || b.Available != c.available || b.Total != c.total {
+			t.Errorf("%s: balance %+v, %v, want %v of %v", c.currency, b, err, c.available, c.total)
+		}
+	}
+}
+
+// 刷新余额失败时对账失败，不允许交易
+func TestReconcileFailed(t *testing.T) {
+	v := newFakeVenue()
+	v.setBalances(&model.BalanceContext{Currency: "btc", Available: "x", Frozen: "0", Balance: "1"})
+	ex, _ := newResolveExchange(t, v)
+
+	report, err := ex.Reconcile(true)
+	if err == nil || report.Error == "" {
+		t.Fatalf("reconciliation with invalid balance succeeded")
+	}
+	if ex.Reconciled() {
+		t.Errorf("trading enabled after failed reconciliation")
+	}
+}
@@ -0,0 +1,171 @@
+package exchange
+
+import (
+	"fcoinExchange/journal"
+	"fcoinExchange/log"
+	"fcoinExchange/model"
+	"fcoinExchange/venue"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// 下单结果不确定时查询最近订单的次数和间隔，交易所的订单列表可能稍有延迟
+	resolveAttempts = 3
+	resolveWait     = 2 * time.Second
+	// 查询最近订单时向前多查的时间，容忍本地与服务器的时钟偏差
+	resolveSkew = 10 * time.Second
+)
+
+// 使用客户端订单号提交订单，提交前后都记录到订单日志。
+// 请求超时等结果不确定的错误不直接返回失败，而是查询最近的订单确认订单是否已经创建，找到时按提交成功处理。
+// 没有找到不代表订单不存在，交易所的订单列表可能有延迟：只有交易所拒绝重复的客户端订单号时，
+// 才使用同一个客户端订单号重试一次，此时订单已经存在的话重试会被拒绝而不会重复下单；
+// 其他交易所不重试，记录为unknown由对账确认
+func (p *Exchange) send(m *Market, lg *zap.SugaredLogger, strategy string, req *venue.OrderRequest) (string, error) {
+	if req.ClientOrderId == "" {
+		req.ClientOrderId = journal.NewClientOrderId()
+	}
+	lg = lg.With("client_order_id", req.ClientOrderId)
+
+	jn := p.orderJournal()
+	err := jn.Append(&journal.Entry{
+		ClientOrderId: req.ClientOrderId,
+		Account:       p.Name,
+		Symbol:        req.Symbol,
+		Side:          req.Side,
+		Type:          req.Type,
+		Price:         req.Price,
+		Amount:        req.Amount,
+		TimeInForce:   req.TimeInForce,
+		PostOnly:      req.PostOnly,
+		Strategy:      strategy,
+		State:         journal.StatePending,
+		SubmittedAt:   time.Now(),
+	})
+	if err != nil {
+		// 没有记录的订单在结果不确定时无法对账，不提交
+		lg.Errorw("write order journal failed", "error", err)
+		return "", fmt.Errorf("write order journal failed. %s", err)
+	}
+
+	for retried := false; ; retried = true {
+		since := time.Now()
+		id, err := p.client().PlaceOrder(req)
+		p.apiResult("create order", err)
+		if err == nil {
+			p.journalUpdate(lg, req.ClientOrderId, id, journal.StateSubmitted, nil)
+			return id, nil
+		}
+		if !venue.IsAmbiguous(err) {
+			p.journalUpdate(lg, req.ClientOrderId, "", journal.StateFailed, err)
+			return "", err
+		}
+
+		lg.Warnw("create order result unknown, look up recent orders", "error", err)
+		id, found, lerr := p.resolveOrder(m, req, since)
+		switch {
+		case found:
+			lg.Infow("order found after ambiguous create order", log.FieldOrderId, id)
+			p.journalUpdate(lg, req.ClientOrderId, id, journal.StateSubmitted, nil)
+			return id, nil
+		case lerr != nil:
+			lg.Errorw("can not confirm whether order exists, leave it to reconciliation", "error", lerr)
+			p.journalUpdate(lg, req.ClientOrderId, "", journal.StateUnknown, err)
+			return "", err
+		case retried || !p.client().DedupClientOrderId():
+			lg.Warnw("order not found after ambiguous create order, leave it to reconciliation")
+			p.journalUpdate(lg, req.ClientOrderId, "", journal.StateUnknown, err)
+			return "", err
+		}
+		lg.Infow("order not found after ambiguous create order, retry with the same client order id")
+	}
+}
+
+// 查询since之后创建的订单，交易所返回客户端订单号时按客户端订单号匹配，
+// 否则按方向、类型、价格和数量匹配，并排除订单日志和本地已经记录的订单。
+// 所有查询都失败时返回错误
+func (p *Exchange) resolveOrder(m *Market, req *venue.OrderRequest, since time.Time) (string, bool, error) {
+	var (
+		checked bool
+		lastErr error
+	)
+	for i := 0; i < resolveAttempts; i++ {
+		time.Sleep(resolveWait)
+
+		orders, err := p.client().RecentOrders(req.Symbol, since.Add(-resolveSkew))
+		p.apiResult("get recent orders", err)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		checked = true
+
+		for _, o := range orders {
+			if p.matchOrder(m, req, o) {
+				return o.Id, true, nil
+			}
+		}
+	}
+
+	if !checked {
+		return "", false, lastErr
+	}
+	return "", false, nil
+}
+
+//
+func (p *Exchange) matchOrder(m *Market, req *venue.OrderRequest, o *model.OrderInfo) bool {
+	if o.ClientOrderId != "" {
+		return o.ClientOrderId == req.ClientOrderId
+	}
+	if o.Side != req.Side || o.Type != req.Type || !sameNumber(o.Amount, req.Amount) {
+		return false
+	}
+	if req.Type == venue.TypeLimit && !sameNumber(o.Price, req.Price) {
+		return false
+	}
+
+	if _, ok := p.orderJournal().Order(o.Id); ok {
+		return false
+	}
+	for _, v := range m.Orders.List() {
+		if v.Id == o.Id {
+			return false
+		}
+	}
+	return true
+}
+
+// 比较交易所返回的数值和提交的数值，交易所可能补齐小数位
+func sameNumber(a, b string) bool {
+	x, err1 := strconv.ParseFloat(a, 64)
+	y, err2 := strconv.ParseFloat(b, 64)
+	return err1 == nil && err2 == nil && x == y
+}
+
+// 写入失败只记录日志，订单的实际状态以交易所为准
+func (p *Exchange) journalUpdate(lg *zap.SugaredLogger, clientId, orderId, state string, cause error) {
+	err := p.orderJournal().Update(clientId, orderId, state, cause)
+	if err != nil {
+		lg.Errorw("write order journal failed", "state", state, "error", err)
+	}
+}
+
+// 订单结束后在订单日志中标记为closed
+func (p *Exchange) journalClose(id string) {
+	err := p.orderJournal().UpdateOrder(id, journal.StateClosed)
+	if err != nil {
+		p.logger.Errorw("write order journal failed", log.FieldOrderId, id, "error", err)
+	}
+}
+
+//
+func (p *Exchange) orderJournal() *journal.Journal {
+	p.RLock()
+	defer p.RUnlock()
+	return p.journal
+}
@@ -0,0 +1,128 @@
+package exchange
+
+import (
+	"errors"
+	"fcoinExchange/journal"
+	"fcoinExchange/model"
+	"fcoinExchange/venue"
+	"path/filepath"
+	"testing"
+)
+
+// 使用订单日志的测试账户，查询最近订单不等待
+func newResolveExchange(t *testing.T, v *fakeVenue) (*Exchange, *Market) {
+	wait := resolveWait
+	resolveWait = 0
+	t.Cleanup(func() { resolveWait = wait })
+
+	ex := newTestExchange(t, v, &model.Configuration{JournalFile: filepath.Join(t.TempDir(), "orders.jsonl")})
+	m, err := ex.Market("btcusdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ex, m
+}
+
+//
+func resolveRequest() *venue.OrderRequest {
+	return &venue.OrderRequest{Symbol: "btcusdt", Side: "buy", Type: venue.TypeLimit, Price: "100.00", Amount: "0.5000"}
+}
+
+var errTimeout = &venue.AmbiguousError{Err: errors.New("timeout")}
+
+// 不拒绝重复客户端订单号的交易所不重试，记录为unknown
+func TestSendAmbiguousWithoutDedup(t *testing.T) {
+	v := newFakeVenue()
+	v.placeErrs = []error{errTimeout}
+	ex, m := newResolveExchange(t, v)
+
+	req := resolveRequest()
+	_, err := ex.send(m, ex.logger, "test", req)
+	if err == nil {
+		t.Fatal("ambiguous create order succeeded")
+	}
+	if n := len(v.placed()); n != 1 {
+		t.Errorf("placed %d orders, want no retry", n)
+	}
+	if e, _ := ex.orderJournal().Get(req.ClientOrderId); e == nil || e.State != journal.StateUnknown {
+		t.Errorf("journal entry = %+v, want unknown", e)
+	}
+}
+
+// 拒绝重复客户端订单号的交易所使用同一个客户端订单号重试一次
+func TestSendAmbiguousRetryWithDedup(t *testing.T) {
+	v := newFakeVenue()
+	v.dedup = true
+	v.placeErrs = []error{errTimeout}
+	ex, m := newResolveExchange(t, v)
+
+	req := resolveRequest()
+	id, err := ex.send(m, ex.logger, "test", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	placed := v.placed()
+	if len(placed) != 2 || placed[0].ClientOrderId != placed[1].ClientOrderId {
+		t.Fatalf("placed %d orders, want one retry with the same client order id", len(placed))
+	}
+	if e, _ := ex.orderJournal().Get(req.ClientOrderId); e == nil || e.State != journal.StateSubmitted || e.OrderId != id {
+		t.Errorf("journal entry = %+v, want submitted %s", e, id)
+	}
+
+	// 重试的结果仍然不确定时不再重试
+	v.placeErrs = []error{errTimeout, errTimeout}
+	req = resolveRequest()
+	_, err = ex.send(m, ex.logger, "test", req)
+	if err == nil {
+		t.Fatal("ambiguous retry succeeded")
+	}
+	if n := len(v.placed()); n != 4 {
+		t.Errorf("placed %d orders, want 4", n)
+	}
+	if e, _ := ex.orderJournal().Get(req.ClientOrderId); e == nil || e.State != journal.StateUnknown {
+		t.Errorf("journal entry = %+v, want unknown", e)
+	}
+}
+
+// 在最近的订单中找到时按提交成功处理，不重试
+func TestSendAmbiguousFound(t *testing.T) {
+	for _, dedup := range []bool{false, true} {
+		v := newFakeVenue()
+		v.dedup = dedup
+		v.placeErrs = []error{errTimeout}
+		v.recent = []*model.OrderInfo{
+			{Id: "other", Symbol: "btcusdt", Side: "sell", Type: "limit", Price: "100", Amount: "0.5"},
+			{Id: "42", Symbol: "btcusdt", Side: "buy", Type: "limit", Price: "100", Amount: "0.5"},
+		}
+		ex, m := newResolveExchange(t, v)
+
+		req := resolveRequest()
+		id, err := ex.send(m, ex.logger, "test", req)
+		if err != nil || id != "42" {
+			t.Errorf("dedup %v: send = %s, %v, want order 42", dedup, id, err)
+		}
+		if n := len(v.placed()); n != 1 {
+			t.Errorf("dedup %v: placed %d orders, want no retry", dedup, n)
+		}
+	}
+}
+
+// 交易所明确拒绝时记录为failed
+func TestSendRejected(t *testing.T) {
+	v := newFakeVenue()
+	v.dedup = true
+	v.placeErrs = []error{errors.New("insufficient balance")}
+	ex, m := newResolveExchange(t, v)
+
+	req := resolveRequest()
+	_, err := ex.send(m, ex.logger, "test", req)
+	if err == nil {
+		t.Fatal("rejected order succeeded")
+	}
+	if n := len(v.placed()); n != 1 {
+		t.Errorf("placed %d orders, want 1", n)
+	}
+	if e, _ := ex.orderJournal().Get(req.ClientOrderId); e == nil || e.State != journal.StateFailed {
+		t.Errorf("journal entry = %+v, want failed", e)
+	}
+}
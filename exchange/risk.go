@@ -0,0 +1,51 @@
+package exchange
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 记录当天已提交的订单数量
+type riskCounter struct {
+	day    string
+	orders int
+	// 当天是否已经因为订单数量上限停止下单
+	tripped bool
+	sync.Mutex
+}
+
+// 检查订单是否超过账户的风险限制，未超过时计入当天订单数量。
+// 市价单的价格和数量为估算值
+func (p *Exchange) checkRisk(pr, a float64) error {
+	limits := p.configuration().Risk
+
+	if limits.MaxOrderAmount > 0 && a > limits.MaxOrderAmount {
+		return fmt.Errorf("order amount %v exceeds max_order_amount %v", a, limits.MaxOrderAmount)
+	}
+
+	if limits.MaxOrderValue > 0 && a*pr > limits.MaxOrderValue {
+		return fmt.Errorf("order value %v exceeds max_order_value %v", a*pr, limits.MaxOrderValue)
+	}
+
+	p.risk.Lock()
+	defer p.risk.Unlock()
+
+	today := time.Now().Format("2006-01-02")
+	if p.risk.day != today {
+		p.risk.day = today
+		p.risk.orders = 0
+		p.risk.tripped = false
+	}
+
+	if limits.MaxDailyOrders > 0 && p.risk.orders >= limits.MaxDailyOrders {
+		if !p.risk.tripped {
+			p.risk.tripped = true
+			p.killSwitchTripped(today, limits.MaxDailyOrders)
+		}
+		return fmt.Errorf("daily orders reach max_daily_orders %d", limits.MaxDailyOrders)
+	}
+	p.risk.orders++
+
+	return nil
+}
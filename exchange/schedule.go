@@ -0,0 +1,123 @@
+package exchange
+
+import (
+	"fcoinExchange/health"
+	"fcoinExchange/log"
+	"fcoinExchange/metrics"
+	"fcoinExchange/notify"
+	"fcoinExchange/schedule"
+	"time"
+)
+
+var (
+	// 检查交易时间安排的时间间隔
+	scheduleInterval = 10 * time.Second
+)
+
+// 按schedule配置开始和停止交易，并在交易时间段变化时替换交易对参数。
+// 停止交易时下单任务跳过每次执行，行情、余额、挂单检查和对账任务继续执行
+func (p *Exchange) AutoSchedule(r *health.Run) {
+	defer notify.Recover(p.Name, "schedule")
+
+	tk := time.NewTicker(scheduleInterval)
+	defer tk.Stop()
+	for {
+		r.Beat()
+		p.updateSchedule(time.Now())
+		select {
+		case <-tk.C:
+		case <-r.Done():
+			return
+		}
+	}
+}
+
+// 是否在交易时间内
+func (p *Exchange) Trading() bool {
+	p.RLock()
+	defer p.RUnlock()
+	return p.sched == nil || p.sched.Active
+}
+
+// 计算now时刻的交易安排，状态变化时记录日志、告警，配置了cancel_on_stop时停止交易后撤销挂单
+func (p *Exchange) updateSchedule(now time.Time) {
+	cfg := p.configuration().Schedule
+	if p.schedule == nil || cfg != p.schedCfg {
+		sc, err := schedule.New(cfg)
+		if err != nil {
+			p.logger.Errorf("invalid schedule, keep current schedule. %s", err)
+			if p.schedule == nil {
+				return
+			}
+		} else {
+			p.schedule = sc
+			p.schedCfg = cfg
+		}
+	}
+
+	st := p.schedule.At(now)
+	p.Lock()
+	old := p.sched
+	p.sched = st
+	p.Unlock()
+
+	// 配置变化后新增的交易对也需要设置
+	for _, m := range p.Markets() {
+		m.setWindow(st.Window)
+	}
+
+	if old != nil && old.String() == st.String() {
+		return
+	}
+	p.logger.Infow("trading schedule changed", "from", old, "to", st, "trading", st.Active)
+	if old == nil {
+		return
+	}
+
+	action := "started"
+	if !st.Active {
+		action = "stopped"
+	}
+	metrics.Inc("schedule", p.Name, action)
+	notify.Send(&notify.Event{
+		Kind:     notify.KindSchedule,
+		Severity: notify.SeverityInfo,
+		Account:  p.Name,
+		Key:      action,
+		Title:    "trading " + action + " by schedule",
+		Message:  old.String() + " -> " + st.String(),
+	})
+
+	if old.Active && !st.Active && cfg != nil && cfg.CancelOnStop != nil && *cfg.CancelOnStop {
+		p.cancelAll()
+	}
+}
+
+// 撤销所有交易对的挂单和正在执行的再平衡算法订单
+func (p *Exchange) cancelAll() {
+	for _, m := range p.Markets() {
+		if o := m.makeUpOrder(); o != nil && m.makeUpRunning() {
+			if err := o.Cancel(); err != nil {
+				m.logger.Warnw("cancel rebalance algo order failed", "algo_id", o.Id, "error", err)
+			}
+		}
+
+		orders, err := p.ListOpenOrders(m)
+		p.apiResult("list orders", err)
+		if err != nil {
+			m.logger.Errorf("get open orders failed, orders not canceled. %s", err)
+			continue
+		}
+		for _, o := range orders {
+			m.logger.Infow("cancel order by schedule", log.FieldOrderId, o.Id)
+			p.CancelOrder(m, o.Id)
+		}
+	}
+}
+
+// 当前的交易安排，未计算过时为nil
+func (p *Exchange) ScheduleState() *schedule.State {
+	p.RLock()
+	defer p.RUnlock()
+	return p.sched
+}
@@ -0,0 +1,132 @@
+package exchange
+
+import (
+	"fcoinExchange/model"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 交易状态的只读快照，发布后不会再被修改，调用者也不应修改其内容
+type Snapshot struct {
+	Version uint64
+	// 交易对 -> 最新行情
+	Quotes map[string]*model.Quote
+	// 币种 -> 交易所返回的余额
+	Balances         map[string]*model.BalanceContext
+	BalanceUpdatedAt time.Time
+}
+
+// 交易状态存储。写入时复制当前快照，修改副本后整体替换，读取时直接返回当前快照无需加锁
+type State struct {
+	v  atomic.Value
+	mu sync.Mutex
+}
+
+//
+func NewState() *State {
+	var s = new(State)
+	s.v.Store(&Snapshot{
+		Quotes:   make(map[string]*model.Quote),
+		Balances: make(map[string]*model.BalanceContext),
+	})
+	return s
+}
+
+// 返回当前快照
+func (p *State) Load() *Snapshot {
+	return p.v.Load().(*Snapshot)
+}
+
+// 串行化写入，fn修改的是当前快照的副本
+func (p *State) update(fn func(s *Snapshot)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old := p.Load()
+	var s = &Snapshot{
+		Version:          old.Version + 1,
+		Quotes:           make(map[string]*model.Quote, len(old.Quotes)),
+		Balances:         make(map[string]*model.BalanceContext, len(old.Balances)),
+		BalanceUpdatedAt: old.BalanceUpdatedAt,
+	}
+	for k, v := range old.Quotes {
+		s.Quotes[k] = v
+	}
+	for k, v := range old.Balances {
+		s.Balances[k] = v
+	}
+
+	fn(s)
+	p.v.Store(s)
+}
+
+// 更新交易对的行情，q在存入后不能再被修改
+func (p *State) SetQuote(symbol string, q *model.Quote) {
+	p.update(func(s *Snapshot) {
+		s.Quotes[symbol] = q
+	})
+}
+
+// 用交易所返回的余额替换所有余额
+func (p *State) SetBalances(data []*model.BalanceContext, at time.Time) {
+	p.update(func(s *Snapshot) {
+		s.Balances = make(map[string]*model.BalanceContext, len(data))
+		for _, v := range data {
+			b := *v
+			s.Balances[v.Currency] = &b
+		}
+		s.BalanceUpdatedAt = at
+	})
+}
+
+// 交易对的只读视图
+type MarketSnapshot struct {
+	Symbol        string
+	BaseCurrency  string
+	QuoteCurrency string
+	Quote         *model.Quote
+	Params        model.SymbolConfig
+	Orders        []TrackedOrder
+}
+
+// 账户的完整只读视图
+type ExchangeSnapshot struct {
+	Name string
+	*Snapshot
+	Markets []*MarketSnapshot
+}
+
+// 返回账户当前状态的快照，包括行情、余额、交易对参数和本地跟踪的订单
+func (p *Exchange) Snapshot() *ExchangeSnapshot {
+	var (
+		st = p.state.Load()
+		es = &ExchangeSnapshot{
+			Name:     p.Name,
+			Snapshot: st,
+			Markets:  make([]*MarketSnapshot, 0),
+		}
+	)
+
+	for _, m := range p.Markets() {
+		ms := &MarketSnapshot{
+			Symbol:        m.Symbol,
+			BaseCurrency:  m.BaseCurrency,
+			QuoteCurrency: m.QuoteCurrency,
+			Quote:         st.Quotes[m.Symbol],
+			Params:        *m.Params(),
+			Orders:        make([]TrackedOrder, 0),
+		}
+		for _, o := range m.Orders.List() {
+			ms.Orders = append(ms.Orders, *o)
+		}
+		es.Markets = append(es.Markets, ms)
+	}
+
+	return es
+}
+
+// 返回交易对最新的行情，尚未获取到行情时返回nil
+func (p *Exchange) GetQuote(symbol string) *model.Quote {
+	return p.state.Load().Quotes[symbol]
+}
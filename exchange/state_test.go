@@ -0,0 +1,130 @@
+package exchange
+
+import (
+	"fcoinExchange/model"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 并发写入行情和余额，读取的快照版本号只增不减，每个快照中的余额来自同一次写入
+func TestStateConcurrentUpdate(t *testing.T) {
+	var (
+		s       = NewState()
+		symbols = []string{"btcusdt", "ethusdt", "ftusdt"}
+		rounds  = 500
+		wg      sync.WaitGroup
+		done    = make(chan struct{})
+	)
+
+	for _, symbol := range symbols {
+		wg.Add(1)
+		go func(symbol string) {
+			defer wg.Done()
+			for i := 1; i <= rounds; i++ {
+				s.SetQuote(symbol, &model.Quote{Seq: int64(i), MaxBuyOnePrice: float64(i), MinSellOnePrice: float64(i)})
+			}
+		}(symbol)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= rounds; i++ {
+			v := strconv.Itoa(i)
+			s.SetBalances([]*model.BalanceContext{
+				{Currency: "btc", Available: v, Frozen: "0", Balance: v},
+				{Currency: "usdt", Available: v, Frozen: "0", Balance: v},
+			}, time.Unix(int64(i), 0))
+		}
+	}()
+
+	var readers sync.WaitGroup
+	errs := make(chan string, 8)
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			var (
+				version uint64
+				seqs    = make(map[string]int64)
+			)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				snap := s.Load()
+				if snap.Version < version {
+					errs <- "version decreased from " + strconv.FormatUint(version, 10) + " to " + strconv.FormatUint(snap.Version, 10)
+					return
+				}
+				version = snap.Version
+
+				for symbol, q := range snap.Quotes {
+					if q.Seq < seqs[symbol] {
+						errs <- symbol + " quote went backwards"
+						return
+					}
+					if q.MaxBuyOnePrice != float64(q.Seq) || q.MinSellOnePrice != float64(q.Seq) {
+						errs <- symbol + " quote is torn"
+						return
+					}
+					seqs[symbol] = q.Seq
+				}
+
+				if snap.BalanceUpdatedAt.IsZero() {
+					continue
+				}
+				want := strconv.FormatInt(snap.BalanceUpdatedAt.Unix(), 10)
+				for currency, b := range snap.Balances {
+					if b.Available != want || b.Balance != want {
+						errs <- currency + " balance " + b.Available + " not from update " + want
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+	close(errs)
+	for e := range errs {
+		t.Error(e)
+	}
+
+	snap := s.Load()
+	if want := uint64(rounds * (len(symbols) + 1)); snap.Version != want {
+		t.Errorf("version = %d, want %d", snap.Version, want)
+	}
+	for _, symbol := range symbols {
+		if q := snap.Quotes[symbol]; q == nil || q.Seq != int64(rounds) {
+			t.Errorf("%s quote = %+v, want seq %d", symbol, q, rounds)
+		}
+	}
+}
+
+// 修改新快照不影响已经发布的快照
+func TestStateSnapshotImmutable(t *testing.T) {
+	s := NewState()
+	s.SetBalances([]*model.BalanceContext{{Currency: "btc", Available: "1", Frozen: "0", Balance: "1"}}, time.Unix(1, 0))
+	old := s.Load()
+
+	s.SetQuote("btcusdt", &model.Quote{Seq: 1})
+	s.SetBalances([]*model.BalanceContext{{Currency: "usdt", Available: "2", Frozen: "0", Balance: "2"}}, time.Unix(2, 0))
+
+	if len(old.Quotes) != 0 {
+		t.Errorf("old snapshot quotes changed: %v", old.Quotes)
+	}
+	if b, ok := old.Balances["btc"]; !ok || b.Available != "1" || len(old.Balances) != 1 {
+		t.Errorf("old snapshot balances changed: %v", old.Balances)
+	}
+	if _, ok := s.Load().Balances["btc"]; ok {
+		t.Errorf("SetBalances kept currency missing from the new response")
+	}
+}
@@ -0,0 +1,156 @@
+package exchange
+
+import (
+	"fcoinExchange/event"
+	"fcoinExchange/log"
+	"fcoinExchange/model"
+	"fcoinExchange/venue"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	log.Logger = log.Named("test")
+	os.Exit(m.Run())
+}
+
+// 测试使用的交易所，返回设置的行情和余额，记录提交的订单
+type fakeVenue struct {
+	sync.Mutex
+	symbols  []*model.Symbol
+	quotes   map[string]*model.Quote
+	balances []*model.BalanceContext
+	orders   []*venue.OrderRequest
+	// 依次作为下单的结果，用完后下单成功
+	placeErrs []error
+	// RecentOrders返回的订单
+	recent []*model.OrderInfo
+	// OpenOrders返回的挂单
+	open []*model.OrderInfo
+	// 撤销的订单号，cancelErr不为nil时撤单失败
+	canceled  []string
+	cancelErr error
+	dedup     bool
+}
+
+//
+func newFakeVenue() *fakeVenue {
+	return &fakeVenue{
+		symbols: []*model.Symbol{
+			{Name: "btcusdt", BaseCurrency: "btc", QuoteCurrency: "usdt", PriceDecimal: 2, AmountDecimal: 4},
+			{Name: "ftusdt", BaseCurrency: "ft", QuoteCurrency: "usdt", PriceDecimal: 6, AmountDecimal: 2},
+		},
+		quotes: make(map[string]*model.Quote),
+	}
+}
+
+//
+func (p *fakeVenue) setBalances(data ...*model.BalanceContext) {
+	p.Lock()
+	defer p.Unlock()
+	p.balances = data
+}
+
+//
+func (p *fakeVenue) setQuote(symbol string, bid, ask float64) {
+	p.Lock()
+	defer p.Unlock()
+	p.quotes[symbol] = &model.Quote{MaxBuyOnePrice: bid, MinSellOnePrice: ask}
+}
+
+//
+func (p *fakeVenue) placed() []*venue.OrderRequest {
+	p.Lock()
+	defer p.Unlock()
+	return append([]*venue.OrderRequest(nil), p.orders...)
+}
+
+func (p *fakeVenue) Name() string { return "fake" }
+
+func (p *fakeVenue) Symbols() ([]*model.Symbol, error) { return p.symbols, nil }
+
+func (p *fakeVenue) Ticker(symbol string) (*model.Quote, error) {
+	p.Lock()
+	defer p.Unlock()
+	q, ok := p.quotes[symbol]
+	if !ok {
+		return nil, fmt.Errorf("no quote for %s", symbol)
+	}
+	return q, nil
+}
+
+func (p *fakeVenue) Depth(symbol string, limit int) (*venue.Depth, error) {
+	return nil, fmt.Errorf("depth not supported")
+}
+
+func (p *fakeVenue) Balances() ([]*model.BalanceContext, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.balances, nil
+}
+
+func (p *fakeVenue) PlaceOrder(req *venue.OrderRequest) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+	p.orders = append(p.orders, req)
+	if len(p.placeErrs) > 0 {
+		err := p.placeErrs[0]
+		p.placeErrs = p.placeErrs[1:]
+		return "", err
+	}
+	return fmt.Sprintf("%d", len(p.orders)), nil
+}
+
+func (p *fakeVenue) CancelOrder(symbol, id string) error {
+	p.Lock()
+	defer p.Unlock()
+	if p.cancelErr != nil {
+		return p.cancelErr
+	}
+	p.canceled = append(p.canceled, id)
+	return nil
+}
+
+func (p *fakeVenue) Order(symbol, id string) (*model.OrderInfo, error) {
+	return nil, fmt.Errorf("order %s not found", id)
+}
+
+func (p *fakeVenue) OpenOrders(symbol string) ([]*model.OrderInfo, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.open, nil
+}
+
+func (p *fakeVenue) RecentOrders(symbol string, since time.Time) ([]*model.OrderInfo, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.recent, nil
+}
+
+func (p *fakeVenue) DedupClientOrderId() bool { return p.dedup }
+
+func (p *fakeVenue) Clock() venue.Clock { return venue.LocalClock{} }
+
+func (p *fakeVenue) SyncClock(samples int) error { return nil }
+
+// 使用fakeVenue创建只有btcusdt一个交易对的账户
+func newTestExchange(t *testing.T, v *fakeVenue, cfg *model.Configuration) *Exchange {
+	if cfg == nil {
+		cfg = &model.Configuration{}
+	}
+	if cfg.Name == "" {
+		cfg.Name = "test"
+	}
+	if cfg.Symbol == "" && len(cfg.Symbols) == 0 {
+		cfg.Symbol = "btcusdt"
+	}
+
+	ex, err := NewExchangeWithVenue(cfg, event.New(0), v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ex
+}
@@ -0,0 +1,276 @@
+package fcoin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// 录制时隐藏的请求头
+	redactedHeaders = []string{"FC-ACCESS-KEY", "FC-ACCESS-SIGNATURE"}
+
+	recorders = make(map[string]*Recorder)
+	recMu     sync.Mutex
+)
+
+// 一次请求和返回
+type Interaction struct {
+	Request  RecordedRequest   `json:"request"`
+	Response *RecordedResponse `json:"response,omitempty"`
+	// 请求没有得到返回时的错误
+	Error      string    `json:"error,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+//
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+}
+
+//
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+// 读取请求内容后恢复请求的Body
+func readBody(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return string(b), nil
+}
+
+// 匹配回放请求使用的标识，不包括带有时间戳的请求头
+func matchKey(method, url, body string) string {
+	return strings.ToUpper(method) + " " + url + " " + body
+}
+
+// 录制文件，每行一次请求和返回，可以追加写入
+type Recorder struct {
+	file *os.File
+	sync.Mutex
+}
+
+// 返回写入path的录制文件，同一个路径共用一个录制文件
+func RecordTo(path string) (*Recorder, error) {
+	recMu.Lock()
+	defer recMu.Unlock()
+
+	if r, ok := recorders[path]; ok {
+		return r, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{file: f}
+	recorders[path] = r
+	return r, nil
+}
+
+// 返回录制经过rt的请求和返回的RoundTripper，rt为空时使用http.DefaultTransport
+func (p *Recorder) Wrap(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &recordingTransport{rec: p, next: rt}
+}
+
+//
+func (p *Recorder) write(it *Interaction) error {
+	b, err := json.Marshal(it)
+	if err != nil {
+		return err
+	}
+	p.Lock()
+	defer p.Unlock()
+	_, err = p.file.Write(append(b, '\n'))
+	return err
+}
+
+//
+func (p *Recorder) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	return p.file.Close()
+}
+
+//
+type recordingTransport struct {
+	rec  *Recorder
+	next http.RoundTripper
+}
+
+//
+func (p *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var it = &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   body,
+		},
+		RecordedAt: time.Now(),
+	}
+	for _, h := range redactedHeaders {
+		if it.Request.Header.Get(h) != "" {
+			it.Request.Header.Set(h, "REDACTED")
+		}
+	}
+
+	resp, err := p.next.RoundTrip(req)
+	if err != nil {
+		it.Error = err.Error()
+		p.rec.write(it)
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	it.Response = &RecordedResponse{
+		Status: resp.StatusCode,
+		Header: resp.Header.Clone(),
+		Body:   string(b),
+	}
+	p.rec.write(it)
+	return resp, nil
+}
+
+// 录制的所有请求和返回
+type Cassette struct {
+	Interactions []*Interaction
+}
+
+// 读取录制文件
+func LoadCassette(path string) (*Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		c  = &Cassette{Interactions: make([]*Interaction, 0)}
+		sc = bufio.NewScanner(f)
+		n  int
+	)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		n++
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var it Interaction
+		err = json.Unmarshal(line, &it)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s line %d failed. %s", path, n, err)
+		}
+		c.Interactions = append(c.Interactions, &it)
+	}
+	return c, sc.Err()
+}
+
+// 按录制文件返回请求结果的RoundTripper，不访问网络。
+// 相同的请求按录制的顺序返回，Repeat为true时用完后重复返回最后一次的结果
+type Player struct {
+	Repeat bool
+
+	queues map[string][]*Interaction
+	last   map[string]*Interaction
+	sync.Mutex
+}
+
+//
+func NewPlayer(c *Cassette) *Player {
+	var p = &Player{
+		queues: make(map[string][]*Interaction),
+		last:   make(map[string]*Interaction),
+	}
+	for _, it := range c.Interactions {
+		key := matchKey(it.Request.Method, it.Request.URL, it.Request.Body)
+		p.queues[key] = append(p.queues[key], it)
+	}
+	return p
+}
+
+//
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	key := matchKey(req.Method, req.URL.String(), body)
+
+	p.Lock()
+	var it *Interaction
+	if q := p.queues[key]; len(q) > 0 {
+		it = q[0]
+		p.queues[key] = q[1:]
+		p.last[key] = it
+	} else if p.Repeat {
+		it = p.last[key]
+	}
+	p.Unlock()
+
+	if it == nil {
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL)
+	}
+	if it.Response == nil {
+		return nil, errors.New(it.Error)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", it.Response.Status, http.StatusText(it.Response.Status)),
+		StatusCode:    it.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        it.Response.Header.Clone(),
+		Body:          ioutil.NopCloser(strings.NewReader(it.Response.Body)),
+		ContentLength: int64(len(it.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// 尚未回放的请求数量
+func (p *Player) Remaining() int {
+	p.Lock()
+	defer p.Unlock()
+	var n int
+	for _, q := range p.queues {
+		n += len(q)
+	}
+	return n
+}
@@ -0,0 +1,173 @@
+package fcoin
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 使用testdata中的录制文件回放请求的Client
+func replayClient(t *testing.T, name string) (*Client, *Player) {
+	c, err := LoadCassette(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := NewPlayer(c)
+	client := NewClient("key", "secret", 1000)
+	client.SetTransport(player)
+	return client, player
+}
+
+//
+func TestReplayTicker(t *testing.T) {
+	client, player := replayClient(t, "ticker.jsonl")
+
+	tk, err := client.GetTicker("btcusdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := ParseTicker(tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if q.Seq != 180225114 || q.Type != "ticker.btcusdt" {
+		t.Errorf("seq %d type %s", q.Seq, q.Type)
+	}
+	want := []float64{6478.87, 0.0107, 6478.86, 0.3861, 6478.87, 1.2237, 6453.4, 6510.0, 6421.19, 4536.8812, 29363924.29}
+	got := []float64{q.LastestPrice, q.LastestVOL, q.MaxBuyOnePrice, q.MaxBuyNumber, q.MinSellOnePrice, q.MinSellNumber,
+		q.TheDayBeforePrice, q.IntradayMaxPrice, q.IntradayMinPrice, q.IntradayBaseCurrencyVOL, q.IntradayQuoteCurrencyVOL}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ticker field %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if n := player.Remaining(); n != 0 {
+		t.Errorf("%d recorded requests not replayed", n)
+	}
+}
+
+// 下单、部分成交、撤单后订单状态为partial_canceled
+func TestReplayPartialFill(t *testing.T) {
+	client, player := replayClient(t, "partial_fill.jsonl")
+
+	order, err := client.CreateOrder("btcusdt", "buy", "limit", "6478.87", "0.5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != 0 || order.Data != "fdk3pVQ9Mn" {
+		t.Fatalf("create order = %+v", order)
+	}
+
+	detail, err := client.GetOrder(order.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := detail.Data; d.State != "partial_filled" || d.FilledAmount != "0.2000" || d.Amount != "0.5000" || d.Side != "buy" {
+		t.Errorf("order after partial fill = %+v", d)
+	}
+
+	cancel, err := client.CancelOrder(order.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancel.Status != 0 || !cancel.Data {
+		t.Errorf("cancel order = %+v", cancel)
+	}
+
+	detail, err = client.GetOrder(order.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := detail.Data; d.State != "partial_canceled" || d.FilledAmount != "0.2000" {
+		t.Errorf("order after cancel = %+v", d)
+	}
+
+	if n := player.Remaining(); n != 0 {
+		t.Errorf("%d recorded requests not replayed", n)
+	}
+	if _, err := client.GetOrder(order.Data); err == nil {
+		t.Errorf("request beyond the cassette succeeded")
+	}
+}
+
+// 交易所返回错误状态、格式错误的行情和请求超时
+func TestReplayErrorStatus(t *testing.T) {
+	client, _ := replayClient(t, "error_status.jsonl")
+
+	tk, err := client.GetTicker("nosuchpair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseTicker(tk); err == nil || !strings.Contains(err.Error(), "40003") {
+		t.Errorf("invalid symbol ticker: err = %v", err)
+	}
+
+	for _, symbol := range []string{"ftusdt", "xrpusdt"} {
+		tk, err := client.GetTicker(symbol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseTicker(tk); err == nil || !strings.Contains(err.Error(), "format wrong") {
+			t.Errorf("%s malformed ticker: err = %v", symbol, err)
+		}
+	}
+
+	order, err := client.CreateOrder("btcusdt", "sell", "limit", "6500.00", "100.0000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != 1016 || order.Data != "" {
+		t.Errorf("insufficient balance order = %+v", order)
+	}
+
+	cancel, err := client.CancelOrder("fdk3pVQ9Mn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancel.Status != 3008 || cancel.Data || cancel.Msg == "" {
+		t.Errorf("invalid state cancel = %+v", cancel)
+	}
+
+	if _, err := client.GetBalance(); err == nil || !strings.Contains(err.Error(), "Client.Timeout") {
+		t.Errorf("balance timeout: err = %v", err)
+	}
+}
+
+// 录制的请求隐藏密钥和签名，回放录制的文件得到相同的结果
+func TestRecordReplay(t *testing.T) {
+	source, _ := replayClient(t, "ticker.jsonl")
+
+	path := filepath.Join(t.TempDir(), "record.jsonl")
+	rec, err := RecordTo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient("key", "secret", 1000)
+	client.SetTransport(rec.Wrap(source.Transport()))
+	if _, err := client.GetTicker("btcusdt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTicker("btcusdt"); err == nil {
+		t.Errorf("second request beyond source cassette succeeded")
+	}
+	rec.Close()
+
+	c, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 2 || c.Interactions[0].Response == nil || c.Interactions[1].Error == "" {
+		t.Fatalf("recorded %d interactions", len(c.Interactions))
+	}
+
+	replayed := NewClient("key", "secret", 1000)
+	replayed.SetTransport(NewPlayer(c))
+	tk, err := replayed.GetTicker("btcusdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q, err := ParseTicker(tk); err != nil || q.Seq != 180225114 {
+		t.Errorf("replayed recording: %+v, %v", q, err)
+	}
+}
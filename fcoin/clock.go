@@ -0,0 +1,104 @@
+package fcoin
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// 所有Client默认共用的时钟
+	DefaultClock = NewClock()
+)
+
+// 与交易所服务器同步的时钟，用于请求签名和计算订单的存在时间。
+// offset为服务器时间减去本地时间，在请求往返时间的中点估算
+type Clock struct {
+	offset   int64
+	rtt      int64
+	syncedAt int64
+}
+
+//
+func NewClock() *Clock {
+	return new(Clock)
+}
+
+// 校正后的当前时间，单位毫秒
+func (p *Clock) Millis() int64 {
+	return time.Now().UnixNano()/1000000 + atomic.LoadInt64(&p.offset)
+}
+
+// 校正后的当前时间
+func (p *Clock) Now() time.Time {
+	return time.Now().Add(p.Offset())
+}
+
+// 服务器时间与本地时间的差
+func (p *Clock) Offset() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.offset)) * time.Millisecond
+}
+
+// 最后一次同步时的请求往返时间
+func (p *Clock) RTT() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.rtt)) * time.Millisecond
+}
+
+// 最后一次同步成功的时间，未同步过时第二个返回值为false
+func (p *Clock) SyncedAt() (time.Time, bool) {
+	ms := atomic.LoadInt64(&p.syncedAt)
+	if ms == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ms*1000000), true
+}
+
+// 采样samples次fcoin服务器时间，使用往返时间最短的一次估算时间差
+func (p *Clock) Sync(c *Client, samples int) error {
+	return p.SyncWith(func() (int64, error) {
+		st, err := c.GetServerTime()
+		if err != nil {
+			return 0, err
+		}
+		if st.Status != 0 {
+			return 0, fmt.Errorf("get server time but return status is %d", st.Status)
+		}
+		return st.Data, nil
+	}, samples)
+}
+
+// 使用serverTime获取服务器的毫秒时间戳，用于其他交易所的时钟同步
+func (p *Clock) SyncWith(serverTime func() (int64, error), samples int) error {
+	var (
+		found   bool
+		bestRTT int64
+		offset  int64
+		lastErr error
+	)
+
+	for i := 0; i < samples; i++ {
+		t0 := time.Now().UnixNano() / 1000000
+		st, err := serverTime()
+		t1 := time.Now().UnixNano() / 1000000
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		rtt := t1 - t0
+		if !found || rtt < bestRTT {
+			found = true
+			bestRTT = rtt
+			offset = st - (t0+t1)/2
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("sync clock failed. %s", lastErr)
+	}
+
+	atomic.StoreInt64(&p.offset, offset)
+	atomic.StoreInt64(&p.rtt, bestRTT)
+	atomic.StoreInt64(&p.syncedAt, time.Now().UnixNano()/1000000)
+	return nil
+}
@@ -28,6 +28,7 @@ var (
 	symbolUrl     = fmt.Sprintf("%s/%s", publicUrl, "symbols")
 
 	tickerUrl  = fmt.Sprintf("%s/%s", marketUrl, "ticker")
+	depthUrl   = fmt.Sprintf("%s/%s", marketUrl, "depth")
 	balanceUrl = fmt.Sprintf("%s/%s", accountUrl, "balance")
 )
 
@@ -36,6 +37,7 @@ type Client struct {
 	appKey    string
 	appSecret []byte
 	timeout   int
+	clock     *Clock
 }
 
 func NewClient(key, secret string, to int) *Client {
@@ -50,9 +52,30 @@ func NewClient(key, secret string, to int) *Client {
 		appKey:    key,
 		appSecret: []byte(secret),
 		timeout:   to,
+		clock:     DefaultClock,
 	}
 }
 
+// 替换发送请求使用的RoundTripper，用于录制和回放请求
+func (p *Client) SetTransport(rt http.RoundTripper) {
+	p.client.Transport = rt
+}
+
+//
+func (p *Client) Transport() http.RoundTripper {
+	return p.client.Transport
+}
+
+// 设置用于签名的时钟
+func (p *Client) SetClock(c *Clock) {
+	p.clock = c
+}
+
+//
+func (p *Client) Clock() *Clock {
+	return p.clock
+}
+
 // signature msg
 func (p *Client) Signature(msg string) string {
 	bmsg := base64.StdEncoding.EncodeToString([]byte(msg))
@@ -192,6 +215,34 @@ func (p *Client) GetTicker(symbol string) (*model.Ticker, error) {
 	return tk, nil
 }
 
+// 获取深度，level为L20、L150或者full
+func (p *Client) GetDepth(symbol, level string) (*model.Depth, error) {
+	reqUrl := fmt.Sprintf("%s/%s/%s", depthUrl, level, symbol)
+	req, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var dp = new(model.Depth)
+	err = json.Unmarshal(data, dp)
+	if err != nil {
+		return nil, err
+	}
+
+	return dp, nil
+}
+
 func (p *Client) GetBalance() (*model.AccountBalance, error) {
 	reqMethod := "GET"
 	req, err := http.NewRequest(reqMethod, balanceUrl, nil)
@@ -199,7 +250,7 @@ func (p *Client) GetBalance() (*model.AccountBalance, error) {
 		return nil, err
 	}
 
-	timestamp := time.Now().UnixNano() / 1000000
+	timestamp := p.clock.Millis()
 	req.Header.Add("FC-ACCESS-KEY", p.appKey)
 	req.Header.Add("FC-ACCESS-SIGNATURE", p.Signature(p.MakeSignatureMessage(reqMethod, balanceUrl, timestamp, nil)))
 	req.Header.Add("FC-ACCESS-TIMESTAMP", fmt.Sprintf("%d", timestamp))
@@ -234,7 +285,10 @@ func (p *Client) CreateOrder(symbol, side, otype, price, amount string) (*model.
 	params["symbol"] = symbol
 	params["side"] = side
 	params["type"] = otype
-	params["price"] = price
+	// 市价单没有价格
+	if price != "" {
+		params["price"] = price
+	}
 	params["amount"] = amount
 
 	postBody, err := json.Marshal(params)
@@ -247,7 +301,7 @@ func (p *Client) CreateOrder(symbol, side, otype, price, amount string) (*model.
 		return nil, err
 	}
 
-	timestamp := time.Now().UnixNano() / 1000000
+	timestamp := p.clock.Millis()
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("FC-ACCESS-KEY", p.appKey)
 	req.Header.Add("FC-ACCESS-SIGNATURE", p.Signature(p.MakeSignatureMessage(reqMethod, orderUrl, timestamp, params)))
@@ -285,7 +339,7 @@ func (p *Client) CancelOrder(id string) (*model.CancelOrder, error) {
 		return nil, err
 	}
 
-	timestamp := time.Now().UnixNano() / 1000000
+	timestamp := p.clock.Millis()
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("FC-ACCESS-KEY", p.appKey)
 	req.Header.Add("FC-ACCESS-SIGNATURE", p.Signature(p.MakeSignatureMessage(reqMethod, reqUrl, timestamp, nil)))
@@ -311,6 +365,42 @@ func (p *Client) CancelOrder(id string) (*model.CancelOrder, error) {
 	return order, nil
 }
 
+// 查询订单
+func (p *Client) GetOrder(id string) (*model.OrderDetail, error) {
+	var (
+		reqMethod string = "GET"
+		reqUrl    string = fmt.Sprintf("%s/%s", orderUrl, id)
+	)
+	req, err := http.NewRequest(reqMethod, reqUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := p.clock.Millis()
+	req.Header.Add("FC-ACCESS-KEY", p.appKey)
+	req.Header.Add("FC-ACCESS-SIGNATURE", p.Signature(p.MakeSignatureMessage(reqMethod, reqUrl, timestamp, nil)))
+	req.Header.Add("FC-ACCESS-TIMESTAMP", fmt.Sprintf("%d", timestamp))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var order = new(model.OrderDetail)
+	err = json.Unmarshal(data, order)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
 func (p *Client) ListOrders(querys map[string]string) (*model.OrderList, error) {
 
 	var (
@@ -324,7 +414,7 @@ func (p *Client) ListOrders(querys map[string]string) (*model.OrderList, error)
 		return nil, err
 	}
 
-	timestamp := time.Now().UnixNano() / 1000000
+	timestamp := p.clock.Millis()
 	req.Header.Add("FC-ACCESS-KEY", p.appKey)
 	req.Header.Add("FC-ACCESS-SIGNATURE", p.Signature(p.MakeSignatureMessage(reqMethod, reqUrl, timestamp, nil)))
 	req.Header.Add("FC-ACCESS-TIMESTAMP", fmt.Sprintf("%d", timestamp))
@@ -351,10 +441,10 @@ func (p *Client) ListOrders(querys map[string]string) (*model.OrderList, error)
 
 func ParseTicker(tk *model.Ticker) (*model.Quote, error) {
 	if tk.Status != 0 {
-		return nil, fmt.Errorf("status is not 0", tk.Status)
+		return nil, fmt.Errorf("status is not 0, %d", tk.Status)
 	}
 
-	if len(tk.Data.Tickers) != 11 {
+	if tk.Data == nil || len(tk.Data.Tickers) != 11 {
 		return nil, fmt.Errorf("ticker format wrong. expected data ticker length is 11")
 	}
 
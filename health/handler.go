@@ -0,0 +1,51 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// 健康检查的返回内容
+type report struct {
+	Status string            `json:"status"`
+	Errors map[string]string `json:"errors,omitempty"`
+	Loops  []*LoopStatus     `json:"loops,omitempty"`
+}
+
+//
+func writeReport(w http.ResponseWriter, errs map[string]error, loops []*LoopStatus) {
+	var (
+		rp   = &report{Status: "ok", Loops: loops}
+		code = http.StatusOK
+	)
+	if len(errs) > 0 {
+		rp.Status = "fail"
+		rp.Errors = make(map[string]string)
+		for k, v := range errs {
+			rp.Errors[k] = v.Error()
+		}
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(rp)
+}
+
+// /healthz，进程存活并且任务没有反复重启时返回200，否则返回503
+func (p *Watchdog) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var errs = make(map[string]error)
+		if err := p.Live(); err != nil {
+			errs["watchdog"] = err
+		}
+		writeReport(w, errs, nil)
+	})
+}
+
+// /readyz，所有任务正常并且交易所接口可以访问时返回200，否则返回503
+func (p *Watchdog) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, p.Ready(), p.Loops())
+	})
+}
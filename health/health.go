@@ -0,0 +1,285 @@
+package health
+
+import (
+	"context"
+	"fcoinExchange/log"
+	"fcoinExchange/metrics"
+	"fcoinExchange/notify"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// 默认的检查间隔
+	defaultInterval = 5 * time.Second
+	// 统计重启次数的时间窗口
+	restartWindow = time.Hour
+)
+
+// 任务的一次运行。任务重启后旧的运行不再影响心跳，旧的goroutine在Done关闭后应尽快退出
+type Run struct {
+	ctx  context.Context
+	loop *Loop
+}
+
+// 任务完成一次循环或者仍在正常处理时调用
+func (p *Run) Beat() {
+	p.loop.beat(p, false)
+}
+
+// 任务开始等待外部事件时调用，等待期间不判断为卡住，下次Beat时恢复判断
+func (p *Run) Idle() {
+	p.loop.beat(p, true)
+}
+
+// 任务被重启或者停止时关闭
+func (p *Run) Done() <-chan struct{} {
+	return p.ctx.Done()
+}
+
+//
+func (p *Run) Context() context.Context {
+	return p.ctx
+}
+
+// 注册到watchdog的任务
+type Loop struct {
+	Name    string
+	timeout func() time.Duration
+	fn      func(r *Run)
+
+	run      *Run
+	cancel   context.CancelFunc
+	last     time.Time
+	idle     bool
+	beaten   bool
+	restarts []time.Time
+	sync.Mutex
+}
+
+//
+func (p *Loop) beat(r *Run, idle bool) {
+	p.Lock()
+	defer p.Unlock()
+	if r != p.run {
+		return
+	}
+	p.last = time.Now()
+	p.idle = idle
+	p.beaten = true
+}
+
+// 启动任务的新一次运行，旧的运行被取消
+func (p *Loop) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Run{ctx: ctx, loop: p}
+
+	p.Lock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.run = r
+	p.cancel = cancel
+	p.last = time.Now()
+	p.idle = false
+	p.beaten = false
+	p.Unlock()
+
+	go func() {
+		p.fn(r)
+		// 任务正常结束后不再判断为卡住
+		p.beat(r, true)
+	}()
+}
+
+// 任务的状态
+type LoopStatus struct {
+	Name string `json:"name"`
+	// 距离最后一次心跳的时间
+	Age      string `json:"age"`
+	Idle     bool   `json:"idle"`
+	Started  bool   `json:"started"`
+	Stalled  bool   `json:"stalled"`
+	Restarts int    `json:"restarts"`
+}
+
+//
+func (p *Loop) status(now time.Time) *LoopStatus {
+	p.Lock()
+	defer p.Unlock()
+
+	var restarts = p.restarts[:0]
+	for _, t := range p.restarts {
+		if now.Sub(t) < restartWindow {
+			restarts = append(restarts, t)
+		}
+	}
+	p.restarts = restarts
+
+	age := now.Sub(p.last)
+	return &LoopStatus{
+		Name:     p.Name,
+		Age:      age.Truncate(time.Millisecond).String(),
+		Idle:     p.idle,
+		Started:  p.beaten,
+		Stalled:  !p.idle && age > p.timeout(),
+		Restarts: len(p.restarts),
+	}
+}
+
+// 就绪检查，返回错误表示未就绪
+type Check func() error
+
+// 检查所有任务的心跳，重启卡住的任务并告警
+type Watchdog struct {
+	loops  map[string]*Loop
+	checks map[string]Check
+
+	interval    time.Duration
+	maxRestarts int
+	sync.RWMutex
+}
+
+// maxRestarts为一小时内单个任务最多重启的次数，超过后健康检查失败，设置为0则不限制
+func NewWatchdog(interval time.Duration, maxRestarts int) *Watchdog {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	return &Watchdog{
+		loops:       make(map[string]*Loop),
+		checks:      make(map[string]Check),
+		interval:    interval,
+		maxRestarts: maxRestarts,
+	}
+}
+
+// 启动任务并开始监控，超过timeout没有心跳并且不在等待状态时判断为卡住
+func (p *Watchdog) Go(name string, timeout func() time.Duration, fn func(r *Run)) {
+	l := &Loop{
+		Name:     name,
+		timeout:  timeout,
+		fn:       fn,
+		restarts: make([]time.Time, 0),
+	}
+
+	p.Lock()
+	if old, ok := p.loops[name]; ok {
+		old.Lock()
+		if old.cancel != nil {
+			old.cancel()
+		}
+		old.Unlock()
+	}
+	p.loops[name] = l
+	p.Unlock()
+
+	l.start()
+}
+
+// 添加就绪检查
+func (p *Watchdog) AddCheck(name string, c Check) {
+	p.Lock()
+	defer p.Unlock()
+	p.checks[name] = c
+}
+
+//
+func (p *Watchdog) list() []*Loop {
+	p.RLock()
+	defer p.RUnlock()
+	var list = make([]*Loop, 0, len(p.loops))
+	for _, l := range p.loops {
+		list = append(list, l)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// 定时检查所有任务
+func (p *Watchdog) Run() {
+	log.Logger.Infof("start watchdog task")
+	tk := time.NewTicker(p.interval)
+	for {
+		<-tk.C
+		p.check()
+	}
+}
+
+//
+func (p *Watchdog) check() {
+	now := time.Now()
+	for _, l := range p.list() {
+		st := l.status(now)
+		if !st.Stalled {
+			continue
+		}
+
+		log.Logger.Errorf("task %s has no heartbeat for %s, restart it", l.Name, st.Age)
+		metrics.Inc("watchdog", "restart", l.Name)
+		notify.Send(&notify.Event{
+			Kind:     notify.KindStalledLoop,
+			Severity: notify.SeverityCritical,
+			Key:      l.Name,
+			Title:    fmt.Sprintf("task %s stalled, restarted", l.Name),
+			Message:  fmt.Sprintf("no heartbeat for %s, %d restarts in last hour", st.Age, st.Restarts+1),
+		})
+
+		l.Lock()
+		l.restarts = append(l.restarts, now)
+		l.Unlock()
+		l.start()
+	}
+}
+
+// 所有任务的状态
+func (p *Watchdog) Loops() []*LoopStatus {
+	now := time.Now()
+	var list = make([]*LoopStatus, 0)
+	for _, l := range p.list() {
+		list = append(list, l.status(now))
+	}
+	return list
+}
+
+// 存活检查，任务在一小时内重启次数过多时返回错误，需要由进程管理重启整个进程
+func (p *Watchdog) Live() error {
+	if p.maxRestarts <= 0 {
+		return nil
+	}
+	for _, st := range p.Loops() {
+		if st.Restarts > p.maxRestarts {
+			return fmt.Errorf("task %s restarted %d times in last hour", st.Name, st.Restarts)
+		}
+	}
+	return nil
+}
+
+// 就绪检查，所有任务都有心跳并且没有卡住，所有就绪检查都通过
+func (p *Watchdog) Ready() map[string]error {
+	var errs = make(map[string]error)
+	for _, st := range p.Loops() {
+		if !st.Started {
+			errs[st.Name] = fmt.Errorf("task has not reported heartbeat yet")
+		} else if st.Stalled {
+			errs[st.Name] = fmt.Errorf("task has no heartbeat for %s", st.Age)
+		}
+	}
+
+	p.RLock()
+	var checks = make(map[string]Check, len(p.checks))
+	for k, v := range p.checks {
+		checks[k] = v
+	}
+	p.RUnlock()
+
+	for k, c := range checks {
+		if err := c(); err != nil {
+			errs[k] = err
+		}
+	}
+	return errs
+}
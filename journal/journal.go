@@ -0,0 +1,396 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fcoinExchange/log"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 订单状态
+const (
+	// 即将提交，提交结果未知
+	StatePending string = "pending"
+	// 交易所已经接受，有订单号
+	StateSubmitted string = "submitted"
+	// 交易所明确拒绝或者确认订单不存在
+	StateFailed string = "failed"
+	// 提交结果不确定，并且无法确认订单是否存在，需要对账
+	StateUnknown string = "unknown"
+	// 订单已经结束
+	StateClosed string = "closed"
+)
+
+var (
+	// 打开时删除结束超过此时间的订单，最近结束的订单保留用于排除结果不确定时误匹配的订单
+	compactAge = time.Hour
+
+	journals = make(map[string]*Journal)
+	mu       sync.Mutex
+)
+
+// 一条订单记录，同一个客户端订单号的多条记录中最后一条为当前状态
+type Entry struct {
+	ClientOrderId string    `json:"client_order_id"`
+	OrderId       string    `json:"order_id,omitempty"`
+	Account       string    `json:"account"`
+	Symbol        string    `json:"symbol"`
+	Side          string    `json:"side"`
+	Type          string    `json:"type"`
+	Price         string    `json:"price,omitempty"`
+	Amount        string    `json:"amount"`
+	TimeInForce   string    `json:"time_in_force,omitempty"`
+	PostOnly      bool      `json:"post_only,omitempty"`
+	Strategy      string    `json:"strategy,omitempty"`
+	State         string    `json:"state"`
+	Error         string    `json:"error,omitempty"`
+	SubmittedAt   time.Time `json:"submitted_at"`
+	Time          time.Time `json:"time"`
+}
+
+// 本地订单日志。
+// 每次提交订单前记录客户端订单号，提交结果确定后追加新的状态，进程重启后从文件恢复，
+// 用于判断超时的订单是否已经提交以及对账
+type Journal struct {
+	path string
+	file *os.File
+
+	sync.Mutex
+	entries map[string]*Entry
+	// 订单号 -> 客户端订单号
+	orders map[string]string
+}
+
+// 打开订单日志，同一个文件只打开一次，多个账户共用。path为空时返回nil，nil的Journal不记录任何内容。
+// 打开时重写文件，每个客户端订单号只保留当前状态，并删除已经结束的订单，避免文件无限增长
+func Open(path string) (*Journal, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if j, ok := journals[path]; ok {
+		return j, nil
+	}
+
+	j := &Journal{
+		path:    path,
+		entries: make(map[string]*Entry),
+		orders:  make(map[string]string),
+	}
+	records, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	err = j.compact(records, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("compact journal %s failed. %s", path, err)
+	}
+
+	j.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	journals[path] = j
+	return j, nil
+}
+
+// 读取已有的记录，文件不存在时为空。
+// 进程崩溃时最后一行可能只写入了一部分，无法解析的最后一行记录警告后从文件中截掉，
+// 避免之后追加的记录接在这一行后面；中间的行无法解析时说明文件已经损坏，返回错误
+func (p *Journal) load() (int, error) {
+	f, err := os.Open(p.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var (
+		r       = bufio.NewReader(f)
+		line    int
+		records int
+		size    int64
+		// 最后一条有效记录结束的位置，以及该记录是否以换行结束
+		valid   int64
+		newline = true
+		// 无法解析的行，之后还有记录时返回错误
+		torn     error
+		tornLine int
+	)
+	for {
+		data, rerr := r.ReadBytes('\n')
+		if len(data) > 0 {
+			line++
+			size += int64(len(data))
+			if b := bytes.TrimSpace(data); len(b) > 0 {
+				if torn != nil {
+					return 0, fmt.Errorf("journal %s line %d is invalid. %s", p.path, tornLine, torn)
+				}
+				var e Entry
+				err = json.Unmarshal(b, &e)
+				if err != nil {
+					torn, tornLine = err, line
+				} else {
+					p.index(&e)
+					records++
+					valid = size
+					newline = data[len(data)-1] == '\n'
+				}
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return 0, rerr
+		}
+	}
+
+	if torn != nil {
+		log.Named("journal").Warnw("journal last line is incomplete, truncated",
+			"path", p.path, "line", tornLine, "error", torn)
+		err = os.Truncate(p.path, valid)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if !newline {
+		// 最后一条记录完整但缺少换行
+		return records, p.appendRaw([]byte("\n"))
+	}
+	return records, nil
+}
+
+// 订单是否已经结束，不再需要对账
+func terminal(state string) bool {
+	return state == StateClosed || state == StateFailed
+}
+
+// 删除now之前compactAge已经结束的订单，每个客户端订单号只写入最后一条记录。
+// 先写入临时文件再替换，失败时原文件不变；records为文件中的记录数，没有可以删除的记录时不重写
+func (p *Journal) compact(records int, now time.Time) error {
+	var list = make([]*Entry, 0, len(p.entries))
+	for id, e := range p.entries {
+		if terminal(e.State) && now.Sub(e.Time) >= compactAge {
+			delete(p.entries, id)
+			if e.OrderId != "" {
+				delete(p.orders, e.OrderId)
+			}
+			continue
+		}
+		list = append(list, e)
+	}
+	if len(list) == records {
+		return nil
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].SubmittedAt.Equal(list[j].SubmittedAt) {
+			return list[i].ClientOrderId < list[j].ClientOrderId
+		}
+		return list[i].SubmittedAt.Before(list[j].SubmittedAt)
+	})
+
+	tmp := p.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	w := bufio.NewWriter(f)
+	for _, e := range list {
+		data, err := json.Marshal(e)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, p.path)
+	if err != nil {
+		return err
+	}
+	log.Named("journal").Infow("journal compacted", "path", p.path, "records", records, "kept", len(list))
+	return nil
+}
+
+// 打开文件前补写内容
+func (p *Journal) appendRaw(data []byte) error {
+	f, err := os.OpenFile(p.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	if err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// 需持有锁
+func (p *Journal) index(e *Entry) {
+	p.entries[e.ClientOrderId] = e
+	if e.OrderId != "" {
+		p.orders[e.OrderId] = e.ClientOrderId
+	}
+}
+
+// 追加一条记录并写入磁盘
+func (p *Journal) Append(e *Entry) error {
+	if p == nil {
+		return nil
+	}
+	e.Time = time.Now()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	_, err = p.file.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+	err = p.file.Sync()
+	if err != nil {
+		return err
+	}
+	c := *e
+	p.index(&c)
+	return nil
+}
+
+// 更新客户端订单号的状态和订单号，orderId为空时保留原订单号
+func (p *Journal) Update(clientId, orderId, state string, cause error) error {
+	if p == nil {
+		return nil
+	}
+	p.Lock()
+	e, ok := p.entries[clientId]
+	p.Unlock()
+	if !ok {
+		return fmt.Errorf("client order id %s not in journal", clientId)
+	}
+	if e.State == state && (orderId == "" || orderId == e.OrderId) && cause == nil {
+		return nil
+	}
+
+	c := *e
+	c.State = state
+	c.Error = ""
+	if orderId != "" {
+		c.OrderId = orderId
+	}
+	if cause != nil {
+		c.Error = cause.Error()
+	}
+	return p.Append(&c)
+}
+
+// 按订单号更新状态，订单不在日志中时忽略
+func (p *Journal) UpdateOrder(orderId, state string) error {
+	if p == nil {
+		return nil
+	}
+	p.Lock()
+	clientId, ok := p.orders[orderId]
+	p.Unlock()
+	if !ok {
+		return nil
+	}
+	return p.Update(clientId, orderId, state, nil)
+}
+
+//
+func (p *Journal) Get(clientId string) (*Entry, bool) {
+	if p == nil {
+		return nil, false
+	}
+	p.Lock()
+	defer p.Unlock()
+	e, ok := p.entries[clientId]
+	if !ok {
+		return nil, false
+	}
+	c := *e
+	return &c, true
+}
+
+// 按订单号查询
+func (p *Journal) Order(orderId string) (*Entry, bool) {
+	if p == nil {
+		return nil, false
+	}
+	p.Lock()
+	clientId, ok := p.orders[orderId]
+	p.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return p.Get(clientId)
+}
+
+// 账户在交易对上指定状态的记录，按提交时间排列，symbol为空时返回所有交易对
+func (p *Journal) List(account, symbol string, states ...string) []*Entry {
+	if p == nil {
+		return nil
+	}
+	var want = make(map[string]bool)
+	for _, v := range states {
+		want[v] = true
+	}
+
+	p.Lock()
+	var list = make([]*Entry, 0)
+	for _, e := range p.entries {
+		if e.Account != account || (symbol != "" && e.Symbol != symbol) {
+			continue
+		}
+		if len(want) > 0 && !want[e.State] {
+			continue
+		}
+		c := *e
+		list = append(list, &c)
+	}
+	p.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].SubmittedAt.Before(list[j].SubmittedAt)
+	})
+	return list
+}
+
+// 生成客户端订单号，只包含字母和数字，不超过32个字符
+func NewClientOrderId() string {
+	var b = make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "c" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return "c" + hex.EncodeToString(b)
+}
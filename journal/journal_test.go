@@ -0,0 +1,153 @@
+package journal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//
+func writeJournal(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "orders.jsonl")
+	err := ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 崩溃时写了一半的最后一行被截掉，之后追加的记录可以正常读取
+func TestOpenTornFinalLine(t *testing.T) {
+	path := writeJournal(t, `{"client_order_id":"c0","account":"a","state":"pending"}
+{"client_order_id":"c1","order_id":"1","account":"a","state":"submitted"}
+{"client_order_id":"c2","account":"a","sta`)
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := j.Get("c1"); !ok || e.State != StateSubmitted || e.OrderId != "1" {
+		t.Errorf("c1 = %+v", e)
+	}
+	if _, ok := j.Get("c2"); ok {
+		t.Errorf("torn entry c2 loaded")
+	}
+
+	err = j.Append(&Entry{ClientOrderId: "c3", Account: "a", State: StatePending})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(data), `"sta{`) || strings.Count(string(data), "\n") != 3 {
+		t.Errorf("journal after append:\n%s", data)
+	}
+
+	// 重新读取截断后的文件
+	mu.Lock()
+	delete(journals, path)
+	mu.Unlock()
+	j, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := j.Get("c3"); !ok {
+		t.Errorf("entry appended after truncation not loaded")
+	}
+}
+
+// 最后一条记录完整但缺少换行时补写换行
+func TestOpenMissingFinalNewline(t *testing.T) {
+	path := writeJournal(t, `{"client_order_id":"c1","account":"a","state":"pending"}`)
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = j.Append(&Entry{ClientOrderId: "c2", Account: "a", State: StatePending})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	delete(journals, path)
+	mu.Unlock()
+	j, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"c1", "c2"} {
+		if _, ok := j.Get(id); !ok {
+			t.Errorf("%s not loaded", id)
+		}
+	}
+}
+
+// 中间的行损坏时返回错误，不截断文件
+func TestOpenCorruptMiddleLine(t *testing.T) {
+	content := `{"client_order_id":"c1","account":"a","state":"pending"}
+not json
+
+{"client_order_id":"c2","account":"a","state":"pending"}
+`
+	path := writeJournal(t, content)
+
+	_, err := Open(path)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("err = %v, want line 2 invalid", err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	if string(data) != content {
+		t.Errorf("corrupt journal was modified")
+	}
+}
+
+// 打开时删除结束超过compactAge的订单，每个客户端订单号只保留当前状态
+func TestOpenCompacts(t *testing.T) {
+	var (
+		old    = time.Now().Add(-2 * compactAge).Format(time.RFC3339Nano)
+		recent = time.Now().Add(-time.Minute).Format(time.RFC3339Nano)
+		lines  = []string{
+			`{"client_order_id":"closed","account":"a","state":"pending","time":"%[1]s"}`,
+			`{"client_order_id":"closed","order_id":"1","account":"a","state":"submitted","time":"%[1]s"}`,
+			`{"client_order_id":"closed","order_id":"1","account":"a","state":"closed","time":"%[1]s"}`,
+			`{"client_order_id":"failed","account":"a","state":"failed","time":"%[1]s"}`,
+			`{"client_order_id":"recent","order_id":"2","account":"a","state":"closed","time":"%[2]s"}`,
+			`{"client_order_id":"open","account":"a","state":"pending","time":"%[1]s"}`,
+			`{"client_order_id":"open","order_id":"3","account":"a","state":"submitted","time":"%[1]s"}`,
+			`{"client_order_id":"unknown","account":"a","state":"unknown","time":"%[1]s"}`,
+		}
+	)
+	path := writeJournal(t, fmt.Sprintf(strings.Join(lines, "\n")+"\n", old, recent))
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"closed", "failed"} {
+		if _, ok := j.Get(id); ok {
+			t.Errorf("%s entry kept", id)
+		}
+	}
+	if _, ok := j.Order("1"); ok {
+		t.Errorf("order of compacted entry still indexed")
+	}
+	for _, id := range []string{"recent", "open", "unknown"} {
+		if _, ok := j.Get(id); !ok {
+			t.Errorf("%s entry dropped", id)
+		}
+	}
+	if e, ok := j.Order("3"); !ok || e.State != StateSubmitted {
+		t.Errorf("open order = %+v", e)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	if n := strings.Count(string(data), "\n"); n != 3 {
+		t.Errorf("compacted journal has %d lines, want 3:\n%s", n, data)
+	}
+	if _, err := ioutil.ReadFile(path + ".tmp"); err == nil {
+		t.Errorf("temporary file left behind")
+	}
+}
@@ -0,0 +1,40 @@
+package log
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 交易日志中使用的字段名
+const (
+	FieldAccount   string = "account"
+	FieldSymbol    string = "symbol"
+	FieldOrderId   string = "order_id"
+	FieldSide      string = "side"
+	FieldPrice     string = "price"
+	FieldAmount    string = "amount"
+	FieldStrategy  string = "strategy"
+	FieldRequestId string = "request_id"
+)
+
+var (
+	requestPrefix = newRequestPrefix()
+	requestSeq    uint64
+)
+
+// 进程启动时生成的随机前缀，避免重启后request id重复
+func newRequestPrefix() string {
+	var b = make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().Unix(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// 生成关联同一次下单或撤单请求的所有日志的request id
+func NewRequestId() string {
+	return requestPrefix + "-" + strconv.FormatUint(atomic.AddUint64(&requestSeq, 1), 10)
+}
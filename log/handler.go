@@ -0,0 +1,54 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// 日志级别查询和修改的请求和返回内容
+type levelPayload struct {
+	Module string            `json:"module,omitempty"`
+	Level  string            `json:"level"`
+	Levels map[string]string `json:"modules,omitempty"`
+}
+
+// 返回修改日志级别的http接口。
+// GET返回默认级别和各模块的级别，PUT或POST {"module": "exchange", "level": "debug"}修改级别，
+// module为空时修改默认级别。修改在下次配置文件中的日志级别变化前有效
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var req levelPayload
+			err := json.NewDecoder(r.Body).Decode(&req)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			level, err := ParseLevel(req.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logger.setLevel(req.Module, level)
+			Logger.Infof("log level of module %q changed to %s by control api", req.Module, level)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var resp = levelPayload{
+			Level:  logger.level.String(),
+			Levels: make(map[string]string),
+		}
+		logger.Lock()
+		for k, l := range logger.modules {
+			resp.Levels[k] = l.String()
+		}
+		logger.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
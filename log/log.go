@@ -2,46 +2,246 @@ package log
 
 import (
 	"fcoinExchange/conf"
+	"fcoinExchange/model"
 	"fmt"
+	"os"
+	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var (
 	Logger *zap.SugaredLogger
+
+	logger *logging
 )
 
+// 日志输出和各模块的日志级别。
+// 所有模块共用同一个输出，每个模块使用单独的级别，未单独配置级别的模块使用log_level
+type logging struct {
+	writer *lumberjack.Logger
+	core   zapcore.Core
+
+	level   zap.AtomicLevel
+	modules map[string]zap.AtomicLevel
+	// 单独配置了级别的模块
+	overrides map[string]string
+	sync.Mutex
+}
+
 func Init() {
+	c := conf.GetConfiguration()
+
+	var err error
+	logger, err = newLogging(c)
+	if err != nil {
+		fmt.Printf("build log failed. %s\n", err)
+		os.Exit(1)
+	}
+	Logger = Named("main")
+
+	go logger.watch()
+}
+
+//
+func newLogging(c *model.Configuration) (*logging, error) {
 	var (
-		cfg    zap.Config
-		logger *zap.Logger
+		p = &logging{
+			level:     zap.NewAtomicLevel(),
+			modules:   make(map[string]zap.AtomicLevel),
+			overrides: make(map[string]string),
+		}
+		syncers = []zapcore.WriteSyncer{zapcore.Lock(os.Stdout)}
 	)
-	cfg = zap.NewProductionConfig()
-	cfg.OutputPaths = []string{conf.GetConfiguration().LogFile, "stdout"}
-	switch strings.ToUpper(conf.GetConfiguration().LogLevel) {
-	case "DEBUG":
-		cfg.Level.SetLevel(zap.DebugLevel)
-		break
-	case "INFO":
-		cfg.Level.SetLevel(zap.InfoLevel)
-		break
-	case "WARNNING":
-
-		cfg.Level.SetLevel(zap.WarnLevel)
-		break
-	case "ERROR":
-		cfg.Level.SetLevel(zap.ErrorLevel)
-		break
-	default:
-		cfg.Level.SetLevel(zap.ErrorLevel)
-		break
+
+	if c.LogFile != "" {
+		p.writer = &lumberjack.Logger{
+			Filename:   c.LogFile,
+			MaxSize:    c.LogMaxSize,
+			MaxBackups: c.LogMaxBackups,
+			MaxAge:     c.LogMaxAge,
+			Compress:   c.LogCompress,
+			LocalTime:  true,
+		}
+		syncers = append(syncers, zapcore.AddSync(p.writer))
+		go p.rotate(c.LogRotate)
 	}
 
-	var err error
-	logger, err = cfg.Build()
+	err := p.apply(c)
 	if err != nil {
-		fmt.Printf("build log failed. %s\n", err)
+		return nil, err
+	}
+
+	enc := zap.NewProductionEncoderConfig()
+	enc.EncodeTime = zapcore.ISO8601TimeEncoder
+	p.core = zapcore.NewCore(zapcore.NewJSONEncoder(enc), zapcore.NewMultiWriteSyncer(syncers...), zap.DebugLevel)
+	return p, nil
+}
+
+// 返回模块的日志，模块的级别可以单独配置，Init之前返回不输出的日志
+func Named(module string) *zap.SugaredLogger {
+	if logger == nil {
+		return zap.NewNop().Sugar()
+	}
+	level := logger.moduleLevel(module)
+	core := &levelCore{Core: logger.core, level: level}
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel)).Named(module).Sugar()
+}
+
+//
+func (p *logging) moduleLevel(module string) zap.AtomicLevel {
+	p.Lock()
+	defer p.Unlock()
+
+	if l, ok := p.modules[module]; ok {
+		return l
+	}
+
+	l := zap.NewAtomicLevelAt(p.level.Level())
+	if v, ok := p.overrides[module]; ok {
+		lv, _ := ParseLevel(v)
+		l.SetLevel(lv)
+	}
+	p.modules[module] = l
+	return l
+}
+
+// 按配置设置默认级别和各模块的级别
+func (p *logging) apply(c *model.Configuration) error {
+	level, err := ParseLevel(c.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	var overrides = make(map[string]string)
+	for k, v := range c.LogLevels {
+		_, err := ParseLevel(v)
+		if err != nil {
+			return fmt.Errorf("module %s: %s", k, err)
+		}
+		overrides[k] = v
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.level.SetLevel(level)
+	p.overrides = overrides
+	for k, l := range p.modules {
+		if v, ok := overrides[k]; ok {
+			lv, _ := ParseLevel(v)
+			l.SetLevel(lv)
+			continue
+		}
+		l.SetLevel(level)
+	}
+	return nil
+}
+
+// 设置模块的级别，module为空时设置默认级别和所有未单独配置级别的模块
+func (p *logging) setLevel(module string, level zapcore.Level) {
+	if module != "" {
+		p.moduleLevel(module).SetLevel(level)
+		p.Lock()
+		p.overrides[module] = level.String()
+		p.Unlock()
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	p.level.SetLevel(level)
+	for k, l := range p.modules {
+		if _, ok := p.overrides[k]; !ok {
+			l.SetLevel(level)
+		}
+	}
+}
+
+// 配置中的日志级别变化时立即生效，日志文件和切割方式需要重启后生效
+func (p *logging) watch() {
+	for c := range conf.Watch() {
+		if c.Old.LogLevel == c.New.LogLevel && reflect.DeepEqual(c.Old.LogLevels, c.New.LogLevels) {
+			continue
+		}
+
+		err := p.apply(c.New)
+		if err != nil {
+			Logger.Errorf("apply log level failed. %s", err)
+			continue
+		}
+		Logger.Infof("log level changed to %s, module levels %v", c.New.LogLevel, c.New.LogLevels)
+	}
+}
+
+// 按时间切割日志文件，按大小切割由lumberjack在写入时完成
+func (p *logging) rotate(mode string) {
+	var period time.Duration
+	switch mode {
+	case "hourly":
+		period = time.Hour
+	case "daily":
+		period = 24 * time.Hour
+	default:
+		return
+	}
+
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Hour).Add(time.Hour)
+		if period == 24*time.Hour {
+			y, m, d := now.Date()
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+		}
+
+		time.Sleep(next.Sub(now))
+		if err := p.writer.Rotate(); err != nil {
+			fmt.Printf("rotate log file failed. %s\n", err)
+		}
+	}
+}
+
+// 解析日志级别，兼容旧配置中的warnning
+func ParseLevel(s string) (zapcore.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return zap.DebugLevel, nil
+	case "", "info":
+		return zap.InfoLevel, nil
+	case "warn", "warning", "warnning":
+		return zap.WarnLevel, nil
+	case "error":
+		return zap.ErrorLevel, nil
+	default:
+		return zap.InfoLevel, fmt.Errorf("unknown log level %s", s)
+	}
+}
+
+// 按模块级别过滤的日志输出
+type levelCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+}
+
+//
+func (p *levelCore) Enabled(l zapcore.Level) bool {
+	return p.level.Enabled(l)
+}
+
+//
+func (p *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: p.Core.With(fields), level: p.level}
+}
+
+//
+func (p *levelCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if p.Enabled(e.Level) {
+		return ce.AddCore(e, p)
 	}
-	Logger = logger.Sugar()
+	return ce
 }
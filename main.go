@@ -2,30 +2,112 @@
 package main
 
 import (
+	"expvar"
+	"fcoinExchange/algo"
+	"fcoinExchange/arbitrage"
 	"fcoinExchange/conf"
+	"fcoinExchange/event"
 	"fcoinExchange/exchange"
+	"fcoinExchange/health"
 	"fcoinExchange/log"
+	"fcoinExchange/model"
+	"fcoinExchange/notify"
+	"net/http"
+	"time"
+)
+
+var (
+	// 事件总线保留的历史事件数量
+	eventHistory = 1000
 )
 
 func main() {
 	conf.Init()
 	log.Init()
+	notify.Init()
+	log.Logger.Infof("configuration: %s", conf.GetConfiguration())
 
-	ex, err := exchange.NewExchange(conf.GetConfiguration())
-	if err != nil {
-		log.Logger.Fatalf("create exchange failed. %s\n", err)
+	// 所有定时任务由watchdog监控，卡住时告警并重启
+	wc := conf.GetConfiguration().Watchdog
+	wd := health.NewWatchdog(time.Duration(wc.Interval)*time.Millisecond, wc.MaxRestarts)
+	go wd.Run()
+
+	// 所有账户共用的事件总线，配置变化也通过事件总线通知
+	bus := event.New(eventHistory)
+	go func() {
+		for c := range conf.Watch() {
+			bus.Publish(&event.ConfigChanged{Old: c.Old, New: c.New, Time: time.Now()})
+		}
+	}()
+
+	group := exchange.NewGroup()
+	for _, cfg := range conf.Accounts(conf.GetConfiguration()) {
+		ex, err := exchange.NewExchange(cfg, bus)
+		if err != nil {
+			notify.SendSync(&notify.Event{
+				Kind:     notify.KindCrash,
+				Severity: notify.SeverityCritical,
+				Account:  cfg.Name,
+				Title:    "create exchange failed",
+				Message:  err.Error(),
+			})
+			log.Logger.Fatalw("create exchange failed", log.FieldAccount, cfg.Name, "error", err)
+		}
+		group.Add(ex)
+
+		switch cfg.Mode {
+		case 0:
+			log.Logger.Infof("account %s start task mode", cfg.Name)
+			ex.AutoUpdate(wd)
+		case 1:
+			log.Logger.Infof("account %s start schedule mode", cfg.Name)
+			ex.Start(wd)
+		default:
+			log.Logger.Errorf("account %s mode need be 0 or 1", cfg.Name)
+		}
 	}
 
-	switch conf.GetConfiguration().Mode {
-	case 0:
-		log.Logger.Infof("start task mode")
-		ex.AutoUpdate()
-	case 1:
-		log.Logger.Infof("start schedule mode")
-		ex.Start()
-	default:
-		log.Logger.Errorf("mode need be 0 or 1")
+	// 定时同步各账户交易所的服务器时间，签名和计算订单存在时间都使用同步后的时间
+	wd.Go("sync_clock", exchange.StallTimeout(func(c *model.Configuration) int64 {
+		return c.ClockSyncInterval
+	}), group.AutoSyncClock)
+	wd.Go("report", exchange.StallTimeout(func(c *model.Configuration) int64 {
+		return c.ReportInterval
+	}), group.AutoReport)
+	wd.Go("daily_summary", exchange.StallTimeout(nil), group.AutoDailySummary)
+
+	// 跨交易所价差监控和单个交易所内的三角套利扫描
+	monitor := arbitrage.NewMonitor(bus)
+	wd.Go("arbitrage", exchange.StallTimeout(func(c *model.Configuration) int64 {
+		return c.Arbitrage.Interval
+	}), monitor.Run)
+	scanner := arbitrage.NewScanner(bus)
+	wd.Go("triangular", exchange.StallTimeout(func(c *model.Configuration) int64 {
+		return c.Arbitrage.Triangular.Interval
+	}), scanner.Run)
+
+	if addr := conf.GetConfiguration().ControlAddr; addr != "" {
+		go serveControl(addr, wd, group, monitor, scanner)
 	}
 
 	select {}
 }
+
+// 启动控制接口，用于健康检查、修改日志级别、查看运行指标和提交算法订单
+func serveControl(addr string, wd *health.Watchdog, group *exchange.Group, monitor *arbitrage.Monitor, scanner *arbitrage.Scanner) {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", wd.LiveHandler())
+	mux.Handle("/readyz", wd.ReadyHandler())
+	mux.Handle("/log/level", log.Handler())
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/arbitrage", monitor.Handler())
+	mux.Handle("/arbitrage/triangular", scanner.Handler())
+	mux.Handle("/algo", algo.Default.Handler(group.Executor))
+	mux.Handle("/algo/", algo.Default.Handler(group.Executor))
+
+	log.Logger.Infof("control api listen on %s", addr)
+	err := http.ListenAndServe(addr, mux)
+	if err != nil {
+		log.Logger.Errorf("control api stopped. %s", err)
+	}
+}
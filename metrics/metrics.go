@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"expvar"
+	"strings"
+)
+
+var (
+	counters = expvar.NewMap("fcoin")
+)
+
+// 计数器加delta，名称由多个部分以"."连接，例如 self_trade.blocked.ftusdt
+func Add(delta int64, name ...string) {
+	counters.Add(strings.Join(name, "."), delta)
+}
+
+//
+func Inc(name ...string) {
+	Add(1, name...)
+}
+
+// 返回计数器的当前值
+func Get(name ...string) int64 {
+	v, ok := counters.Get(strings.Join(name, ".")).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return v.Value()
+}
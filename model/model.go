@@ -1,5 +1,9 @@
 package model
 
+import (
+	"fmt"
+)
+
 const (
 	TaskMode int = iota
 	ScheduleMode
@@ -7,24 +11,343 @@ const (
 
 //
 type Configuration struct {
-	Mode                  int     `yaml:"mode"`
-	ReloadInterval        int     `yaml:"reload_interval"`
-	AppKey                string  `yaml:"appkey"`
-	AppSecret             string  `yaml:"appsecret"`
-	Symbol                string  `yaml:"symbol"`
-	SellNumber            float64 `yaml:"sell_number"`
-	MakeUpPercent         int     `yaml:"makeup_percent"`
-	BalancePercent        int     `yaml:"balance_percent"`
-	ExpectValue           float64 `yaml:"expect_value"`
-	AutoCheckOrder        bool    `yaml:"auto_check_order"`
-	CheckOrderInterval    int64   `yaml:"check_order_interval"`
-	RevokeOrderTime       int64   `yaml:"revoke_order_time"`
-	ShuaDanInterval       int64   `yaml:"shuadan_interval"`
-	UpdateAccountInterval int64   `yaml:"update_account_interval"`
-	UpdateTickerInterval  int64   `yaml:"update_ticker_interval"`
-	RequestTimeout        int     `yaml:"request_timeout"`
-	LogFile               string  `yaml:"log_file"`
-	LogLevel              string  `yaml:"log_level"`
+	Name                  string               `yaml:"name"`
+	Mode                  int                  `yaml:"mode"`
+	ReloadInterval        int                  `yaml:"reload_interval"`
+	WatchFile             bool                 `yaml:"watch_file"`
+	AppKey                string               `yaml:"appkey"`
+	AppSecret             string               `yaml:"appsecret"`
+	Credential            CredentialConfig     `yaml:"credential"`
+	Symbol                string               `yaml:"symbol"`
+	Symbols               []*SymbolConfig      `yaml:"symbols"`
+	SellNumber            float64              `yaml:"sell_number"`
+	MakeUpPercent         int                  `yaml:"makeup_percent"`
+	BalancePercent        int                  `yaml:"balance_percent"`
+	MakeUpAlgo            *AlgoConfig          `yaml:"makeup_algo"`
+	MakeUpOrderType       string               `yaml:"makeup_order_type"`
+	Rebalance             *RebalanceConfig     `yaml:"rebalance"`
+	Schedule              *ScheduleConfig      `yaml:"schedule"`
+	ExpectValue           float64              `yaml:"expect_value"`
+	AutoCheckOrder        bool                 `yaml:"auto_check_order"`
+	CheckOrderInterval    int64                `yaml:"check_order_interval"`
+	RevokeOrderTime       int64                `yaml:"revoke_order_time"`
+	OrderPolicies         []*OrderPolicyConfig `yaml:"order_policies"`
+	SelfTradePrevention   string               `yaml:"self_trade_prevention"`
+	ShuaDanInterval       int64                `yaml:"shuadan_interval"`
+	UpdateAccountInterval int64                `yaml:"update_account_interval"`
+	UpdateTickerInterval  int64                `yaml:"update_ticker_interval"`
+	RequestTimeout        int                  `yaml:"request_timeout"`
+	Venue                 string               `yaml:"venue"`
+	VenueURL              string               `yaml:"venue_url"`
+	LogFile               string               `yaml:"log_file"`
+	LogLevel              string               `yaml:"log_level"`
+	LogLevels             map[string]string    `yaml:"log_levels"`
+	LogMaxSize            int                  `yaml:"log_max_size"`
+	LogMaxBackups         int                  `yaml:"log_max_backups"`
+	LogMaxAge             int                  `yaml:"log_max_age"`
+	LogCompress           bool                 `yaml:"log_compress"`
+	LogRotate             string               `yaml:"log_rotate"`
+	ControlAddr           string               `yaml:"control_addr"`
+	RecordCassette        string               `yaml:"record_cassette"`
+	JournalFile           string               `yaml:"journal_file"`
+	Notify                NotifyConfig         `yaml:"notify"`
+	Watchdog              WatchdogConfig       `yaml:"watchdog"`
+	Reconcile             ReconcileConfig      `yaml:"reconcile"`
+	Arbitrage             ArbitrageConfig      `yaml:"arbitrage"`
+	Risk                  RiskLimits           `yaml:"risk"`
+	ReportInterval        int64                `yaml:"report_interval"`
+	ClockSyncInterval     int64                `yaml:"clock_sync_interval"`
+	MaxClockSkew          int64                `yaml:"max_clock_skew"`
+	Accounts              []*Account           `yaml:"accounts"`
+}
+
+// 返回隐藏了账户密钥的配置副本，用于打印日志或者导出配置
+func (p *Configuration) Redacted() *Configuration {
+	c := *p
+	c.AppKey = Redact(c.AppKey)
+	c.AppSecret = Redact(c.AppSecret)
+	c.Accounts = make([]*Account, 0, len(p.Accounts))
+	for _, v := range p.Accounts {
+		a := *v
+		a.AppKey = Redact(a.AppKey)
+		a.AppSecret = Redact(a.AppSecret)
+		c.Accounts = append(c.Accounts, &a)
+	}
+	// webhook地址中包含访问令牌
+	if p.Notify.Webhook != nil {
+		w := *p.Notify.Webhook
+		w.URL = Redact(w.URL)
+		c.Notify.Webhook = &w
+	}
+	if p.Notify.Slack != nil {
+		w := *p.Notify.Slack
+		w.URL = Redact(w.URL)
+		c.Notify.Slack = &w
+	}
+	return &c
+}
+
+// 隐藏敏感内容，不保留任何字符
+func Redact(s string) string {
+	if s == "" {
+		return s
+	}
+	return "******"
+}
+
+//
+func (p *Configuration) String() string {
+	return fmt.Sprintf("%+v", *p.Redacted())
+}
+
+// 账户密钥来源
+// source: config, env, file, keystore, command
+type CredentialConfig struct {
+	Source        string   `yaml:"source"`
+	KeyEnv        string   `yaml:"key_env"`
+	SecretEnv     string   `yaml:"secret_env"`
+	Path          string   `yaml:"path"`
+	PassphraseEnv string   `yaml:"passphrase_env"`
+	Command       []string `yaml:"command"`
+}
+
+// 账户配置，未设置的项使用顶层配置的值
+type Account struct {
+	Name       string           `yaml:"name"`
+	Mode       *int             `yaml:"mode"`
+	AppKey     string           `yaml:"appkey"`
+	AppSecret  string           `yaml:"appsecret"`
+	Credential CredentialConfig `yaml:"credential"`
+	Venue      string           `yaml:"venue"`
+	VenueURL   string           `yaml:"venue_url"`
+	Symbol     string           `yaml:"symbol"`
+	Symbols    []*SymbolConfig  `yaml:"symbols"`
+	// 以下数值项使用指针，设置为0时也会覆盖顶层配置
+	SellNumber     *float64         `yaml:"sell_number"`
+	MakeUpPercent  *int             `yaml:"makeup_percent"`
+	BalancePercent *int             `yaml:"balance_percent"`
+	ExpectValue    *float64         `yaml:"expect_value"`
+	Risk           *RiskLimits      `yaml:"risk"`
+	Rebalance      *RebalanceConfig `yaml:"rebalance"`
+	Schedule       *ScheduleConfig  `yaml:"schedule"`
+}
+
+// 交易对配置，未设置的项使用账户配置的值
+type SymbolConfig struct {
+	Symbol         string  `yaml:"symbol"`
+	SellNumber     float64 `yaml:"sell_number"`
+	MakeUpPercent  int     `yaml:"makeup_percent"`
+	BalancePercent int     `yaml:"balance_percent"`
+	ExpectValue    float64 `yaml:"expect_value"`
+	// 未设置时使用账户配置的order_policies
+	OrderPolicies []*OrderPolicyConfig `yaml:"order_policies"`
+	// 未设置时使用账户配置的rebalance
+	Rebalance *RebalanceConfig `yaml:"rebalance"`
+}
+
+// 库存再平衡配置，按中间价计算base currency价值占总价值的比例，
+// 超出target_ratio±band时买入或卖出base currency回到目标比例
+type RebalanceConfig struct {
+	// 目标比例，0 ~ 1，为0时全部持有quote currency，未设置时使用默认值
+	TargetRatio *float64 `yaml:"target_ratio"`
+	// 允许的偏差，0 ~ 1，为0时有偏差就再平衡，未设置时使用默认值
+	Band *float64 `yaml:"band"`
+	// 单次再平衡的最小和最大base currency数量
+	MinClip float64 `yaml:"min_clip"`
+	MaxClip float64 `yaml:"max_clip"`
+	// limit、market、ioc或者algo
+	OrderType string `yaml:"order_type"`
+	// 两次再平衡之间的最小间隔，单位毫秒
+	Cooldown int64 `yaml:"cooldown"`
+	// 每天最多再平衡的base currency数量和次数
+	DailyLimit  float64 `yaml:"daily_limit"`
+	DailyOrders int     `yaml:"daily_orders"`
+}
+
+// 订单处理策略，按顺序执行，第一个给出处理结果的策略生效
+// type:
+//
+//	timeout      订单存在时间超过after毫秒后撤单
+//	reprice      订单存在时间超过after毫秒并且价格不是最优价时，撤单后按最优价重新下单
+//	keep_partial 保留部分成交的订单
+//	price_move   最优价偏离订单价格超过ticks个最小价格单位时撤单
+type OrderPolicyConfig struct {
+	Type  string `yaml:"type"`
+	After int64  `yaml:"after"`
+	Ticks int    `yaml:"ticks"`
+}
+
+// 执行算法配置
+// type:
+//
+//	twap     在horizon内按时间平均分成slices个子订单
+//	vwap     按profile中每个时间段的成交量比例分配数量
+//	iceberg  每次只挂出display数量
+type AlgoConfig struct {
+	Type string `yaml:"type"`
+	// 执行时间，单位毫秒，超过后撤销剩余的子订单
+	Horizon int64     `yaml:"horizon"`
+	Slices  int       `yaml:"slices"`
+	Profile []float64 `yaml:"profile"`
+	Display float64   `yaml:"display"`
+	// 子订单超过此时间未完全成交时撤单重新下单，单位毫秒，为0时使用默认值
+	ChildTimeout int64 `yaml:"child_timeout"`
+	// 限价相对于开始时对手价的最大偏离百分比，为0时不限价
+	PriceLimit float64 `yaml:"price_limit"`
+}
+
+// 风险限制，值为0表示不限制
+type RiskLimits struct {
+	MaxOrderAmount float64 `yaml:"max_order_amount"`
+	MaxOrderValue  float64 `yaml:"max_order_value"`
+	MaxDailyOrders int     `yaml:"max_daily_orders"`
+}
+
+// 任务监控配置
+type WatchdogConfig struct {
+	// 检查任务心跳的时间间隔，单位毫秒
+	Interval int64 `yaml:"interval"`
+	// 任务超过此时间并且超过三个执行间隔没有心跳时判断为卡住并重启，单位毫秒
+	Timeout int64 `yaml:"timeout"`
+	// 单个任务一小时内最多重启的次数，超过后/healthz返回失败，设置为0则不限制
+	MaxRestarts int `yaml:"max_restarts"`
+}
+
+// 交易时间安排，未配置windows时全天交易，blackouts中的时间段不交易
+type ScheduleConfig struct {
+	// 时区，例如Asia/Shanghai，为空时使用本地时区
+	TimeZone string `yaml:"time_zone"`
+	// 交易时间段，配置后只在时间段内交易，多个时间段重叠时使用第一个时间段的参数
+	Windows []*WindowConfig `yaml:"windows"`
+	// 禁止交易的时间段，优先于windows
+	Blackouts []*WindowConfig `yaml:"blackouts"`
+	// 停止交易时撤销所有交易对的挂单，账户中设置为false时也会覆盖顶层配置
+	CancelOnStop *bool `yaml:"cancel_on_stop"`
+}
+
+// 时间段，start和end、cron和duration、from和to三种方式选择一种
+type WindowConfig struct {
+	Name string `yaml:"name"`
+	// 每天的开始和结束时间，格式为15:04，end不大于start时跨过午夜，start等于end时为全天
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+	// 时间段开始的星期，mon、tue、wed、thu、fri、sat、sun，为空时为每天
+	Days []string `yaml:"days"`
+	// cron表达式，每次触发后持续duration毫秒
+	Cron     string `yaml:"cron"`
+	Duration int64  `yaml:"duration"`
+	// 固定的开始和结束时间，格式为2006-01-02 15:04
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+
+	// 时间段内替换所有交易对的参数，未设置时不替换，设置为0时也会替换
+	SellNumber     *float64 `yaml:"sell_number"`
+	ExpectValue    *float64 `yaml:"expect_value"`
+	MakeUpPercent  *int     `yaml:"makeup_percent"`
+	BalancePercent *int     `yaml:"balance_percent"`
+}
+
+// 启动时和定时与交易所对账
+type ReconcileConfig struct {
+	// 定时对账的时间间隔，单位毫秒，设置为0则只在启动时对账
+	Interval int64 `yaml:"interval"`
+	// 交易所有挂单但订单日志和本地都没有记录时的处理方式，adopt或者cancel
+	UnknownOrders string `yaml:"unknown_orders"`
+	// 对账报告文件路径，每次对账追加一行，为空时只输出日志
+	ReportFile string `yaml:"report_file"`
+}
+
+// 跨交易所价差监控，至少配置两个行情来源时生效
+type ArbitrageConfig struct {
+	// 检查的时间间隔，单位毫秒，设置为0则不监控
+	Interval int64 `yaml:"interval"`
+	// 扣除手续费和提币费用后的价差百分比超过此值时告警
+	Threshold float64 `yaml:"threshold"`
+	// 计算价差使用的base currency数量，设置为0则使用两边盘口的较小数量
+	Amount float64 `yaml:"amount"`
+	// 行情超过此时间未更新时不参与计算，单位毫秒，设置为0则不检查
+	MaxQuoteAge int64              `yaml:"max_quote_age"`
+	Symbols     []string           `yaml:"symbols"`
+	Sources     []*ArbitrageSource `yaml:"sources"`
+	Triangular  TriangularConfig   `yaml:"triangular"`
+}
+
+// 单个交易所内的三角套利扫描
+type TriangularConfig struct {
+	// 检查的时间间隔，单位毫秒，设置为0则不扫描
+	Interval int64 `yaml:"interval"`
+	// fcoin或者binance，为空时使用fcoin
+	Venue    string `yaml:"venue"`
+	VenueURL string `yaml:"venue_url"`
+	// 扣除手续费和取整后的收益百分比超过此值时告警
+	Threshold float64 `yaml:"threshold"`
+	// 每笔交易的吃单手续费率
+	TakerFee float64 `yaml:"taker_fee"`
+	// 起始币种 -> 单次套利最多使用的数量
+	MaxAmounts map[string]float64 `yaml:"max_amounts"`
+	// 除起始币种外允许经过的币种，为空时允许所有币种
+	Currencies []string `yaml:"currencies"`
+}
+
+// 价差监控的行情来源
+// venue:
+//
+//	fcoin    fcoin的ticker
+//	binance  币安兼容的bookTicker
+//	feed     本地json文件，格式为 {"btcusdt": {"bid": 1, "bid_amount": 1, "ask": 2, "ask_amount": 1, "time": 毫秒时间戳}}
+type ArbitrageSource struct {
+	Name     string `yaml:"name"`
+	Venue    string `yaml:"venue"`
+	VenueURL string `yaml:"venue_url"`
+	Path     string `yaml:"path"`
+	// 吃单手续费率，例如0.001
+	TakerFee float64 `yaml:"taker_fee"`
+	// 交易对 -> 从该来源提出base currency的手续费，单位为base currency，在该来源买入时计入成本
+	WithdrawFees map[string]float64 `yaml:"withdraw_fees"`
+}
+
+// 告警通知配置，未配置任何通知方式时只输出日志
+type NotifyConfig struct {
+	// 相同告警的去重时间，单位毫秒
+	DedupWindow int64 `yaml:"dedup_window"`
+	// 每分钟最多发送的告警数量，设置为0则不限制
+	RateLimit int `yaml:"rate_limit"`
+	// 连续请求失败达到此次数时告警，设置为0则不告警
+	APIErrorThreshold int `yaml:"api_error_threshold"`
+	// 币种 -> 可用余额低于此值时告警
+	LowBalance map[string]float64 `yaml:"low_balance"`
+	// 订单存在时间超过此值仍未完成时告警，单位毫秒，设置为0则不告警
+	StuckOrderAfter int64 `yaml:"stuck_order_after"`
+	// 每天发送盈亏汇总的时间，格式为15:04，为空时不发送
+	DailySummaryAt string `yaml:"daily_summary_at"`
+
+	SMTP     *SMTPConfig     `yaml:"smtp"`
+	Webhook  *WebhookConfig  `yaml:"webhook"`
+	Telegram *TelegramConfig `yaml:"telegram"`
+	Slack    *WebhookConfig  `yaml:"slack"`
+}
+
+// 邮件通知，密码从环境变量读取
+type SMTPConfig struct {
+	Host        string   `yaml:"host"`
+	Port        int      `yaml:"port"`
+	Username    string   `yaml:"username"`
+	PasswordEnv string   `yaml:"password_env"`
+	From        string   `yaml:"from"`
+	To          []string `yaml:"to"`
+}
+
+//
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+}
+
+// Telegram机器人通知，token从环境变量读取
+type TelegramConfig struct {
+	TokenEnv string `yaml:"token_env"`
+	ChatId   string `yaml:"chat_id"`
+	// 默认为https://api.telegram.org
+	BaseURL string `yaml:"base_url"`
 }
 
 // 服务器时间
@@ -82,6 +405,20 @@ type Quote struct {
 	IntradayQuoteCurrencyVOL float64
 }
 
+// 深度，bids和asks按价格、数量交替排列
+type Depth struct {
+	Status int           `json:"status"`
+	Data   *DepthContext `json:"data"`
+}
+
+type DepthContext struct {
+	Type string    `json:"type"`
+	Seq  int64     `json:"seq"`
+	Ts   int64     `json:"ts"`
+	Bids []float64 `json:"bids"`
+	Asks []float64 `json:"asks"`
+}
+
 // 账户资产
 type AccountBalance struct {
 	Status int               `json:"status"`
@@ -131,7 +468,7 @@ type OrderInfo struct {
 	Symbol        string `json:"symbol"`
 	Type          string `json:"type"`
 	Side          string `json:"side"`
-	price         string `json:"price"`
+	Price         string `json:"price"`
 	Amount        string `json:"amount"`
 	State         string `json:"state"`
 	ExecutedValue string `json:"executed_value"`
@@ -139,6 +476,13 @@ type OrderInfo struct {
 	FilledAmount  string `json:"filled_amount"`
 	CreatedAt     int64  `json:"created_at"`
 	Source        string `json:"source"`
+	// 交易所支持客户端订单号时返回
+	ClientOrderId string `json:"client_order_id,omitempty"`
+}
+
+type OrderDetail struct {
+	Status int        `json:"status"`
+	Data   *OrderInfo `json:"data"`
 }
 
 type CancelOrder struct {
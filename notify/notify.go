@@ -0,0 +1,317 @@
+package notify
+
+import (
+	"fcoinExchange/conf"
+	"fcoinExchange/log"
+	"fcoinExchange/metrics"
+	"fcoinExchange/model"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 告警类型
+const (
+	KindKillSwitch   string = "kill_switch"
+	KindAPIError     string = "api_error"
+	KindLowBalance   string = "low_balance"
+	KindStuckOrder   string = "stuck_order"
+	KindDailySummary string = "daily_summary"
+	KindCrash        string = "crash"
+	KindStalledLoop  string = "stalled_loop"
+	KindArbitrage    string = "arbitrage"
+	KindReconcile    string = "reconcile"
+	KindSchedule     string = "schedule"
+)
+
+// 告警级别
+const (
+	SeverityInfo     string = "info"
+	SeverityWarning  string = "warning"
+	SeverityCritical string = "critical"
+)
+
+var (
+	// 默认的去重时间
+	defaultDedupWindow = 10 * time.Minute
+	// 等待发送的告警数量
+	queueSize = 64
+
+	notifier *Notifier
+	mu       sync.RWMutex
+)
+
+// 告警事件
+type Event struct {
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	Account  string `json:"account,omitempty"`
+	// 同一类型告警的区分标识，例如币种或者订单号，用于去重
+	Key     string    `json:"key,omitempty"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+//
+func (p *Event) dedupKey() string {
+	return p.Kind + "/" + p.Account + "/" + p.Key
+}
+
+// 用于邮件、Telegram和Slack的文本内容
+func (p *Event) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", strings.ToUpper(p.Severity), p.Title)
+	if p.Account != "" {
+		fmt.Fprintf(&b, " (account %s)", p.Account)
+	}
+	if p.Message != "" {
+		b.WriteString("\n")
+		b.WriteString(p.Message)
+	}
+	fmt.Fprintf(&b, "\n%s", p.Time.Format("2006-01-02 15:04:05"))
+	return b.String()
+}
+
+// 告警发送方式
+type Sink interface {
+	Name() string
+	Send(e *Event) error
+}
+
+// 对告警去重和限流后发送到所有通知方式
+type Notifier struct {
+	sinks       []Sink
+	dedupWindow time.Duration
+	rateLimit   int
+
+	// 去重标识 -> 最后一次发送的时间
+	sent map[string]time.Time
+	// 最近一分钟内的发送时间
+	recent []time.Time
+	queue  chan *Event
+	stop   chan struct{}
+	sync.Mutex
+}
+
+// 根据配置创建通知方式并启动发送任务
+func New(cfg *model.NotifyConfig) (*Notifier, error) {
+	sinks, err := NewSinks(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var p = &Notifier{
+		sinks:       sinks,
+		dedupWindow: time.Duration(cfg.DedupWindow) * time.Millisecond,
+		rateLimit:   cfg.RateLimit,
+		sent:        make(map[string]time.Time),
+		recent:      make([]time.Time, 0),
+		queue:       make(chan *Event, queueSize),
+		stop:        make(chan struct{}),
+	}
+	if p.dedupWindow <= 0 {
+		p.dedupWindow = defaultDedupWindow
+	}
+
+	go p.run()
+	return p, nil
+}
+
+// 异步发送告警，重复的告警和超过限流的告警被丢弃
+func (p *Notifier) Notify(e *Event) {
+	if !p.allow(e) {
+		return
+	}
+
+	select {
+	case p.queue <- e:
+	default:
+		metrics.Inc("notify", "dropped", e.Kind)
+		log.Named("notify").Errorw("notify queue full, event dropped", "kind", e.Kind, "title", e.Title)
+	}
+}
+
+// 同步发送告警，用于进程退出前
+func (p *Notifier) NotifySync(e *Event) {
+	if !p.allow(e) {
+		return
+	}
+	p.send(e)
+}
+
+// 检查告警是否重复或者超过限流，通过时记录发送时间
+func (p *Notifier) allow(e *Event) bool {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	key := e.dedupKey()
+	if last, ok := p.sent[key]; ok && e.Time.Sub(last) < p.dedupWindow {
+		metrics.Inc("notify", "deduplicated", e.Kind)
+		return false
+	}
+
+	// 清理一分钟之前的发送时间和过期的去重记录
+	var recent = p.recent[:0]
+	for _, t := range p.recent {
+		if e.Time.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	p.recent = recent
+	for k, t := range p.sent {
+		if e.Time.Sub(t) >= p.dedupWindow {
+			delete(p.sent, k)
+		}
+	}
+
+	if p.rateLimit > 0 && len(p.recent) >= p.rateLimit {
+		metrics.Inc("notify", "rate_limited", e.Kind)
+		log.Named("notify").Infow("notify rate limited", "kind", e.Kind, "title", e.Title)
+		return false
+	}
+
+	p.sent[key] = e.Time
+	p.recent = append(p.recent, e.Time)
+	return true
+}
+
+// 停止后发送完队列中剩余的告警
+func (p *Notifier) run() {
+	for {
+		select {
+		case e := <-p.queue:
+			p.send(e)
+		case <-p.stop:
+			for {
+				select {
+				case e := <-p.queue:
+					p.send(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// 停止发送任务
+func (p *Notifier) Stop() {
+	close(p.stop)
+}
+
+// 发送到所有通知方式，单个通知方式失败时不影响其他通知方式
+func (p *Notifier) send(e *Event) {
+	lg := log.Named("notify").With("kind", e.Kind, "severity", e.Severity, log.FieldAccount, e.Account)
+	lg.Infow(e.Title, "message", e.Message)
+
+	for _, s := range p.sinks {
+		err := s.Send(e)
+		if err != nil {
+			metrics.Inc("notify", "failed", s.Name())
+			lg.Errorw("send notification failed", "sink", s.Name(), "error", err)
+			continue
+		}
+		metrics.Inc("notify", "sent", s.Name())
+	}
+}
+
+// 继承去重记录，配置变化后重建通知方式时避免重复发送
+func (p *Notifier) inherit(old *Notifier) {
+	old.Lock()
+	defer old.Unlock()
+	p.Lock()
+	defer p.Unlock()
+	for k, v := range old.sent {
+		p.sent[k] = v
+	}
+	p.recent = append(p.recent, old.recent...)
+}
+
+// 根据当前配置创建全局的通知，配置中的通知方式变化时重建
+func Init() {
+	n, err := New(&conf.GetConfiguration().Notify)
+	if err != nil {
+		log.Logger.Errorf("create notifier failed, notifications only write to log. %s", err)
+		n, _ = New(&model.NotifyConfig{})
+	}
+	mu.Lock()
+	notifier = n
+	mu.Unlock()
+
+	go watch()
+}
+
+//
+func watch() {
+	for c := range conf.Watch() {
+		if reflect.DeepEqual(c.Old.Notify, c.New.Notify) {
+			continue
+		}
+
+		n, err := New(&c.New.Notify)
+		if err != nil {
+			log.Logger.Errorf("apply notify configuration failed. %s", err)
+			continue
+		}
+
+		mu.Lock()
+		n.inherit(notifier)
+		old := notifier
+		notifier = n
+		mu.Unlock()
+		old.Stop()
+		log.Logger.Infof("notify configuration changed")
+	}
+}
+
+//
+func current() *Notifier {
+	mu.RLock()
+	defer mu.RUnlock()
+	return notifier
+}
+
+// 通过全局的通知发送告警，未初始化时只输出日志
+func Send(e *Event) {
+	n := current()
+	if n == nil {
+		log.Logger.Infof("notification %s: %s", e.Kind, e.Title)
+		return
+	}
+	n.Notify(e)
+}
+
+//
+func SendSync(e *Event) {
+	n := current()
+	if n == nil {
+		log.Logger.Infof("notification %s: %s", e.Kind, e.Title)
+		return
+	}
+	n.NotifySync(e)
+}
+
+// 在任务的goroutine中defer调用，崩溃时发送告警后继续panic
+func Recover(account, task string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	SendSync(&Event{
+		Kind:     KindCrash,
+		Severity: SeverityCritical,
+		Account:  account,
+		Key:      task,
+		Title:    fmt.Sprintf("task %s crashed", task),
+		Message:  fmt.Sprint(r),
+	})
+	panic(r)
+}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"fcoinExchange/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录发送的告警
+type memorySink struct {
+	sync.Mutex
+	events []*Event
+}
+
+func (p *memorySink) Name() string { return "memory" }
+
+func (p *memorySink) Send(e *Event) error {
+	p.Lock()
+	defer p.Unlock()
+	p.events = append(p.events, e)
+	return nil
+}
+
+//
+func (p *memorySink) count() int {
+	p.Lock()
+	defer p.Unlock()
+	return len(p.events)
+}
+
+//
+func newTestNotifier(t *testing.T, cfg *model.NotifyConfig) (*Notifier, *memorySink) {
+	n, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(n.Stop)
+	s := new(memorySink)
+	n.sinks = []Sink{s}
+	return n, s
+}
+
+// 去重时间内同一类型、账户和标识的告警只发送一次
+func TestNotifyDedup(t *testing.T) {
+	n, s := newTestNotifier(t, &model.NotifyConfig{DedupWindow: 60000})
+	at := time.Now()
+
+	events := []struct {
+		e    *Event
+		sent bool
+	}{
+		{&Event{Kind: KindLowBalance, Account: "a", Key: "usdt", Time: at}, true},
+		{&Event{Kind: KindLowBalance, Account: "a", Key: "usdt", Time: at.Add(30 * time.Second)}, false},
+		{&Event{Kind: KindLowBalance, Account: "a", Key: "btc", Time: at.Add(30 * time.Second)}, true},
+		{&Event{Kind: KindLowBalance, Account: "b", Key: "usdt", Time: at.Add(30 * time.Second)}, true},
+		{&Event{Kind: KindStuckOrder, Account: "a", Key: "usdt", Time: at.Add(30 * time.Second)}, true},
+		{&Event{Kind: KindLowBalance, Account: "a", Key: "usdt", Time: at.Add(61 * time.Second)}, true},
+	}
+
+	var want int
+	for i, v := range events {
+		n.NotifySync(v.e)
+		if v.sent {
+			want++
+		}
+		if got := s.count(); got != want {
+			t.Errorf("event %d: sent %d, want %d", i, got, want)
+		}
+	}
+}
+
+// 一分钟内最多发送rate_limit条告警
+func TestNotifyRateLimit(t *testing.T) {
+	n, s := newTestNotifier(t, &model.NotifyConfig{RateLimit: 3})
+	at := time.Now()
+
+	for i := 0; i < 5; i++ {
+		n.NotifySync(&Event{Kind: KindAPIError, Key: string(rune('a' + i)), Time: at.Add(time.Duration(i) * time.Second)})
+	}
+	if got := s.count(); got != 3 {
+		t.Errorf("sent %d within a minute, want 3", got)
+	}
+
+	n.NotifySync(&Event{Kind: KindAPIError, Key: "later", Time: at.Add(61 * time.Second)})
+	if got := s.count(); got != 4 {
+		t.Errorf("sent %d after a minute, want 4", got)
+	}
+}
+
+// 异步发送的告警在Stop后发送完
+func TestNotifyAsync(t *testing.T) {
+	n, err := New(&model.NotifyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := new(memorySink)
+	n.sinks = []Sink{s}
+
+	for i := 0; i < 3; i++ {
+		n.Notify(&Event{Kind: KindCrash, Key: string(rune('a' + i))})
+	}
+	n.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for s.count() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := s.count(); got != 3 {
+		t.Errorf("sent %d, want 3", got)
+	}
+}
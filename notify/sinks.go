@@ -0,0 +1,282 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fcoinExchange/model"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+	// 连接邮件服务器和发送一封邮件的总时间
+	smtpTimeout = 10 * time.Second
+
+	defaultTelegramURL = "https://api.telegram.org"
+)
+
+// 根据配置创建所有通知方式
+func NewSinks(cfg *model.NotifyConfig) ([]Sink, error) {
+	var sinks = make([]Sink, 0)
+
+	if cfg.SMTP != nil {
+		s, err := NewSMTP(cfg.SMTP)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+
+	if cfg.Webhook != nil {
+		if cfg.Webhook.URL == "" {
+			return nil, fmt.Errorf("webhook url is empty")
+		}
+		sinks = append(sinks, &Webhook{URL: cfg.Webhook.URL})
+	}
+
+	if cfg.Telegram != nil {
+		s, err := NewTelegram(cfg.Telegram)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+
+	if cfg.Slack != nil {
+		if cfg.Slack.URL == "" {
+			return nil, fmt.Errorf("slack url is empty")
+		}
+		sinks = append(sinks, &Slack{URL: cfg.Slack.URL})
+	}
+
+	return sinks, nil
+}
+
+// 发送JSON请求，返回非2xx状态码时返回错误
+func postJSON(addr string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Post(addr, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// 地址中可能包含访问令牌，不输出到日志
+		if ue, ok := err.(*url.Error); ok {
+			return fmt.Errorf("%s request failed. %s", ue.Op, ue.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("return status %d, %s", resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// 邮件通知
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+//
+func NewSMTP(cfg *model.SMTPConfig) (*SMTP, error) {
+	if cfg.Host == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("smtp host, from and to are required")
+	}
+
+	var port = cfg.Port
+	if port == 0 {
+		port = 25
+	}
+
+	var s = &SMTP{
+		Addr:     net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		Username: cfg.Username,
+		From:     cfg.From,
+		To:       cfg.To,
+	}
+	if cfg.PasswordEnv != "" {
+		s.Password = os.Getenv(cfg.PasswordEnv)
+		if s.Password == "" {
+			return nil, fmt.Errorf("environment variable %s is empty", cfg.PasswordEnv)
+		}
+	}
+	return s, nil
+}
+
+//
+func (p *SMTP) Name() string {
+	return "smtp"
+}
+
+//
+func (p *SMTP) Send(e *Event) error {
+	var auth smtp.Auth
+	if p.Username != "" {
+		host, _, _ := net.SplitHostPort(p.Addr)
+		auth = smtp.PlainAuth("", p.Username, p.Password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", p.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(p.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [fcoin %s] %s\r\n", e.Severity, e.Title)
+	fmt.Fprintf(&msg, "Date: %s\r\n", e.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.Replace(e.Text(), "\n", "\r\n", -1))
+	msg.WriteString("\r\n")
+
+	return p.sendMail(auth, msg.Bytes())
+}
+
+// 与smtp.SendMail相同，但连接和整个会话都有超时，邮件服务器无响应时不会一直阻塞发送任务
+func (p *SMTP) sendMail(auth smtp.Auth, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", p.Addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(time.Now().Add(smtpTimeout))
+	if err != nil {
+		return err
+	}
+
+	host, _, _ := net.SplitHostPort(p.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp server %s does not support AUTH", p.Addr)
+		}
+		err = c.Auth(auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(p.From)
+	if err != nil {
+		return err
+	}
+	for _, to := range p.To {
+		err = c.Rcpt(to)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// 通用webhook，以JSON格式POST告警事件
+type Webhook struct {
+	URL string
+}
+
+//
+func (p *Webhook) Name() string {
+	return "webhook"
+}
+
+//
+func (p *Webhook) Send(e *Event) error {
+	return postJSON(p.URL, e)
+}
+
+// Telegram机器人通知
+type Telegram struct {
+	BaseURL string
+	Token   string
+	ChatId  string
+}
+
+//
+func NewTelegram(cfg *model.TelegramConfig) (*Telegram, error) {
+	if cfg.TokenEnv == "" || cfg.ChatId == "" {
+		return nil, fmt.Errorf("telegram token_env and chat_id are required")
+	}
+
+	var s = &Telegram{
+		BaseURL: cfg.BaseURL,
+		Token:   os.Getenv(cfg.TokenEnv),
+		ChatId:  cfg.ChatId,
+	}
+	if s.Token == "" {
+		return nil, fmt.Errorf("environment variable %s is empty", cfg.TokenEnv)
+	}
+	if s.BaseURL == "" {
+		s.BaseURL = defaultTelegramURL
+	}
+	return s, nil
+}
+
+//
+func (p *Telegram) Name() string {
+	return "telegram"
+}
+
+//
+func (p *Telegram) Send(e *Event) error {
+	return postJSON(strings.TrimRight(p.BaseURL, "/")+"/bot"+p.Token+"/sendMessage", map[string]string{
+		"chat_id": p.ChatId,
+		"text":    e.Text(),
+	})
+}
+
+// Slack兼容的incoming webhook
+type Slack struct {
+	URL string
+}
+
+//
+func (p *Slack) Name() string {
+	return "slack"
+}
+
+//
+func (p *Slack) Send(e *Event) error {
+	return postJSON(p.URL, map[string]string{
+		"text": e.Text(),
+	})
+}
@@ -0,0 +1,223 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 记录收到的请求内容，按status返回
+func recordServer(t *testing.T, status int) (*httptest.Server, chan map[string]interface{}, chan string) {
+	bodies := make(chan map[string]interface{}, 8)
+	paths := make(chan string, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		var v map[string]interface{}
+		data, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Errorf("invalid json body %s", data)
+		}
+		bodies <- v
+		paths <- r.URL.Path
+		w.WriteHeader(status)
+		w.Write([]byte("server says no"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, bodies, paths
+}
+
+//
+func testEvent() *Event {
+	return &Event{
+		Kind:     KindLowBalance,
+		Severity: SeverityWarning,
+		Account:  "desk1",
+		Key:      "usdt",
+		Title:    "usdt balance below 100",
+		Message:  "available 50",
+		Time:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+//
+func TestWebhookSend(t *testing.T) {
+	srv, bodies, _ := recordServer(t, http.StatusOK)
+	if err := (&Webhook{URL: srv.URL}).Send(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	v := <-bodies
+	if v["kind"] != KindLowBalance || v["severity"] != SeverityWarning || v["account"] != "desk1" ||
+		v["key"] != "usdt" || v["title"] != "usdt balance below 100" || v["message"] != "available 50" {
+		t.Errorf("webhook payload = %v", v)
+	}
+}
+
+//
+func TestSlackSend(t *testing.T) {
+	srv, bodies, _ := recordServer(t, http.StatusOK)
+	if err := (&Slack{URL: srv.URL}).Send(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	v := <-bodies
+	if v["text"] != testEvent().Text() || len(v) != 1 {
+		t.Errorf("slack payload = %v", v)
+	}
+}
+
+//
+func TestTelegramSend(t *testing.T) {
+	srv, bodies, paths := recordServer(t, http.StatusOK)
+	s := &Telegram{BaseURL: srv.URL + "/", Token: "123:abc", ChatId: "42"}
+	if err := s.Send(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	v := <-bodies
+	if v["chat_id"] != "42" || v["text"] != testEvent().Text() {
+		t.Errorf("telegram payload = %v", v)
+	}
+	if p := <-paths; p != "/bot123:abc/sendMessage" {
+		t.Errorf("telegram path = %s", p)
+	}
+}
+
+// 非2xx返回错误，错误中不包含可能带有令牌的地址
+func TestSinkNon2xx(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusInternalServerError} {
+		srv, _, _ := recordServer(t, status)
+		sinks := []Sink{
+			&Webhook{URL: srv.URL},
+			&Slack{URL: srv.URL},
+			&Telegram{BaseURL: srv.URL, Token: "secret-token", ChatId: "1"},
+		}
+		for _, s := range sinks {
+			err := s.Send(testEvent())
+			if err == nil || !strings.Contains(err.Error(), "server says no") {
+				t.Errorf("%s status %d: err = %v", s.Name(), status, err)
+			}
+		}
+	}
+
+	err := (&Telegram{BaseURL: "http://127.0.0.1:1", Token: "secret-token", ChatId: "1"}).Send(testEvent())
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("connection error = %v", err)
+	}
+}
+
+// 只支持发送邮件所需命令的SMTP服务器，返回收到的邮件内容
+func smtpStub(t *testing.T) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	mails := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var (
+			r    = bufio.NewReader(conn)
+			mail strings.Builder
+		)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP stub")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM"), strings.HasPrefix(cmd, "RCPT TO"):
+				mail.WriteString(strings.TrimSpace(line) + "\n")
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					mail.WriteString(l)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				mails <- mail.String()
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), mails
+}
+
+//
+func TestSMTPSend(t *testing.T) {
+	addr, mails := smtpStub(t)
+	s := &SMTP{Addr: addr, From: "bot@example.com", To: []string{"a@example.com", "b@example.com"}}
+	if err := s.Send(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+
+	mail := <-mails
+	for _, want := range []string{
+		"MAIL FROM:<bot@example.com>",
+		"RCPT TO:<a@example.com>",
+		"RCPT TO:<b@example.com>",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: [fcoin warning] usdt balance below 100\r\n",
+		"available 50\r\n",
+	} {
+		if !strings.Contains(mail, want) {
+			t.Errorf("mail missing %q:\n%s", want, mail)
+		}
+	}
+}
+
+// 邮件服务器接受连接但不响应时在smtpTimeout后返回
+func TestSMTPTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	old := smtpTimeout
+	smtpTimeout = 200 * time.Millisecond
+	defer func() { smtpTimeout = old }()
+
+	start := time.Now()
+	err = (&SMTP{Addr: ln.Addr().String(), From: "bot@example.com", To: []string{"a@example.com"}}).Send(testEvent())
+	if err == nil {
+		t.Fatal("send to silent server succeeded")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("send blocked for %s", d)
+	}
+}
@@ -0,0 +1,206 @@
+package rebalance
+
+import (
+	"errors"
+	"fcoinExchange/model"
+	"math"
+	"sync"
+	"time"
+)
+
+// 执行再平衡的订单类型
+const (
+	// 在对手价下限价单
+	OrderLimit string = "limit"
+	// 市价单
+	OrderMarket string = "market"
+	// 在对手价下限价单，未立即成交的部分撤销
+	OrderIOC string = "ioc"
+	// 使用makeup_algo配置的执行算法
+	OrderAlgo string = "algo"
+)
+
+var (
+	ErrNoPrice      = errors.New("no bid or ask price to value inventory")
+	ErrNoInventory  = errors.New("no inventory to rebalance")
+	ErrWithinBand   = errors.New("inventory ratio within band")
+	ErrBelowMinClip = errors.New("rebalance amount below min clip")
+	ErrCooldown     = errors.New("rebalance in cooldown")
+	ErrDailyLimit   = errors.New("rebalance daily limit reached")
+
+	// 未配置时的默认值
+	defaultTargetRatio = 0.5
+	defaultBand        = 0.4
+)
+
+// 是否为不需要或者暂时不能再平衡的原因，而不是执行错误
+func Skipped(err error) bool {
+	switch err {
+	case ErrNoPrice, ErrNoInventory, ErrWithinBand, ErrBelowMinClip, ErrCooldown, ErrDailyLimit:
+		return true
+	}
+	return false
+}
+
+// 交易对两个币种的库存，Base和Quote为总余额，用于计算比例，
+// BaseFree和QuoteFree为可用于下单的数量，用于限制订单数量
+type Inventory struct {
+	Base      float64
+	Quote     float64
+	BaseFree  float64
+	QuoteFree float64
+}
+
+// 再平衡订单，Amount为base currency数量，Price为对手价
+type Decision struct {
+	Side   string
+	Amount float64
+	Price  float64
+	Mid    float64
+	// 下单前base currency价值占总价值的比例，以及与目标比例的偏差
+	Ratio     float64
+	Deviation float64
+}
+
+// 按配置补齐默认值，cfg为nil时使用全部默认值，maxClip为未配置max_clip时的最大数量。
+// target_ratio和band设置为0时保留0
+func Normalize(cfg *model.RebalanceConfig, maxClip float64) *model.RebalanceConfig {
+	var c model.RebalanceConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.TargetRatio == nil {
+		v := defaultTargetRatio
+		c.TargetRatio = &v
+	}
+	if c.Band == nil {
+		v := defaultBand
+		c.Band = &v
+	}
+	if c.MaxClip == 0 {
+		c.MaxClip = maxClip
+	}
+	return &c
+}
+
+// 按中间价计算库存比例，超出目标比例±band时买入或卖出base currency回到目标比例，
+// 数量不超过max_clip、可用余额和当天剩余额度，小于min_clip时不下单。cfg为Normalize返回的配置
+func Decide(cfg *model.RebalanceConfig, inv *Inventory, q *model.Quote, st *State, now time.Time) (*Decision, error) {
+	if q.MaxBuyOnePrice <= 0 || q.MinSellOnePrice <= 0 {
+		return nil, ErrNoPrice
+	}
+	mid := (q.MaxBuyOnePrice + q.MinSellOnePrice) / 2
+
+	value := inv.Base*mid + inv.Quote
+	if value <= 0 {
+		return nil, ErrNoInventory
+	}
+
+	d := &Decision{
+		Mid:   mid,
+		Ratio: inv.Base * mid / value,
+	}
+	d.Deviation = d.Ratio - *cfg.TargetRatio
+	if math.Abs(d.Deviation) <= *cfg.Band {
+		return d, ErrWithinBand
+	}
+
+	d.Amount = math.Abs(d.Deviation) * value / mid
+	if d.Deviation > 0 {
+		d.Side = "sell"
+		d.Price = q.MaxBuyOnePrice
+		d.Amount = math.Min(d.Amount, inv.BaseFree)
+	} else {
+		d.Side = "buy"
+		d.Price = q.MinSellOnePrice
+		d.Amount = math.Min(d.Amount, inv.QuoteFree/d.Price)
+	}
+	if cfg.MaxClip > 0 {
+		d.Amount = math.Min(d.Amount, cfg.MaxClip)
+	}
+
+	if st != nil {
+		if cfg.Cooldown > 0 && now.Sub(st.LastAt) < time.Duration(cfg.Cooldown)*time.Millisecond {
+			return d, ErrCooldown
+		}
+		amount, orders := st.today(now)
+		if cfg.DailyOrders > 0 && orders >= cfg.DailyOrders {
+			return d, ErrDailyLimit
+		}
+		if cfg.DailyLimit > 0 {
+			if amount >= cfg.DailyLimit {
+				return d, ErrDailyLimit
+			}
+			d.Amount = math.Min(d.Amount, cfg.DailyLimit-amount)
+		}
+	}
+
+	if d.Amount <= 0 || d.Amount < cfg.MinClip {
+		return d, ErrBelowMinClip
+	}
+	return d, nil
+}
+
+// 交易对的再平衡记录，用于冷却时间和每日限制，日期按本地时区计算
+type State struct {
+	LastAt time.Time
+	day    string
+	amount float64
+	orders int
+}
+
+// 当天已经再平衡的数量和次数
+func (p *State) today(now time.Time) (float64, int) {
+	if p.day != now.Format("2006-01-02") {
+		return 0, 0
+	}
+	return p.amount, p.orders
+}
+
+// 记录一次再平衡
+func (p *State) Record(amount float64, now time.Time) {
+	day := now.Format("2006-01-02")
+	if p.day != day {
+		p.day = day
+		p.amount = 0
+		p.orders = 0
+	}
+	p.LastAt = now
+	p.amount += amount
+	p.orders++
+}
+
+// 按交易对保存再平衡记录
+type Tracker struct {
+	sync.Mutex
+	states map[string]*State
+}
+
+//
+func NewTracker() *Tracker {
+	return &Tracker{states: make(map[string]*State)}
+}
+
+// 使用交易对的记录执行Decide
+func (p *Tracker) Decide(symbol string, cfg *model.RebalanceConfig, inv *Inventory, q *model.Quote, now time.Time) (*Decision, error) {
+	p.Lock()
+	defer p.Unlock()
+	return Decide(cfg, inv, q, p.state(symbol), now)
+}
+
+// 下单成功后记录
+func (p *Tracker) Record(symbol string, amount float64, now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+	p.state(symbol).Record(amount, now)
+}
+
+// 需持有锁
+func (p *Tracker) state(symbol string) *State {
+	st, ok := p.states[symbol]
+	if !ok {
+		st = new(State)
+		p.states[symbol] = st
+	}
+	return st
+}
@@ -0,0 +1,166 @@
+package rebalance
+
+import (
+	"fcoinExchange/model"
+	"math"
+	"testing"
+	"time"
+)
+
+//
+func ratio(v float64) *float64 {
+	return &v
+}
+
+//
+func TestDecide(t *testing.T) {
+	var (
+		now       = time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+		yesterday = now.Add(-24 * time.Hour)
+		quote     = &model.Quote{MaxBuyOnePrice: 99, MinSellOnePrice: 101}
+		// 中间价100，总价值1000
+		balanced  = &Inventory{Base: 5, Quote: 500, BaseFree: 5, QuoteFree: 500}
+		baseHeavy = &Inventory{Base: 8, Quote: 200, BaseFree: 8, QuoteFree: 200}
+		// 偏离目标比例0.3，需要买入或卖出3
+		quoteHeavy = &Inventory{Base: 2, Quote: 800, BaseFree: 2, QuoteFree: 800}
+	)
+
+	// 当天已经再平衡的记录
+	recorded := func(at time.Time, amounts ...float64) *State {
+		st := new(State)
+		for _, v := range amounts {
+			st.Record(v, at)
+		}
+		return st
+	}
+
+	for _, c := range []struct {
+		name   string
+		cfg    model.RebalanceConfig
+		inv    *Inventory
+		quote  *model.Quote
+		state  *State
+		err    error
+		side   string
+		amount float64
+		price  float64
+	}{
+		{name: "within band", cfg: model.RebalanceConfig{Band: ratio(0.1)}, inv: balanced, err: ErrWithinBand},
+		{name: "too much base", cfg: model.RebalanceConfig{Band: ratio(0.1)}, inv: baseHeavy, side: "sell", amount: 3, price: 99},
+		{name: "too much quote", cfg: model.RebalanceConfig{Band: ratio(0.1)}, inv: quoteHeavy, side: "buy", amount: 3, price: 101},
+		{name: "target ratio", cfg: model.RebalanceConfig{TargetRatio: ratio(0.8), Band: ratio(0.1)}, inv: balanced, side: "buy", amount: 3, price: 101},
+		{
+			name: "sell limited by free base", cfg: model.RebalanceConfig{Band: ratio(0.1)},
+			inv: &Inventory{Base: 8, Quote: 200, BaseFree: 1.5, QuoteFree: 200}, side: "sell", amount: 1.5, price: 99,
+		},
+		{
+			name: "buy limited by free quote", cfg: model.RebalanceConfig{Band: ratio(0.1)},
+			inv: &Inventory{Base: 2, Quote: 800, BaseFree: 2, QuoteFree: 202}, side: "buy", amount: 2, price: 101,
+		},
+		{name: "max clip", cfg: model.RebalanceConfig{Band: ratio(0.1), MaxClip: 1}, inv: baseHeavy, side: "sell", amount: 1, price: 99},
+		{name: "min clip", cfg: model.RebalanceConfig{Band: ratio(0.1), MinClip: 5}, inv: quoteHeavy, err: ErrBelowMinClip, side: "buy", amount: 3, price: 101},
+		{
+			name: "no free balance", cfg: model.RebalanceConfig{Band: ratio(0.1)},
+			inv: &Inventory{Base: 8, Quote: 200, BaseFree: 0, QuoteFree: 200}, err: ErrBelowMinClip, side: "sell", price: 99,
+		},
+		{
+			name: "cooldown", cfg: model.RebalanceConfig{Band: ratio(0.1), Cooldown: 60000}, inv: baseHeavy,
+			state: recorded(now.Add(-time.Second), 1), err: ErrCooldown, side: "sell", amount: 3, price: 99,
+		},
+		{
+			name: "cooldown passed", cfg: model.RebalanceConfig{Band: ratio(0.1), Cooldown: 60000}, inv: baseHeavy,
+			state: recorded(now.Add(-time.Minute), 1), side: "sell", amount: 3, price: 99,
+		},
+		{
+			name: "daily limit remaining", cfg: model.RebalanceConfig{Band: ratio(0.1), DailyLimit: 3}, inv: baseHeavy,
+			state: recorded(now.Add(-time.Hour), 1, 1.5), side: "sell", amount: 0.5, price: 99,
+		},
+		{
+			name: "daily limit reached", cfg: model.RebalanceConfig{Band: ratio(0.1), DailyLimit: 3}, inv: baseHeavy,
+			state: recorded(now.Add(-time.Hour), 1, 2), err: ErrDailyLimit, side: "sell", amount: 3, price: 99,
+		},
+		{
+			name: "daily orders reached", cfg: model.RebalanceConfig{Band: ratio(0.1), DailyOrders: 2}, inv: baseHeavy,
+			state: recorded(now.Add(-time.Hour), 0.1, 0.1), err: ErrDailyLimit, side: "sell", amount: 3, price: 99,
+		},
+		{
+			name: "daily limit reset next day", cfg: model.RebalanceConfig{Band: ratio(0.1), DailyLimit: 3, DailyOrders: 1}, inv: baseHeavy,
+			state: recorded(yesterday, 3), side: "sell", amount: 3, price: 99,
+		},
+		{name: "no bid", cfg: model.RebalanceConfig{Band: ratio(0.1)}, inv: baseHeavy, quote: &model.Quote{MinSellOnePrice: 101}, err: ErrNoPrice},
+		{name: "no ask", cfg: model.RebalanceConfig{Band: ratio(0.1)}, inv: baseHeavy, quote: &model.Quote{MaxBuyOnePrice: 99}, err: ErrNoPrice},
+		{name: "no quote", cfg: model.RebalanceConfig{Band: ratio(0.1)}, inv: baseHeavy, quote: &model.Quote{}, err: ErrNoPrice},
+		{name: "zero target ratio", cfg: model.RebalanceConfig{TargetRatio: ratio(0), Band: ratio(0.1)}, inv: balanced, side: "sell", amount: 5, price: 99},
+		{name: "zero band balanced", cfg: model.RebalanceConfig{Band: ratio(0)}, inv: balanced, err: ErrWithinBand},
+		{
+			name: "zero band small deviation", cfg: model.RebalanceConfig{Band: ratio(0)},
+			inv: &Inventory{Base: 5.1, Quote: 490, BaseFree: 5.1, QuoteFree: 490}, side: "sell", amount: 0.1, price: 99,
+		},
+		{name: "default band", inv: baseHeavy, err: ErrWithinBand},
+		{name: "no inventory", cfg: model.RebalanceConfig{Band: ratio(0.1)}, inv: &Inventory{}, err: ErrNoInventory},
+	} {
+		q := c.quote
+		if q == nil {
+			q = quote
+		}
+		d, err := Decide(Normalize(&c.cfg, 0), c.inv, q, c.state, now)
+		if err != c.err {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.err)
+			continue
+		}
+		if err != nil && !Skipped(err) {
+			t.Errorf("%s: %v is not a skip reason", c.name, err)
+		}
+		if c.side == "" && c.amount == 0 {
+			continue
+		}
+		if d.Side != c.side || math.Abs(d.Amount-c.amount) > 1e-9 || d.Price != c.price {
+			t.Errorf("%s: decision %s %v at %v, want %s %v at %v", c.name, d.Side, d.Amount, d.Price, c.side, c.amount, c.price)
+		}
+	}
+}
+
+// 未配置的项使用默认值，max_clip使用传入的默认值
+func TestNormalize(t *testing.T) {
+	c := Normalize(nil, 2)
+	if *c.TargetRatio != defaultTargetRatio || *c.Band != defaultBand || c.MaxClip != 2 {
+		t.Errorf("default config = %+v", c)
+	}
+
+	// 设置为0时不使用默认值
+	c = Normalize(&model.RebalanceConfig{TargetRatio: ratio(0), Band: ratio(0)}, 2)
+	if *c.TargetRatio != 0 || *c.Band != 0 {
+		t.Errorf("target_ratio %v band %v, want 0", *c.TargetRatio, *c.Band)
+	}
+
+	cfg := &model.RebalanceConfig{TargetRatio: ratio(0.3), Band: ratio(0.05), MaxClip: 1}
+	c = Normalize(cfg, 2)
+	if *c.TargetRatio != 0.3 || *c.Band != 0.05 || c.MaxClip != 1 {
+		t.Errorf("config = %+v", c)
+	}
+	if c == cfg {
+		t.Errorf("Normalize modifies the configuration")
+	}
+}
+
+// 下单后按交易对记录冷却时间
+func TestTrackerRecord(t *testing.T) {
+	var (
+		tr  = NewTracker()
+		now = time.Now()
+		cfg = Normalize(&model.RebalanceConfig{Band: ratio(0.1), Cooldown: 60000}, 0)
+		inv = &Inventory{Base: 8, Quote: 200, BaseFree: 8, QuoteFree: 200}
+		q   = &model.Quote{MaxBuyOnePrice: 99, MinSellOnePrice: 101}
+	)
+
+	if _, err := tr.Decide("btcusdt", cfg, inv, q, now); err != nil {
+		t.Fatal(err)
+	}
+	tr.Record("btcusdt", 1, now)
+	if _, err := tr.Decide("btcusdt", cfg, inv, q, now.Add(time.Second)); err != ErrCooldown {
+		t.Errorf("btcusdt after record: err = %v, want ErrCooldown", err)
+	}
+	if _, err := tr.Decide("ethusdt", cfg, inv, q, now.Add(time.Second)); err != nil {
+		t.Errorf("ethusdt: err = %v", err)
+	}
+}
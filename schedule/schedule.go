@@ -0,0 +1,256 @@
+package schedule
+
+import (
+	"fcoinExchange/model"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	weekdays = map[string]time.Weekday{
+		"sun": time.Sunday,
+		"mon": time.Monday,
+		"tue": time.Tuesday,
+		"wed": time.Wednesday,
+		"thu": time.Thursday,
+		"fri": time.Friday,
+		"sat": time.Saturday,
+	}
+)
+
+// 某一时刻的交易安排
+type State struct {
+	// 是否允许交易
+	Active bool
+	// 所在的交易时间段，未配置windows时为nil
+	Window *model.WindowConfig
+	// 所在的禁止交易时间段名称
+	Blackout string
+}
+
+// 状态的简短描述，用于日志和比较状态是否变化
+func (p *State) String() string {
+	switch {
+	case p.Blackout != "":
+		return "blackout " + p.Blackout
+	case !p.Active:
+		return "outside windows"
+	case p.Window != nil:
+		return "window " + p.Window.Name
+	}
+	return "always"
+}
+
+// 交易时间安排
+type Schedule struct {
+	loc       *time.Location
+	windows   []*period
+	blackouts []*period
+}
+
+// 解析配置，cfg为nil时全天交易
+func New(cfg *model.ScheduleConfig) (*Schedule, error) {
+	p := &Schedule{loc: time.Local}
+	if cfg == nil {
+		return p, nil
+	}
+
+	if cfg.TimeZone != "" {
+		loc, err := time.LoadLocation(cfg.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("time_zone %s is invalid. %s", cfg.TimeZone, err)
+		}
+		p.loc = loc
+	}
+
+	for i, v := range cfg.Windows {
+		pd, err := newPeriod(v, p.loc)
+		if err != nil {
+			return nil, fmt.Errorf("window %d %s: %s", i+1, v.Name, err)
+		}
+		p.windows = append(p.windows, pd)
+	}
+	for i, v := range cfg.Blackouts {
+		pd, err := newPeriod(v, p.loc)
+		if err != nil {
+			return nil, fmt.Errorf("blackout %d %s: %s", i+1, v.Name, err)
+		}
+		p.blackouts = append(p.blackouts, pd)
+	}
+	return p, nil
+}
+
+// t时刻的交易安排
+func (p *Schedule) At(t time.Time) *State {
+	t = t.In(p.loc)
+	for _, v := range p.blackouts {
+		if v.contains(t) {
+			return &State{Blackout: v.cfg.Name}
+		}
+	}
+
+	if len(p.windows) == 0 {
+		return &State{Active: true}
+	}
+	for _, v := range p.windows {
+		if v.contains(t) {
+			return &State{Active: true, Window: v.cfg}
+		}
+	}
+	return &State{}
+}
+
+// 一个时间段
+type period struct {
+	cfg *model.WindowConfig
+
+	// 每天的开始和结束时间，单位分钟
+	daily      bool
+	start, end int
+	days       map[time.Weekday]bool
+
+	cron     cron.Schedule
+	duration time.Duration
+
+	from, to time.Time
+}
+
+//
+func newPeriod(cfg *model.WindowConfig, loc *time.Location) (*period, error) {
+	var (
+		p     = &period{cfg: cfg}
+		forms int
+		err   error
+	)
+
+	if cfg.Start != "" || cfg.End != "" {
+		forms++
+		p.daily = true
+		p.start, err = minuteOfDay(cfg.Start)
+		if err != nil {
+			return nil, fmt.Errorf("start %s", err)
+		}
+		p.end, err = minuteOfDay(cfg.End)
+		if err != nil {
+			return nil, fmt.Errorf("end %s", err)
+		}
+	}
+
+	if len(cfg.Days) > 0 {
+		if !p.daily {
+			return nil, fmt.Errorf("days need start and end")
+		}
+		p.days = make(map[time.Weekday]bool)
+		for _, d := range cfg.Days {
+			wd, ok := weekdays[strings.ToLower(d)]
+			if !ok {
+				return nil, fmt.Errorf("day %s need be mon, tue, wed, thu, fri, sat or sun", d)
+			}
+			p.days[wd] = true
+		}
+	}
+
+	if cfg.Cron != "" || cfg.Duration != 0 {
+		forms++
+		if cfg.Duration <= 0 {
+			return nil, fmt.Errorf("cron need duration greater than 0")
+		}
+		p.cron, err = cron.ParseStandard(cfg.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("cron %q is invalid. %s", cfg.Cron, err)
+		}
+		p.duration = time.Duration(cfg.Duration) * time.Millisecond
+	}
+
+	if cfg.From != "" || cfg.To != "" {
+		forms++
+		p.from, err = time.ParseInLocation("2006-01-02 15:04", cfg.From, loc)
+		if err != nil {
+			return nil, fmt.Errorf("from need be 2006-01-02 15:04")
+		}
+		p.to, err = time.ParseInLocation("2006-01-02 15:04", cfg.To, loc)
+		if err != nil {
+			return nil, fmt.Errorf("to need be 2006-01-02 15:04")
+		}
+		if !p.to.After(p.from) {
+			return nil, fmt.Errorf("to need be later than from")
+		}
+	}
+
+	if forms != 1 {
+		return nil, fmt.Errorf("need exactly one of start and end, cron and duration, from and to")
+	}
+
+	// 替换后的参数需满足交易对配置的范围
+	switch {
+	case cfg.SellNumber != nil && *cfg.SellNumber <= 0:
+		return nil, fmt.Errorf("sell_number need greater than 0")
+	case cfg.MakeUpPercent != nil && (*cfg.MakeUpPercent < 0 || *cfg.MakeUpPercent > 100):
+		return nil, fmt.Errorf("makeup_percent need be 0 ~ 100")
+	case cfg.BalancePercent != nil && (*cfg.BalancePercent < 1 || *cfg.BalancePercent > 100):
+		return nil, fmt.Errorf("balance_percent need be 1 ~ 100")
+	}
+	return p, nil
+}
+
+// 15:04格式的时间转换为当天的分钟数
+func minuteOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q need be 15:04", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// t已经转换为配置的时区
+func (p *period) contains(t time.Time) bool {
+	switch {
+	case p.daily:
+		return p.containsDaily(t)
+	case p.cron != nil:
+		// 最近一次触发在duration以内
+		next := p.cron.Next(t.Add(-p.duration))
+		return !next.IsZero() && !next.After(t)
+	}
+	return !t.Before(p.from) && t.Before(p.to)
+}
+
+// 跨过午夜的时间段按开始的日期判断星期
+func (p *period) containsDaily(t time.Time) bool {
+	var (
+		m         = t.Hour()*60 + t.Minute()
+		today     = p.days == nil || p.days[t.Weekday()]
+		yesterday = p.days == nil || p.days[t.AddDate(0, 0, -1).Weekday()]
+	)
+	switch {
+	case p.start == p.end:
+		return today
+	case p.start < p.end:
+		return today && m >= p.start && m < p.end
+	}
+	return (today && m >= p.start) || (yesterday && m < p.end)
+}
+
+// 按时间段替换交易对参数，w为nil时返回原参数
+func Apply(sc *model.SymbolConfig, w *model.WindowConfig) *model.SymbolConfig {
+	if w == nil {
+		return sc
+	}
+	c := *sc
+	if w.SellNumber != nil {
+		c.SellNumber = *w.SellNumber
+	}
+	if w.ExpectValue != nil {
+		c.ExpectValue = *w.ExpectValue
+	}
+	if w.MakeUpPercent != nil {
+		c.MakeUpPercent = *w.MakeUpPercent
+	}
+	if w.BalancePercent != nil {
+		c.BalancePercent = *w.BalancePercent
+	}
+	return &c
+}
@@ -0,0 +1,179 @@
+package schedule
+
+import (
+	"fcoinExchange/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+//
+func float(v float64) *float64 {
+	return &v
+}
+
+//
+func integer(v int) *int {
+	return &v
+}
+
+// 2026-10-19为星期一
+func at(day, hour, minute int) time.Time {
+	return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+}
+
+//
+func TestPeriodContains(t *testing.T) {
+	var (
+		office = &model.WindowConfig{Start: "09:00", End: "17:00"}
+		// 星期五晚上开始，跨过午夜到星期六
+		night  = &model.WindowConfig{Start: "22:00", End: "02:00", Days: []string{"fri"}}
+		nights = &model.WindowConfig{Start: "22:00", End: "02:00"}
+		// 开始等于结束时为全天
+		weekend = &model.WindowConfig{Start: "00:00", End: "00:00", Days: []string{"Sat", "sun"}}
+		// 工作日9点开始持续2小时
+		morning = &model.WindowConfig{Cron: "0 9 * * 1-5", Duration: 2 * 3600 * 1000}
+		// 23点开始持续3小时，跨过午夜
+		late  = &model.WindowConfig{Cron: "0 23 * * *", Duration: 3 * 3600 * 1000}
+		fixed = &model.WindowConfig{From: "2026-10-20 08:00", To: "2026-10-21 08:00"}
+	)
+
+	for _, c := range []struct {
+		name string
+		cfg  *model.WindowConfig
+		t    time.Time
+		want bool
+	}{
+		{name: "daily inside", cfg: office, t: at(19, 12, 0), want: true},
+		{name: "daily start", cfg: office, t: at(19, 9, 0), want: true},
+		{name: "daily end excluded", cfg: office, t: at(19, 17, 0)},
+		{name: "daily before", cfg: office, t: at(19, 8, 59)},
+		{name: "overnight before midnight", cfg: nights, t: at(19, 23, 0), want: true},
+		{name: "overnight after midnight", cfg: nights, t: at(20, 1, 59), want: true},
+		{name: "overnight outside", cfg: nights, t: at(20, 2, 0)},
+		{name: "overnight on start day", cfg: night, t: at(23, 23, 0), want: true},
+		{name: "overnight on next day", cfg: night, t: at(24, 1, 0), want: true},
+		{name: "overnight next day evening", cfg: night, t: at(24, 23, 0)},
+		{name: "overnight morning of start day", cfg: night, t: at(23, 1, 0)},
+		{name: "whole day", cfg: weekend, t: at(24, 0, 0), want: true},
+		{name: "whole day end of day", cfg: weekend, t: at(25, 23, 59), want: true},
+		{name: "whole day other day", cfg: weekend, t: at(19, 12, 0)},
+		{name: "cron trigger time", cfg: morning, t: at(19, 9, 0), want: true},
+		{name: "cron within duration", cfg: morning, t: at(19, 10, 59), want: true},
+		{name: "cron duration elapsed", cfg: morning, t: at(19, 11, 0)},
+		{name: "cron before trigger", cfg: morning, t: at(19, 8, 59)},
+		{name: "cron not triggered on weekend", cfg: morning, t: at(24, 10, 0)},
+		{name: "cron lookback across midnight", cfg: late, t: at(20, 1, 0), want: true},
+		{name: "cron lookback elapsed", cfg: late, t: at(20, 2, 0)},
+		{name: "fixed inside", cfg: fixed, t: at(20, 8, 0), want: true},
+		{name: "fixed end excluded", cfg: fixed, t: at(21, 8, 0)},
+	} {
+		pd, err := newPeriod(c.cfg, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if got := pd.contains(c.t); got != c.want {
+			t.Errorf("%s: contains %s = %v, want %v", c.name, c.t, got, c.want)
+		}
+	}
+}
+
+//
+func TestNewInvalid(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		cfg  *model.WindowConfig
+		err  string
+	}{
+		{name: "no form", cfg: &model.WindowConfig{}, err: "need exactly one"},
+		{name: "daily and cron", cfg: &model.WindowConfig{Start: "09:00", End: "17:00", Cron: "0 9 * * *", Duration: 1000}, err: "need exactly one"},
+		{name: "daily and fixed", cfg: &model.WindowConfig{Start: "09:00", End: "17:00", From: "2026-10-20 08:00", To: "2026-10-21 08:00"}, err: "need exactly one"},
+		{name: "cron and fixed", cfg: &model.WindowConfig{Cron: "0 9 * * *", Duration: 1000, From: "2026-10-20 08:00", To: "2026-10-21 08:00"}, err: "need exactly one"},
+		{name: "days without start", cfg: &model.WindowConfig{Days: []string{"mon"}, Cron: "0 9 * * *", Duration: 1000}, err: "days need start and end"},
+		{name: "bad day", cfg: &model.WindowConfig{Start: "09:00", End: "17:00", Days: []string{"monday"}}, err: "day monday"},
+		{name: "bad start", cfg: &model.WindowConfig{Start: "9am", End: "17:00"}, err: "start"},
+		{name: "missing end", cfg: &model.WindowConfig{Start: "09:00"}, err: "end"},
+		{name: "cron without duration", cfg: &model.WindowConfig{Cron: "0 9 * * *"}, err: "duration"},
+		{name: "bad cron", cfg: &model.WindowConfig{Cron: "every day", Duration: 1000}, err: "cron"},
+		{name: "to before from", cfg: &model.WindowConfig{From: "2026-10-21 08:00", To: "2026-10-20 08:00"}, err: "later than from"},
+		{name: "zero sell number", cfg: &model.WindowConfig{Start: "09:00", End: "17:00", SellNumber: float(0)}, err: "sell_number"},
+		{name: "makeup percent", cfg: &model.WindowConfig{Start: "09:00", End: "17:00", MakeUpPercent: integer(101)}, err: "makeup_percent"},
+		{name: "balance percent", cfg: &model.WindowConfig{Start: "09:00", End: "17:00", BalancePercent: integer(0)}, err: "balance_percent"},
+	} {
+		_, err := New(&model.ScheduleConfig{Windows: []*model.WindowConfig{c.cfg}})
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: error %v, want %q", c.name, err, c.err)
+		}
+		_, err = New(&model.ScheduleConfig{Blackouts: []*model.WindowConfig{c.cfg}})
+		if err == nil || !strings.Contains(err.Error(), "blackout 1") {
+			t.Errorf("%s: blackout error %v", c.name, err)
+		}
+	}
+
+	_, err := New(&model.ScheduleConfig{TimeZone: "Mars/Olympus"})
+	if err == nil {
+		t.Errorf("invalid time_zone accepted")
+	}
+}
+
+//
+func TestScheduleAt(t *testing.T) {
+	s, err := New(&model.ScheduleConfig{
+		TimeZone: "UTC",
+		Windows: []*model.WindowConfig{
+			{Name: "asia", Start: "01:00", End: "09:00"},
+			{Name: "europe", Start: "07:00", End: "16:00"},
+		},
+		Blackouts: []*model.WindowConfig{
+			{Name: "maintenance", Cron: "0 8 * * 2", Duration: 3600 * 1000},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{name: "first window", t: at(19, 2, 0), want: "window asia"},
+		{name: "overlap uses first window", t: at(19, 8, 0), want: "window asia"},
+		{name: "second window", t: at(19, 12, 0), want: "window europe"},
+		{name: "outside windows", t: at(19, 20, 0), want: "outside windows"},
+		{name: "blackout before windows", t: at(20, 8, 30), want: "blackout maintenance"},
+		{name: "other time zone", t: at(19, 12, 0).In(time.FixedZone("UTC+8", 8*3600)), want: "window europe"},
+	} {
+		if got := s.At(c.t).String(); got != c.want {
+			t.Errorf("%s: %s, want %s", c.name, got, c.want)
+		}
+	}
+
+	s, err = New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := s.At(at(19, 12, 0)); !st.Active || st.String() != "always" {
+		t.Errorf("nil schedule: %s", st)
+	}
+}
+
+//
+func TestApply(t *testing.T) {
+	sc := &model.SymbolConfig{Symbol: "btcusdt", SellNumber: 1, ExpectValue: 0.5, MakeUpPercent: 50, BalancePercent: 80}
+
+	if got := Apply(sc, nil); got != sc {
+		t.Errorf("nil window returned a copy")
+	}
+
+	got := Apply(sc, &model.WindowConfig{SellNumber: float(2), ExpectValue: float(0), BalancePercent: integer(100)})
+	want := model.SymbolConfig{Symbol: "btcusdt", SellNumber: 2, ExpectValue: 0, MakeUpPercent: 50, BalancePercent: 100}
+	if got.Symbol != want.Symbol || got.SellNumber != want.SellNumber || got.ExpectValue != want.ExpectValue ||
+		got.MakeUpPercent != want.MakeUpPercent || got.BalancePercent != want.BalancePercent {
+		t.Errorf("applied %+v, want %+v", got, want)
+	}
+	if sc.SellNumber != 1 || sc.ExpectValue != 0.5 || sc.BalancePercent != 80 {
+		t.Errorf("symbol config changed: %+v", sc)
+	}
+}
@@ -0,0 +1,168 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+var (
+	defaultPassphraseEnv string = "FCOIN_KEYSTORE_PASSPHRASE"
+)
+
+// 加密后的密钥文件内容
+type keystoreFile struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	CipherText []byte `json:"ciphertext"`
+}
+
+// 通过口令解密的密钥文件，口令从环境变量读取，未设置时在终端输入
+type Keystore struct {
+	Path          string
+	PassphraseEnv string
+}
+
+//
+func (p *Keystore) Name() string {
+	return KeystoreSource
+}
+
+//
+func (p *Keystore) Load() (*Credential, error) {
+	data, err := readPrivateFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase, err := p.passphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	return Open(data, passphrase)
+}
+
+//
+func (p *Keystore) passphrase() ([]byte, error) {
+	env := p.PassphraseEnv
+	if env == "" {
+		env = defaultPassphraseEnv
+	}
+
+	if s := os.Getenv(env); s != "" {
+		return []byte(s), nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("keystore passphrase not found in %s and stdin is not a terminal", env)
+	}
+
+	fmt.Printf("keystore passphrase: ")
+	defer fmt.Printf("\n")
+	return term.ReadPassword(int(os.Stdin.Fd()))
+}
+
+// 使用口令加密密钥，返回的数据可以直接写入密钥文件
+func Seal(c *Credential, passphrase []byte) ([]byte, error) {
+	var ks = new(keystoreFile)
+
+	ks.Salt = make([]byte, 16)
+	_, err := io.ReadFull(rand.Reader, ks.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(passphrase, ks.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.Nonce = make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, ks.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.CipherText = aead.Seal(nil, ks.Nonce, plain, nil)
+	return json.Marshal(ks)
+}
+
+// 使用口令解密密钥文件
+func Open(data, passphrase []byte) (*Credential, error) {
+	var ks = new(keystoreFile)
+	err := json.Unmarshal(data, ks)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(passphrase, ks.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ks.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("keystore nonce length wrong")
+	}
+
+	plain, err := aead.Open(nil, ks.Nonce, ks.CipherText, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt keystore failed, wrong passphrase or damaged file")
+	}
+
+	var c = new(Credential)
+	err = json.Unmarshal(plain, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+//
+func newAEAD(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// 加密密钥并写入密钥文件，文件权限为0600，文件已存在时返回错误
+func WriteKeystore(path string, c *Credential, passphrase []byte) error {
+	data, err := Seal(c, passphrase)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//
+func TestSealOpen(t *testing.T) {
+	c := &Credential{AppKey: "key", AppSecret: "secret"}
+	data, err := Seal(c, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("keystore contains plain secret: %s", data)
+	}
+
+	for _, v := range []struct {
+		name       string
+		data       []byte
+		passphrase string
+		err        string
+	}{
+		{name: "right passphrase", data: data, passphrase: "passphrase"},
+		{name: "wrong passphrase", data: data, passphrase: "wrong", err: "wrong passphrase"},
+		{name: "damaged", data: []byte(strings.Replace(string(data), `"ciphertext":"`, `"ciphertext":"AAAA`, 1)), passphrase: "passphrase", err: "damaged"},
+		{name: "not json", data: []byte("appkey: key"), passphrase: "passphrase", err: "invalid"},
+	} {
+		got, err := Open(v.data, []byte(v.passphrase))
+		if v.err != "" {
+			if err == nil || !strings.Contains(err.Error(), v.err) {
+				t.Errorf("%s: error %v, want %q", v.name, err, v.err)
+			}
+			continue
+		}
+		if err != nil || *got != *c {
+			t.Errorf("%s: %v, %v", v.name, got, err)
+		}
+	}
+}
+
+//
+func TestKeystoreLoad(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "fcoin.keystore")
+		c    = &Credential{AppKey: "key", AppSecret: "secret"}
+	)
+	err := WriteKeystore(path, c, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 已存在的文件不覆盖
+	if err := WriteKeystore(path, c, []byte("other")); err == nil {
+		t.Errorf("existing keystore overwritten")
+	}
+
+	t.Setenv("TEST_KEYSTORE_PASSPHRASE", "passphrase")
+	ks := &Keystore{Path: path, PassphraseEnv: "TEST_KEYSTORE_PASSPHRASE"}
+	got, err := Load(ks)
+	if err != nil || *got != *c {
+		t.Errorf("load = %v, %v", got, err)
+	}
+
+	t.Setenv("TEST_KEYSTORE_PASSPHRASE", "wrong")
+	if _, err := Load(ks); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("load with wrong passphrase: %v", err)
+	}
+
+	// 同组或者其他用户可以访问时拒绝读取
+	t.Setenv("TEST_KEYSTORE_PASSPHRASE", "passphrase")
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(ks); err == nil || !strings.Contains(err.Error(), "accessible by group or others") {
+		t.Errorf("load group readable keystore: %v", err)
+	}
+}
+
+//
+func TestReadPrivateFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, v := range []struct {
+		mode os.FileMode
+		ok   bool
+	}{
+		{mode: 0600, ok: true},
+		{mode: 0400, ok: true},
+		{mode: 0640},
+		{mode: 0604},
+		{mode: 0660},
+	} {
+		path := filepath.Join(dir, v.mode.String())
+		if err := ioutil.WriteFile(path, []byte("data"), v.mode); err != nil {
+			t.Fatal(err)
+		}
+		// 不受umask影响
+		if err := os.Chmod(path, v.mode); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readPrivateFile(path)
+		if (err == nil) != v.ok {
+			t.Errorf("mode %04o: error %v, want ok %v", v.mode, err, v.ok)
+		}
+	}
+
+	if _, err := readPrivateFile(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("missing file read")
+	}
+}
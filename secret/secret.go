@@ -0,0 +1,87 @@
+package secret
+
+import (
+	"fcoinExchange/model"
+	"fmt"
+)
+
+const (
+	ConfigSource   string = "config"
+	EnvSource      string = "env"
+	FileSource     string = "file"
+	KeystoreSource string = "keystore"
+	CommandSource  string = "command"
+)
+
+// 账户密钥
+type Credential struct {
+	AppKey    string `yaml:"appkey" json:"appkey"`
+	AppSecret string `yaml:"appsecret" json:"appsecret"`
+}
+
+// 打印时隐藏密钥内容
+func (p *Credential) String() string {
+	return fmt.Sprintf("{AppKey:%s AppSecret:%s}", model.Redact(p.AppKey), model.Redact(p.AppSecret))
+}
+
+//
+func (p *Credential) GoString() string {
+	return p.String()
+}
+
+// 密钥来源
+type Source interface {
+	Name() string
+	Load() (*Credential, error)
+}
+
+// 根据配置创建密钥来源，来源为config时直接使用配置文件中的appkey和appsecret
+func NewSource(cfg *model.CredentialConfig, appKey, appSecret string) (Source, error) {
+	switch cfg.Source {
+	case "", ConfigSource:
+		return &Static{Credential: Credential{AppKey: appKey, AppSecret: appSecret}}, nil
+	case EnvSource:
+		return NewEnv(cfg.KeyEnv, cfg.SecretEnv), nil
+	case FileSource:
+		return &File{Path: cfg.Path}, nil
+	case KeystoreSource:
+		return &Keystore{Path: cfg.Path, PassphraseEnv: cfg.PassphraseEnv}, nil
+	case CommandSource:
+		if len(cfg.Command) == 0 {
+			return nil, fmt.Errorf("credential command is empty")
+		}
+		return &Command{Args: cfg.Command}, nil
+	default:
+		return nil, fmt.Errorf("unknown credential source %s", cfg.Source)
+	}
+}
+
+// 从来源中读取密钥并检查是否完整
+func Load(src Source) (*Credential, error) {
+	c, err := src.Load()
+	if err != nil {
+		return nil, fmt.Errorf("load credential from %s failed. %s", src.Name(), err)
+	}
+
+	if c.AppKey == "" || c.AppSecret == "" {
+		return nil, fmt.Errorf("credential from %s is incomplete", src.Name())
+	}
+
+	return c, nil
+}
+
+// 配置文件中的密钥
+type Static struct {
+	Credential Credential
+}
+
+//
+func (p *Static) Name() string {
+	return ConfigSource
+}
+
+//
+func (p *Static) Load() (*Credential, error) {
+	c := p.Credential
+	return &c, nil
+}
@@ -0,0 +1,109 @@
+package secret
+
+import (
+	"fcoinExchange/model"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//
+func TestNewSource(t *testing.T) {
+	for _, v := range []struct {
+		cfg  model.CredentialConfig
+		name string
+		err  bool
+	}{
+		{cfg: model.CredentialConfig{}, name: ConfigSource},
+		{cfg: model.CredentialConfig{Source: EnvSource}, name: EnvSource},
+		{cfg: model.CredentialConfig{Source: FileSource, Path: "key.yaml"}, name: FileSource},
+		{cfg: model.CredentialConfig{Source: KeystoreSource, Path: "fcoin.keystore"}, name: KeystoreSource},
+		{cfg: model.CredentialConfig{Source: CommandSource, Command: []string{"pass"}}, name: CommandSource},
+		{cfg: model.CredentialConfig{Source: CommandSource}, err: true},
+		{cfg: model.CredentialConfig{Source: "vault"}, err: true},
+	} {
+		src, err := NewSource(&v.cfg, "key", "secret")
+		if v.err {
+			if err == nil {
+				t.Errorf("%q: source created", v.cfg.Source)
+			}
+			continue
+		}
+		if err != nil || src.Name() != v.name {
+			t.Errorf("%q: source %v, %v, want %s", v.cfg.Source, src, err, v.name)
+		}
+	}
+}
+
+//
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "key.yaml")
+	if err := ioutil.WriteFile(file, []byte("appkey: file-key\nappsecret: file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_APPKEY", "env-key")
+	t.Setenv("TEST_APPSECRET", "env-secret")
+
+	for _, v := range []struct {
+		name string
+		src  Source
+		want Credential
+		err  string
+	}{
+		{name: "config", src: &Static{Credential: Credential{AppKey: "key", AppSecret: "secret"}}, want: Credential{AppKey: "key", AppSecret: "secret"}},
+		{name: "incomplete", src: &Static{Credential: Credential{AppKey: "key"}}, err: "incomplete"},
+		{name: "env", src: NewEnv("TEST_APPKEY", "TEST_APPSECRET"), want: Credential{AppKey: "env-key", AppSecret: "env-secret"}},
+		{name: "env missing", src: NewEnv("TEST_APPKEY", "TEST_MISSING"), err: "incomplete"},
+		{name: "file", src: &File{Path: file}, want: Credential{AppKey: "file-key", AppSecret: "file-secret"}},
+		{name: "file missing", src: &File{Path: filepath.Join(dir, "missing.yaml")}, err: "no such file"},
+		{
+			name: "command", src: &Command{Args: []string{"sh", "-c", "echo appkey: cmd-key; echo appsecret: cmd-secret"}},
+			want: Credential{AppKey: "cmd-key", AppSecret: "cmd-secret"},
+		},
+		{name: "command failed", src: &Command{Args: []string{"sh", "-c", "echo locked >&2; exit 1"}}, err: "locked"},
+	} {
+		c, err := Load(v.src)
+		if v.err != "" {
+			if err == nil || !strings.Contains(err.Error(), v.err) {
+				t.Errorf("%s: error %v, want %q", v.name, err, v.err)
+			}
+			continue
+		}
+		if err != nil || *c != v.want {
+			t.Errorf("%s: %v, %v", v.name, c, err)
+		}
+	}
+}
+
+// 命令超过commandTimeout时结束命令并返回错误
+func TestCommandTimeout(t *testing.T) {
+	timeout := commandTimeout
+	commandTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { commandTimeout = timeout })
+
+	start := time.Now()
+	_, err := (&Command{Args: []string{"sleep", "10"}}).Load()
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("error %v, want timeout", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("command killed after %s", d)
+	}
+}
+
+// 打印时不输出密钥
+func TestCredentialString(t *testing.T) {
+	c := &Credential{AppKey: "0123456789abcdef", AppSecret: "fedcba9876543210"}
+	for _, s := range []string{c.String(), fmt.Sprintf("%v", c), fmt.Sprintf("%+v", c), fmt.Sprintf("%#v", c)} {
+		if strings.Contains(s, "0123") || strings.Contains(s, "fedc") {
+			t.Errorf("credential printed as %s", s)
+		}
+	}
+	if s := (&Credential{AppKey: "key"}).String(); s != "{AppKey:****** AppSecret:}" {
+		t.Errorf("credential printed as %s", s)
+	}
+}
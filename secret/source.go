@@ -0,0 +1,137 @@
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	defaultKeyEnv    string = "FCOIN_APPKEY"
+	defaultSecretEnv string = "FCOIN_APPSECRET"
+
+	commandTimeout = 10 * time.Second
+)
+
+// 从环境变量中读取密钥
+type Env struct {
+	KeyEnv    string
+	SecretEnv string
+}
+
+//
+func NewEnv(keyEnv, secretEnv string) *Env {
+	if keyEnv == "" {
+		keyEnv = defaultKeyEnv
+	}
+	if secretEnv == "" {
+		secretEnv = defaultSecretEnv
+	}
+	return &Env{KeyEnv: keyEnv, SecretEnv: secretEnv}
+}
+
+//
+func (p *Env) Name() string {
+	return EnvSource
+}
+
+//
+func (p *Env) Load() (*Credential, error) {
+	return &Credential{
+		AppKey:    os.Getenv(p.KeyEnv),
+		AppSecret: os.Getenv(p.SecretEnv),
+	}, nil
+}
+
+// 从单独的yaml文件中读取密钥，文件不允许同组和其他用户访问
+type File struct {
+	Path string
+}
+
+//
+func (p *File) Name() string {
+	return FileSource
+}
+
+//
+func (p *File) Load() (*Credential, error) {
+	data, err := readPrivateFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c = new(Credential)
+	err = yaml.Unmarshal(data, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// 通过外部命令获取密钥，命令输出格式与密钥文件相同
+type Command struct {
+	Args []string
+}
+
+//
+func (p *Command) Name() string {
+	return CommandSource
+}
+
+//
+func (p *Command) Load() (*Credential, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(p.Args[0], p.Args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(commandTimeout):
+		cmd.Process.Kill()
+		return nil, fmt.Errorf("command %s timeout", p.Args[0])
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("command %s failed. %s %s", p.Args[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	var c = new(Credential)
+	err = yaml.Unmarshal(stdout.Bytes(), c)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// 读取文件前检查文件权限
+func readPrivateFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("%s is accessible by group or others (mode %04o), need 0600 or stricter", path, info.Mode().Perm())
+	}
+
+	return ioutil.ReadFile(path)
+}
@@ -0,0 +1,554 @@
+package venue
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fcoinExchange/fcoin"
+	"fcoinExchange/model"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	binanceBaseUrl = "https://api.binance.com"
+
+	// 签名请求的有效时间，单位毫秒
+	binanceRecvWindow = 5000
+	// 查询订单列表时每次返回的最大数量
+	binanceOrderLimit = 1000
+	// 不能确定请求是否已经被执行的错误码，与HTTP状态码无关，
+	// -1000未知错误，-1006返回了意外的响应，-1007等待后端响应超时
+	binanceAmbiguousCodes = map[int]bool{-1000: true, -1006: true, -1007: true}
+
+	// 地址 -> 时钟，使用同一地址的账户共用一个时钟
+	binanceClocks   = make(map[string]*fcoin.Clock)
+	binanceClocksMu sync.Mutex
+)
+
+// Binance兼容的REST API的适配。
+// 交易对名称转换为小写后与fcoin保持一致，例如BTCUSDT对应btcusdt，
+// 订单状态转换为fcoin的状态
+type Binance struct {
+	client    *http.Client
+	baseUrl   string
+	appKey    string
+	appSecret []byte
+	clock     *fcoin.Clock
+
+	sync.RWMutex
+	// 交易所使用的交易对名称，key为小写的交易对名称
+	symbols map[string]string
+}
+
+// baseUrl为空时使用币安的地址，to为请求超时时间，单位毫秒
+func NewBinance(baseUrl, key, secret string, to int) *Binance {
+	if baseUrl == "" {
+		baseUrl = binanceBaseUrl
+	}
+	baseUrl = strings.TrimRight(baseUrl, "/")
+	return &Binance{
+		client: &http.Client{
+			Timeout: time.Duration(to) * time.Millisecond,
+		},
+		baseUrl:   baseUrl,
+		appKey:    key,
+		appSecret: []byte(secret),
+		clock:     binanceClock(baseUrl),
+	}
+}
+
+//
+func binanceClock(baseUrl string) *fcoin.Clock {
+	binanceClocksMu.Lock()
+	defer binanceClocksMu.Unlock()
+	c, ok := binanceClocks[baseUrl]
+	if !ok {
+		c = fcoin.NewClock()
+		binanceClocks[baseUrl] = c
+	}
+	return c
+}
+
+//
+func (p *Binance) Name() string {
+	return "binance"
+}
+
+// 替换发送请求使用的RoundTripper
+func (p *Binance) SetTransport(rt http.RoundTripper) {
+	p.client.Transport = rt
+}
+
+// HMAC-SHA256签名，返回十六进制字符串
+func (p *Binance) Signature(msg string) string {
+	sh := hmac.New(sha256.New, p.appSecret)
+	sh.Write([]byte(msg))
+	return hex.EncodeToString(sh.Sum(nil))
+}
+
+// 发送请求并解析返回，signed为true时在参数中加入时间戳和签名
+func (p *Binance) do(method, path string, params url.Values, signed bool, v interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	if signed {
+		params.Set("recvWindow", strconv.Itoa(binanceRecvWindow))
+		params.Set("timestamp", strconv.FormatInt(p.clock.Millis(), 10))
+	}
+
+	query := params.Encode()
+	if signed {
+		query = fmt.Sprintf("%s&signature=%s", query, p.Signature(query))
+	}
+
+	requrl := p.baseUrl + path
+	if query != "" {
+		requrl = fmt.Sprintf("%s?%s", requrl, query)
+	}
+
+	req, err := http.NewRequest(method, requrl, nil)
+	if err != nil {
+		return err
+	}
+	if signed {
+		req.Header.Set("X-MBX-APIKEY", p.appKey)
+	}
+
+	// 请求发出后的网络错误和服务器内部错误不能确定请求是否已经被处理
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// 请求地址中包含签名，不在错误中输出
+		if ue, ok := err.(*url.Error); ok {
+			err = fmt.Errorf("%s %s: %s", method, path, ue.Err)
+		}
+		return &AmbiguousError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return &AmbiguousError{Err: err}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Msg != "" {
+			err = fmt.Errorf("%s %s return status %d, code %d, %s", method, path, resp.StatusCode, e.Code, e.Msg)
+		} else {
+			err = fmt.Errorf("%s %s return status %d", method, path, resp.StatusCode)
+		}
+		// 其他错误码表示请求被拒绝，例如-2010下单被拒绝、-1013参数不符合过滤器、-1021时间戳超出recvWindow
+		if binanceAmbiguousCodes[e.Code] || resp.StatusCode >= http.StatusInternalServerError {
+			return &AmbiguousError{Err: err}
+		}
+		return err
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return &AmbiguousError{Err: err}
+	}
+	return nil
+}
+
+// 查询交易对元数据，价格和数量精度由PRICE_FILTER和LOT_SIZE计算。
+// 只返回状态为TRADING的交易对，暂停和下线的交易对仍记录名称用于查询历史订单
+func (p *Binance) Symbols() ([]*model.Symbol, error) {
+	var info struct {
+		Symbols []struct {
+			Symbol     string `json:"symbol"`
+			Status     string `json:"status"`
+			BaseAsset  string `json:"baseAsset"`
+			QuoteAsset string `json:"quoteAsset"`
+			Filters    []struct {
+				FilterType string `json:"filterType"`
+				TickSize   string `json:"tickSize"`
+				StepSize   string `json:"stepSize"`
+			} `json:"filters"`
+		} `json:"symbols"`
+	}
+	err := p.do("GET", "/api/v3/exchangeInfo", nil, false, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		list    = make([]*model.Symbol, 0, len(info.Symbols))
+		symbols = make(map[string]string)
+	)
+	for _, v := range info.Symbols {
+		s := &model.Symbol{
+			Name:          strings.ToLower(v.Symbol),
+			BaseCurrency:  strings.ToLower(v.BaseAsset),
+			QuoteCurrency: strings.ToLower(v.QuoteAsset),
+		}
+		for _, f := range v.Filters {
+			switch f.FilterType {
+			case "PRICE_FILTER":
+				s.PriceDecimal = decimals(f.TickSize)
+			case "LOT_SIZE":
+				s.AmountDecimal = decimals(f.StepSize)
+			}
+		}
+		symbols[s.Name] = v.Symbol
+		if v.Status != "TRADING" {
+			continue
+		}
+		list = append(list, s)
+	}
+
+	p.Lock()
+	p.symbols = symbols
+	p.Unlock()
+
+	return list, nil
+}
+
+// 步长的小数位数，例如0.00100000为3
+func decimals(step string) int {
+	step = strings.TrimRight(step, "0")
+	i := strings.Index(step, ".")
+	if i < 0 {
+		return 0
+	}
+	return len(step) - i - 1
+}
+
+// 交易所使用的交易对名称，未查询过元数据时转换为大写
+func (p *Binance) symbol(symbol string) string {
+	p.RLock()
+	defer p.RUnlock()
+	if s, ok := p.symbols[symbol]; ok {
+		return s
+	}
+	return strings.ToUpper(symbol)
+}
+
+// 只有买一卖一，其他字段为0
+func (p *Binance) Ticker(symbol string) (*model.Quote, error) {
+	var t struct {
+		BidPrice string `json:"bidPrice"`
+		BidQty   string `json:"bidQty"`
+		AskPrice string `json:"askPrice"`
+		AskQty   string `json:"askQty"`
+	}
+	err := p.do("GET", "/api/v3/ticker/bookTicker", url.Values{"symbol": {p.symbol(symbol)}}, false, &t)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		q    = &model.Quote{Type: fmt.Sprintf("ticker.%s", symbol)}
+		errs = make([]error, 0)
+	)
+	for _, v := range []struct {
+		s string
+		f *float64
+	}{
+		{t.BidPrice, &q.MaxBuyOnePrice},
+		{t.BidQty, &q.MaxBuyNumber},
+		{t.AskPrice, &q.MinSellOnePrice},
+		{t.AskQty, &q.MinSellNumber},
+	} {
+		f, err := strconv.ParseFloat(v.s, 64)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		*v.f = f
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("parse %s book ticker failed. %s", symbol, errs[0])
+	}
+
+	// 没有最新成交价，使用买一卖一的中间价
+	q.LastestPrice = (q.MaxBuyOnePrice + q.MinSellOnePrice) / 2
+	return q, nil
+}
+
+// limit为0时使用交易所的默认档位数量
+func (p *Binance) Depth(symbol string, limit int) (*Depth, error) {
+	var params = url.Values{"symbol": {p.symbol(symbol)}}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	var dp struct {
+		Bids [][]string `json:"bids"`
+		Asks [][]string `json:"asks"`
+	}
+	err := p.do("GET", "/api/v3/depth", params, false, &dp)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Depth{Symbol: symbol, Time: time.Now()}
+	d.Bids, err = binanceLevels(dp.Bids, limit)
+	if err != nil {
+		return nil, err
+	}
+	d.Asks, err = binanceLevels(dp.Asks, limit)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// [价格, 数量]转换为档位
+func binanceLevels(list [][]string, limit int) ([]Level, error) {
+	var levels = make([]Level, 0, len(list))
+	for _, v := range list {
+		if limit > 0 && len(levels) >= limit {
+			break
+		}
+		if len(v) < 2 {
+			return nil, fmt.Errorf("depth level %v is invalid", v)
+		}
+		price, err := strconv.ParseFloat(v[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := strconv.ParseFloat(v[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, Level{Price: price, Amount: amount})
+	}
+	return levels, nil
+}
+
+// 币种转换为小写
+func (p *Binance) Balances() ([]*model.BalanceContext, error) {
+	var account struct {
+		Balances []struct {
+			Asset  string `json:"asset"`
+			Free   string `json:"free"`
+			Locked string `json:"locked"`
+		} `json:"balances"`
+	}
+	err := p.do("GET", "/api/v3/account", nil, true, &account)
+	if err != nil {
+		return nil, err
+	}
+
+	var list = make([]*model.BalanceContext, 0, len(account.Balances))
+	for _, v := range account.Balances {
+		free, err := strconv.ParseFloat(v.Free, 64)
+		if err != nil {
+			return nil, err
+		}
+		locked, err := strconv.ParseFloat(v.Locked, 64)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, &model.BalanceContext{
+			Currency:  strings.ToLower(v.Asset),
+			Available: v.Free,
+			Frozen:    v.Locked,
+			Balance:   strconv.FormatFloat(free+locked, 'f', -1, 64),
+		})
+	}
+	return list, nil
+}
+
+// 交易所返回的订单
+type binanceOrder struct {
+	Symbol              string `json:"symbol"`
+	OrderId             int64  `json:"orderId"`
+	Price               string `json:"price"`
+	OrigQty             string `json:"origQty"`
+	ExecutedQty         string `json:"executedQty"`
+	CummulativeQuoteQty string `json:"cummulativeQuoteQty"`
+	Status              string `json:"status"`
+	Type                string `json:"type"`
+	Side                string `json:"side"`
+	Time                int64  `json:"time"`
+	TransactTime        int64  `json:"transactTime"`
+	ClientOrderId       string `json:"clientOrderId"`
+}
+
+// 转换为fcoin格式的订单
+func (p *binanceOrder) info() *model.OrderInfo {
+	created := p.Time
+	if created == 0 {
+		created = p.TransactTime
+	}
+	return &model.OrderInfo{
+		Id:            strconv.FormatInt(p.OrderId, 10),
+		Symbol:        strings.ToLower(p.Symbol),
+		Type:          strings.ToLower(p.Type),
+		Side:          strings.ToLower(p.Side),
+		Price:         p.Price,
+		Amount:        p.OrigQty,
+		State:         binanceState(p.Status, p.ExecutedQty),
+		ExecutedValue: p.CummulativeQuoteQty,
+		FilledAmount:  p.ExecutedQty,
+		CreatedAt:     created,
+		Source:        "api",
+		ClientOrderId: p.ClientOrderId,
+	}
+}
+
+// 订单状态转换为fcoin的状态，撤销和过期的订单有成交时为partial_canceled
+func binanceState(status, executed string) string {
+	switch status {
+	case "NEW", "PENDING_NEW":
+		return "submitted"
+	case "PARTIALLY_FILLED":
+		return "partial_filled"
+	case "FILLED":
+		return "filled"
+	case "CANCELED", "PENDING_CANCEL", "REJECTED", "EXPIRED":
+		if f, _ := strconv.ParseFloat(executed, 64); f > 0 {
+			return "partial_canceled"
+		}
+		return "canceled"
+	}
+	return strings.ToLower(status)
+}
+
+// IOC和FOK使用交易所的timeInForce，post-only使用LIMIT_MAKER，市价买单按quote currency数量下单
+func (p *Binance) PlaceOrder(req *OrderRequest) (string, error) {
+	err := req.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	var params = url.Values{
+		"symbol": {p.symbol(req.Symbol)},
+		"side":   {strings.ToUpper(req.Side)},
+	}
+	switch {
+	case req.Type == TypeMarket:
+		params.Set("type", "MARKET")
+		if req.Side == "buy" {
+			params.Set("quoteOrderQty", req.Amount)
+		} else {
+			params.Set("quantity", req.Amount)
+		}
+	case req.PostOnly:
+		params.Set("type", "LIMIT_MAKER")
+		params.Set("price", req.Price)
+		params.Set("quantity", req.Amount)
+	default:
+		tif := req.TimeInForce
+		if tif == "" {
+			tif = GTC
+		}
+		params.Set("type", "LIMIT")
+		params.Set("timeInForce", tif)
+		params.Set("price", req.Price)
+		params.Set("quantity", req.Amount)
+	}
+	if req.ClientOrderId != "" {
+		params.Set("newClientOrderId", req.ClientOrderId)
+	}
+
+	var o binanceOrder
+	err = p.do("POST", "/api/v3/order", params, true, &o)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(o.OrderId, 10), nil
+}
+
+//
+func (p *Binance) CancelOrder(symbol, id string) error {
+	var o binanceOrder
+	return p.do("DELETE", "/api/v3/order", url.Values{
+		"symbol":  {p.symbol(symbol)},
+		"orderId": {id},
+	}, true, &o)
+}
+
+//
+func (p *Binance) Order(symbol, id string) (*model.OrderInfo, error) {
+	var o binanceOrder
+	err := p.do("GET", "/api/v3/order", url.Values{
+		"symbol":  {p.symbol(symbol)},
+		"orderId": {id},
+	}, true, &o)
+	if err != nil {
+		return nil, err
+	}
+	return o.info(), nil
+}
+
+//
+func (p *Binance) OpenOrders(symbol string) ([]*model.OrderInfo, error) {
+	var orders []*binanceOrder
+	err := p.do("GET", "/api/v3/openOrders", url.Values{"symbol": {p.symbol(symbol)}}, true, &orders)
+	if err != nil {
+		return nil, err
+	}
+
+	var list = make([]*model.OrderInfo, 0, len(orders))
+	for _, v := range orders {
+		list = append(list, v.info())
+	}
+	return list, nil
+}
+
+// 从since开始查询，每次最多返回1000个订单，按订单号翻页
+func (p *Binance) RecentOrders(symbol string, since time.Time) ([]*model.OrderInfo, error) {
+	var (
+		list   = make([]*model.OrderInfo, 0)
+		params = url.Values{
+			"symbol":    {p.symbol(symbol)},
+			"startTime": {strconv.FormatInt(since.UnixNano()/int64(time.Millisecond), 10)},
+			"limit":     {strconv.Itoa(binanceOrderLimit)},
+		}
+	)
+	for {
+		var orders []*binanceOrder
+		err := p.do("GET", "/api/v3/allOrders", params, true, &orders)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range orders {
+			list = append(list, v.info())
+		}
+		if len(orders) < binanceOrderLimit {
+			return list, nil
+		}
+
+		// 有orderId时忽略startTime，从最后一个订单之后继续
+		params.Del("startTime")
+		params.Set("orderId", strconv.FormatInt(orders[len(orders)-1].OrderId+1, 10))
+	}
+}
+
+// 未完成的订单使用相同的newClientOrderId时交易所拒绝下单
+func (p *Binance) DedupClientOrderId() bool {
+	return true
+}
+
+// 签名使用同步后的时间，未同步时为本地时间
+func (p *Binance) Clock() Clock {
+	return p.clock
+}
+
+// 通过/api/v3/time同步时钟，避免本地时间偏差超过recvWindow时请求被拒绝
+func (p *Binance) SyncClock(samples int) error {
+	return p.clock.SyncWith(func() (int64, error) {
+		var st struct {
+			ServerTime int64 `json:"serverTime"`
+		}
+		err := p.do("GET", "/api/v3/time", nil, false, &st)
+		if err != nil {
+			return 0, err
+		}
+		return st.ServerTime, nil
+	}, samples)
+}
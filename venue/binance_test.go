@@ -0,0 +1,304 @@
+package venue
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 记录收到的请求并按路径返回固定内容的币安接口
+type binanceServer struct {
+	*httptest.Server
+
+	sync.Mutex
+	requests []*http.Request
+	// 路径 -> 返回内容
+	routes map[string]binanceRoute
+}
+
+//
+type binanceRoute struct {
+	status int
+	body   string
+}
+
+//
+func newBinanceServer(t *testing.T) *binanceServer {
+	s := &binanceServer{routes: make(map[string]binanceRoute)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Lock()
+		s.requests = append(s.requests, r)
+		route, ok := s.routes[r.Method+" "+r.URL.Path]
+		s.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(route.status)
+		fmt.Fprint(w, route.body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+//
+func (p *binanceServer) route(method, path string, status int, body string) {
+	p.Lock()
+	defer p.Unlock()
+	p.routes[method+" "+path] = binanceRoute{status: status, body: body}
+}
+
+//
+func (p *binanceServer) last() *http.Request {
+	p.Lock()
+	defer p.Unlock()
+	return p.requests[len(p.requests)-1]
+}
+
+// 签名是对签名参数之前的完整查询字符串的HMAC-SHA256
+func TestBinanceSignedRequest(t *testing.T) {
+	s := newBinanceServer(t)
+	s.route("GET", "/api/v3/account", http.StatusOK, `{"balances":[]}`)
+	b := NewBinance(s.URL, "my-key", "my-secret", 1000)
+
+	_, err := b.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.last()
+	if k := r.Header.Get("X-MBX-APIKEY"); k != "my-key" {
+		t.Errorf("X-MBX-APIKEY = %q", k)
+	}
+	raw := r.URL.RawQuery
+	i := strings.LastIndex(raw, "&signature=")
+	if i < 0 {
+		t.Fatalf("query %q is not signed", raw)
+	}
+	mac := hmac.New(sha256.New, []byte("my-secret"))
+	mac.Write([]byte(raw[:i]))
+	if want := hex.EncodeToString(mac.Sum(nil)); raw[i+len("&signature="):] != want {
+		t.Errorf("signature = %s, want %s", raw[i+len("&signature="):], want)
+	}
+	q := r.URL.Query()
+	if q.Get("timestamp") == "" || q.Get("recvWindow") != "5000" {
+		t.Errorf("signed query %q has no timestamp or recvWindow", raw)
+	}
+
+	// 行情接口不签名
+	s.route("GET", "/api/v3/ticker/bookTicker", http.StatusOK, `{"bidPrice":"1","bidQty":"1","askPrice":"2","askQty":"1"}`)
+	_, err = b.Ticker("btcusdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := s.last(); r.Header.Get("X-MBX-APIKEY") != "" || r.URL.Query().Get("signature") != "" {
+		t.Errorf("public request %s is signed", r.URL)
+	}
+}
+
+//
+func TestBinanceTickerAndDepth(t *testing.T) {
+	s := newBinanceServer(t)
+	s.route("GET", "/api/v3/ticker/bookTicker", http.StatusOK,
+		`{"symbol":"BTCUSDT","bidPrice":"6478.86000000","bidQty":"0.38610000","askPrice":"6478.88000000","askQty":"1.22370000"}`)
+	s.route("GET", "/api/v3/depth", http.StatusOK,
+		`{"lastUpdateId":1027024,"bids":[["4.00000000","431.00000000"],["3.90000000","12.00000000"],["3.80000000","1.00000000"]],"asks":[["4.00000200","12.00000000"]]}`)
+	b := NewBinance(s.URL, "key", "secret", 1000)
+
+	q, err := b.Ticker("btcusdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.last().URL.Query().Get("symbol"); got != "BTCUSDT" {
+		t.Errorf("ticker symbol = %s", got)
+	}
+	if q.MaxBuyOnePrice != 6478.86 || q.MaxBuyNumber != 0.3861 || q.MinSellOnePrice != 6478.88 || q.MinSellNumber != 1.2237 {
+		t.Errorf("ticker = %+v", q)
+	}
+	if q.LastestPrice != 6478.87 || q.Type != "ticker.btcusdt" {
+		t.Errorf("ticker price %v type %s", q.LastestPrice, q.Type)
+	}
+
+	d, err := b.Depth("btcusdt", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.last().URL.Query().Get("limit"); got != "2" {
+		t.Errorf("depth limit = %s", got)
+	}
+	if len(d.Bids) != 2 || d.Bids[0] != (Level{Price: 4, Amount: 431}) || d.Bids[1] != (Level{Price: 3.9, Amount: 12}) {
+		t.Errorf("bids = %+v", d.Bids)
+	}
+	if len(d.Asks) != 1 || d.Asks[0] != (Level{Price: 4.000002, Amount: 12}) {
+		t.Errorf("asks = %+v", d.Asks)
+	}
+
+	s.route("GET", "/api/v3/ticker/bookTicker", http.StatusOK, `{"bidPrice":"","bidQty":"1","askPrice":"2","askQty":"1"}`)
+	if _, err := b.Ticker("btcusdt"); err == nil {
+		t.Errorf("malformed ticker parsed")
+	}
+}
+
+// 币种转换为小写，余额为可用和冻结之和
+func TestBinanceBalances(t *testing.T) {
+	s := newBinanceServer(t)
+	s.route("GET", "/api/v3/account", http.StatusOK, `{"balances":[
+		{"asset":"BTC","free":"4723846.89208129","locked":"0.00000000"},
+		{"asset":"USDT","free":"1.5","locked":"2.25"}]}`)
+	b := NewBinance(s.URL, "key", "secret", 1000)
+
+	list, err := b.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("%d balances", len(list))
+	}
+	if v := list[0]; v.Currency != "btc" || v.Available != "4723846.89208129" || v.Frozen != "0.00000000" || v.Balance != "4723846.89208129" {
+		t.Errorf("btc balance = %+v", v)
+	}
+	if v := list[1]; v.Currency != "usdt" || v.Available != "1.5" || v.Frozen != "2.25" || v.Balance != "3.75" {
+		t.Errorf("usdt balance = %+v", v)
+	}
+}
+
+// 只返回TRADING状态的交易对，精度由过滤器计算
+func TestBinanceSymbols(t *testing.T) {
+	s := newBinanceServer(t)
+	s.route("GET", "/api/v3/exchangeInfo", http.StatusOK, `{"symbols":[
+		{"symbol":"BTCUSDT","status":"TRADING","baseAsset":"BTC","quoteAsset":"USDT","filters":[
+			{"filterType":"PRICE_FILTER","tickSize":"0.01000000"},{"filterType":"LOT_SIZE","stepSize":"0.00001000"}]},
+		{"symbol":"OLDUSDT","status":"BREAK","baseAsset":"OLD","quoteAsset":"USDT","filters":[]}]}`)
+	b := NewBinance(s.URL, "key", "secret", 1000)
+
+	list, err := b.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("%d symbols, want only the trading one", len(list))
+	}
+	if v := list[0]; v.Name != "btcusdt" || v.BaseCurrency != "btc" || v.QuoteCurrency != "usdt" || v.PriceDecimal != 2 || v.AmountDecimal != 5 {
+		t.Errorf("symbol = %+v", v)
+	}
+	if got := b.symbol("oldusdt"); got != "OLDUSDT" {
+		t.Errorf("halted symbol name = %s", got)
+	}
+}
+
+//
+func TestBinancePlaceOrder(t *testing.T) {
+	s := newBinanceServer(t)
+	s.route("POST", "/api/v3/order", http.StatusOK, `{"symbol":"BTCUSDT","orderId":28,"status":"NEW"}`)
+	b := NewBinance(s.URL, "key", "secret", 1000)
+
+	for _, c := range []struct {
+		name string
+		req  *OrderRequest
+		want map[string]string
+		none []string
+	}{
+		{
+			name: "post-only",
+			req:  &OrderRequest{Symbol: "btcusdt", Side: "buy", Type: TypeLimit, Price: "6478.86", Amount: "0.5", PostOnly: true, ClientOrderId: "c1"},
+			want: map[string]string{"symbol": "BTCUSDT", "side": "BUY", "type": "LIMIT_MAKER", "price": "6478.86", "quantity": "0.5", "newClientOrderId": "c1"},
+			none: []string{"timeInForce"},
+		},
+		{
+			name: "market buy",
+			req:  &OrderRequest{Symbol: "btcusdt", Side: "buy", Type: TypeMarket, Amount: "100"},
+			want: map[string]string{"type": "MARKET", "side": "BUY", "quoteOrderQty": "100"},
+			none: []string{"quantity", "price"},
+		},
+		{
+			name: "market sell",
+			req:  &OrderRequest{Symbol: "btcusdt", Side: "sell", Type: TypeMarket, Amount: "0.5"},
+			want: map[string]string{"type": "MARKET", "side": "SELL", "quantity": "0.5"},
+			none: []string{"quoteOrderQty", "price"},
+		},
+		{
+			name: "limit ioc",
+			req:  &OrderRequest{Symbol: "btcusdt", Side: "sell", Type: TypeLimit, Price: "6500", Amount: "0.5", TimeInForce: IOC},
+			want: map[string]string{"type": "LIMIT", "timeInForce": IOC, "price": "6500", "quantity": "0.5"},
+		},
+		{
+			name: "limit",
+			req:  &OrderRequest{Symbol: "btcusdt", Side: "sell", Type: TypeLimit, Price: "6500", Amount: "0.5"},
+			want: map[string]string{"type": "LIMIT", "timeInForce": GTC},
+		},
+	} {
+		id, err := b.PlaceOrder(c.req)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if id != "28" {
+			t.Errorf("%s: order id = %s", c.name, id)
+		}
+		q := s.last().URL.Query()
+		for k, v := range c.want {
+			if q.Get(k) != v {
+				t.Errorf("%s: %s = %q, want %q", c.name, k, q.Get(k), v)
+			}
+		}
+		for _, k := range c.none {
+			if _, ok := q[k]; ok {
+				t.Errorf("%s: unexpected parameter %s", c.name, k)
+			}
+		}
+	}
+}
+
+// 下单被拒绝是确定的错误，超时、未知错误和服务器错误不能确定订单是否已经创建
+func TestBinanceErrors(t *testing.T) {
+	s := newBinanceServer(t)
+	b := NewBinance(s.URL, "key", "secret", 1000)
+	req := &OrderRequest{Symbol: "btcusdt", Side: "buy", Type: TypeLimit, Price: "1", Amount: "1"}
+
+	for _, c := range []struct {
+		status    int
+		body      string
+		ambiguous bool
+	}{
+		{http.StatusBadRequest, `{"code":-2010,"msg":"Account has insufficient balance for requested action."}`, false},
+		{http.StatusBadRequest, `{"code":-1013,"msg":"Filter failure: LOT_SIZE"}`, false},
+		{http.StatusBadRequest, `{"code":-1021,"msg":"Timestamp for this request is outside of the recvWindow."}`, false},
+		{http.StatusTooManyRequests, `{"code":-1003,"msg":"Too many requests."}`, false},
+		{http.StatusBadRequest, `{"code":-1007,"msg":"Timeout waiting for response from backend server. Send status unknown; execution status unknown."}`, true},
+		{http.StatusBadRequest, `{"code":-1006,"msg":"An unexpected response was received from the message bus. Execution status unknown."}`, true},
+		{http.StatusBadRequest, `{"code":-1000,"msg":"An unknown error occurred while processing the request."}`, true},
+		{http.StatusServiceUnavailable, `{"code":-1001,"msg":"Internal error; unable to process your request. Please try again."}`, true},
+		{http.StatusBadGateway, `<html>bad gateway</html>`, true},
+		{http.StatusOK, `{"orderId":`, true},
+	} {
+		s.route("POST", "/api/v3/order", c.status, c.body)
+		_, err := b.PlaceOrder(req)
+		if err == nil {
+			t.Errorf("%d %s: no error", c.status, c.body)
+			continue
+		}
+		if IsAmbiguous(err) != c.ambiguous {
+			t.Errorf("%d %s: ambiguous = %v, want %v", c.status, c.body, IsAmbiguous(err), c.ambiguous)
+		}
+		if strings.Contains(err.Error(), "signature=") {
+			t.Errorf("error contains the signed query: %s", err)
+		}
+	}
+
+	// 连接失败时请求可能已经发出
+	s.Close()
+	_, err := b.PlaceOrder(req)
+	if !IsAmbiguous(err) {
+		t.Errorf("connection error: %v is not ambiguous", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "signature=") {
+		t.Errorf("connection error contains the signed query: %s", err)
+	}
+}
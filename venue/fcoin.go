@@ -0,0 +1,319 @@
+package venue
+
+import (
+	"fcoinExchange/fcoin"
+	"fcoinExchange/model"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	// 每页查询的订单数量
+	orderPageSize = 100
+	openStates    = []string{"submitted", "partial_filled"}
+	allStates     = []string{"submitted", "partial_filled", "partial_canceled", "filled", "canceled"}
+)
+
+// fcoin.Client的适配
+type FCoin struct {
+	client *fcoin.Client
+}
+
+//
+func NewFCoin(client *fcoin.Client) *FCoin {
+	return &FCoin{client: client}
+}
+
+//
+func (p *FCoin) Name() string {
+	return "fcoin"
+}
+
+//
+func (p *FCoin) Client() *fcoin.Client {
+	return p.client
+}
+
+//
+func (p *FCoin) Symbols() ([]*model.Symbol, error) {
+	list, err := p.client.GetSymbols()
+	if err != nil {
+		return nil, err
+	}
+	if list.Status != 0 {
+		return nil, fmt.Errorf("get symbols but return status is %d", list.Status)
+	}
+	return list.Data, nil
+}
+
+//
+func (p *FCoin) Ticker(symbol string) (*model.Quote, error) {
+	ticker, err := p.client.GetTicker(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if ticker.Status != 0 {
+		return nil, fmt.Errorf("get %s ticker but return status is %d", symbol, ticker.Status)
+	}
+	return fcoin.ParseTicker(ticker)
+}
+
+// limit不超过20时查询L20，否则查询L150
+func (p *FCoin) Depth(symbol string, limit int) (*Depth, error) {
+	var level = "L20"
+	if limit > 20 {
+		level = "L150"
+	}
+
+	dp, err := p.client.GetDepth(symbol, level)
+	if err != nil {
+		return nil, err
+	}
+	if dp.Status != 0 || dp.Data == nil {
+		return nil, fmt.Errorf("get %s depth but return status is %d", symbol, dp.Status)
+	}
+
+	var d = &Depth{
+		Symbol: symbol,
+		Bids:   levels(dp.Data.Bids, limit),
+		Asks:   levels(dp.Data.Asks, limit),
+		Time:   time.Unix(0, dp.Data.Ts*int64(time.Millisecond)),
+	}
+	return d, nil
+}
+
+// 价格和数量交替排列的深度转换为档位，limit为0时不限制档位数量
+func levels(flat []float64, limit int) []Level {
+	var list = make([]Level, 0, len(flat)/2)
+	for i := 0; i+1 < len(flat); i += 2 {
+		if limit > 0 && len(list) >= limit {
+			break
+		}
+		list = append(list, Level{Price: flat[i], Amount: flat[i+1]})
+	}
+	return list
+}
+
+//
+func (p *FCoin) Balances() ([]*model.BalanceContext, error) {
+	balance, err := p.client.GetBalance()
+	if err != nil {
+		return nil, err
+	}
+	if balance.Status != 0 {
+		return nil, fmt.Errorf("get balance but return status is %d", balance.Status)
+	}
+	return balance.Data, nil
+}
+
+// fcoin只支持限价单和市价单，post-only、IOC和FOK通过下单前检查行情和下单后立即撤单模拟，
+// 检查和下单之间行情可能变化，post-only仍可能成交，FOK仍可能部分成交。
+// 不支持客户端订单号，下单请求发出后的任何错误都可能是订单已经创建，返回AmbiguousError
+func (p *FCoin) PlaceOrder(req *OrderRequest) (string, error) {
+	err := req.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	if req.PostOnly {
+		err = p.checkPostOnly(req)
+		if err != nil {
+			return "", err
+		}
+	}
+	if req.TimeInForce == FOK {
+		err = p.checkFillable(req)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	order, err := p.client.CreateOrder(req.Symbol, req.Side, req.Type, req.Price, req.Amount)
+	if err != nil {
+		return "", &AmbiguousError{Err: err}
+	}
+	if order.Status != 0 {
+		return "", fmt.Errorf("create %s order but return status is %d", req.Symbol, order.Status)
+	}
+
+	// 市价单会立即成交，不需要撤单
+	if req.Type == TypeLimit && (req.TimeInForce == IOC || req.TimeInForce == FOK) {
+		// 订单可能已经全部成交，撤单失败时以订单查询结果为准
+		p.CancelOrder(req.Symbol, order.Data)
+	}
+	return order.Data, nil
+}
+
+// 买单价格不低于卖一或者卖单价格不高于买一时会立即成交
+func (p *FCoin) checkPostOnly(req *OrderRequest) error {
+	price, _ := strconv.ParseFloat(req.Price, 64)
+	q, err := p.Ticker(req.Symbol)
+	if err != nil {
+		return err
+	}
+	if req.Side == "buy" && q.MinSellOnePrice > 0 && price >= q.MinSellOnePrice {
+		return ErrWouldTakeLiquidity
+	}
+	if req.Side == "sell" && q.MaxBuyOnePrice > 0 && price <= q.MaxBuyOnePrice {
+		return ErrWouldTakeLiquidity
+	}
+	return nil
+}
+
+// 对手盘在限价以内的数量不少于订单数量时可以全部成交
+func (p *FCoin) checkFillable(req *OrderRequest) error {
+	price, _ := strconv.ParseFloat(req.Price, 64)
+	amount, _ := strconv.ParseFloat(req.Amount, 64)
+	d, err := p.Depth(req.Symbol, 150)
+	if err != nil {
+		return err
+	}
+
+	var (
+		levels    = d.Asks
+		available float64
+	)
+	if req.Side == "sell" {
+		levels = d.Bids
+	}
+	for _, v := range levels {
+		if (req.Side == "buy" && v.Price > price) || (req.Side == "sell" && v.Price < price) {
+			break
+		}
+		available += v.Amount
+		if available >= amount {
+			return nil
+		}
+	}
+	return ErrNotFillable
+}
+
+//
+func (p *FCoin) CancelOrder(symbol, id string) error {
+	corder, err := p.client.CancelOrder(id)
+	if err != nil {
+		return err
+	}
+	if corder.Status != 0 {
+		return fmt.Errorf("cancel order %s but return status is %d, %s", id, corder.Status, corder.Msg)
+	}
+	return nil
+}
+
+//
+func (p *FCoin) Order(symbol, id string) (*model.OrderInfo, error) {
+	order, err := p.client.GetOrder(id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != 0 || order.Data == nil {
+		return nil, fmt.Errorf("get order %s but return status is %d", id, order.Status)
+	}
+	return order.Data, nil
+}
+
+//
+func (p *FCoin) OpenOrders(symbol string) ([]*model.OrderInfo, error) {
+	var list = make([]*model.OrderInfo, 0)
+	for _, state := range openStates {
+		orders, err := p.listOrders(symbol, state, 0)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, orders...)
+	}
+	return list, nil
+}
+
+// 按状态分别查询，订单按创建时间倒序返回，翻页到早于since的订单为止
+func (p *FCoin) RecentOrders(symbol string, since time.Time) ([]*model.OrderInfo, error) {
+	var (
+		list  = make([]*model.OrderInfo, 0)
+		after = since.UnixNano() / int64(time.Millisecond)
+	)
+	for _, state := range allStates {
+		orders, err := p.listOrders(symbol, state, after)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, orders...)
+	}
+	return list, nil
+}
+
+// 分页查询交易对所有指定状态的订单，since大于0时只返回此毫秒时间戳之后创建的订单。
+// 订单按创建时间倒序返回，下一页从本页最早的订单的创建时间开始（包含该毫秒），
+// 避免同一毫秒创建的订单跨页时被跳过，重复返回的订单按订单号去重
+func (p *FCoin) listOrders(symbol, state string, since int64) ([]*model.OrderInfo, error) {
+	var (
+		list   = make([]*model.OrderInfo, 0)
+		querys = map[string]string{
+			"symbol": symbol,
+			"states": state,
+			"limit":  strconv.Itoa(orderPageSize),
+		}
+		seen   = make(map[string]bool)
+		before int64
+	)
+
+	for {
+		orders, err := p.client.ListOrders(querys)
+		if err != nil {
+			return nil, err
+		}
+		if orders.Status != 0 {
+			return nil, fmt.Errorf("get orderlist but return status is %d", orders.Status)
+		}
+
+		var (
+			added int
+			older bool
+		)
+		for _, v := range orders.Data {
+			if since > 0 && v.CreatedAt < since {
+				older = true
+				continue
+			}
+			if seen[v.Id] {
+				continue
+			}
+			seen[v.Id] = true
+			list = append(list, v)
+			added++
+		}
+
+		if len(orders.Data) < orderPageSize || older {
+			return list, nil
+		}
+
+		// before不包含该时间戳，加1后包含与本页最早的订单同一毫秒创建的订单。
+		// 整页都已经返回过时说明该毫秒的订单超过一页，只能跳过该毫秒继续，
+		// cursor每页至少减小1毫秒，保证翻页结束
+		oldest := orders.Data[len(orders.Data)-1].CreatedAt
+		cursor := oldest + 1
+		if added == 0 {
+			cursor = oldest
+		}
+		if before > 0 && cursor >= before {
+			cursor = before - 1
+		}
+		before = cursor
+		querys["before"] = strconv.FormatInt(before, 10)
+	}
+}
+
+// 不支持客户端订单号
+func (p *FCoin) DedupClientOrderId() bool {
+	return false
+}
+
+//
+func (p *FCoin) Clock() Clock {
+	return p.client.Clock()
+}
+
+//
+func (p *FCoin) SyncClock(samples int) error {
+	return p.client.Clock().Sync(p.client, samples)
+}
@@ -0,0 +1,112 @@
+package venue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fcoinExchange/fcoin"
+	"fcoinExchange/model"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+//
+func jsonResponse(status int, v interface{}) *http.Response {
+	data, _ := json.Marshal(v)
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(data)),
+	}
+}
+
+// 按创建时间倒序分页返回订单的fcoin订单列表接口，inclusive为true时before包含该时间戳
+func orderListServer(orders []*model.OrderInfo, inclusive bool) *fcoin.Client {
+	sort.SliceStable(orders, func(i, j int) bool { return orders[i].CreatedAt > orders[j].CreatedAt })
+
+	c := fcoin.NewClient("key", "secret", 1000)
+	c.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		q := req.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		before, _ := strconv.ParseInt(q.Get("before"), 10, 64)
+
+		var page = make([]*model.OrderInfo, 0)
+		for _, v := range orders {
+			if v.State != q.Get("states") {
+				continue
+			}
+			if before > 0 && (v.CreatedAt > before || (!inclusive && v.CreatedAt == before)) {
+				continue
+			}
+			if len(page) == limit {
+				break
+			}
+			page = append(page, v)
+		}
+		return jsonResponse(http.StatusOK, &model.OrderList{Data: page}), nil
+	}))
+	return c
+}
+
+// 同一毫秒创建的订单跨页时不遗漏也不重复
+func TestFCoinOpenOrdersPageBoundary(t *testing.T) {
+	var orders []*model.OrderInfo
+	for i := 0; i < 250; i++ {
+		orders = append(orders, &model.OrderInfo{
+			Id:        strconv.Itoa(i),
+			Symbol:    "btcusdt",
+			State:     "submitted",
+			CreatedAt: 1000000 - int64(i/3),
+		})
+	}
+	orders = append(orders, &model.OrderInfo{Id: "p", Symbol: "btcusdt", State: "partial_filled", CreatedAt: 999990})
+
+	for _, inclusive := range []bool{false, true} {
+		v := NewFCoin(orderListServer(append([]*model.OrderInfo(nil), orders...), inclusive))
+		list, err := v.OpenOrders("btcusdt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seen := make(map[string]bool)
+		for _, o := range list {
+			if seen[o.Id] {
+				t.Errorf("inclusive=%v: order %s returned twice", inclusive, o.Id)
+			}
+			seen[o.Id] = true
+		}
+		if len(seen) != len(orders) {
+			t.Errorf("inclusive=%v: got %d orders, want %d", inclusive, len(seen), len(orders))
+		}
+	}
+}
+
+// 同一毫秒的订单超过一页时跳过该毫秒，不会一直请求同一页
+func TestFCoinOpenOrdersSameMillisecond(t *testing.T) {
+	var orders []*model.OrderInfo
+	for i := 0; i < orderPageSize+20; i++ {
+		orders = append(orders, &model.OrderInfo{Id: strconv.Itoa(i), State: "submitted", CreatedAt: 5000})
+	}
+	orders = append(orders, &model.OrderInfo{Id: "old", State: "submitted", CreatedAt: 4000})
+
+	v := NewFCoin(orderListServer(orders, true))
+	list, err := v.OpenOrders("btcusdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, o := range list {
+		found = found || o.Id == "old"
+	}
+	if !found || len(list) < orderPageSize+1 {
+		t.Errorf("got %d orders, old order found %v", len(list), found)
+	}
+}
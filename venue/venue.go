@@ -0,0 +1,190 @@
+package venue
+
+import (
+	"errors"
+	"fcoinExchange/model"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// 订单类型
+const (
+	TypeLimit  string = "limit"
+	TypeMarket string = "market"
+)
+
+// 订单有效时间
+const (
+	// 一直有效直到成交或者撤销
+	GTC string = "GTC"
+	// 立即成交，未成交的部分撤销
+	IOC string = "IOC"
+	// 全部立即成交，否则撤销
+	FOK string = "FOK"
+)
+
+var (
+	ErrWouldTakeLiquidity = errors.New("post-only order would take liquidity")
+	ErrNotFillable        = errors.New("fok order can not be filled entirely")
+)
+
+// 结果不确定的错误，例如请求超时、连接中断或者服务器内部错误，请求可能已经被交易所处理
+type AmbiguousError struct {
+	Err error
+}
+
+//
+func (p *AmbiguousError) Error() string {
+	return p.Err.Error()
+}
+
+// 下单返回此错误时订单可能已经存在，需要查询最近的订单确认，不能直接重新下单
+func IsAmbiguous(err error) bool {
+	_, ok := err.(*AmbiguousError)
+	return ok
+}
+
+// 深度中的一档
+type Level struct {
+	Price  float64
+	Amount float64
+}
+
+// 交易对的深度，Bids按价格从高到低，Asks按价格从低到高
+type Depth struct {
+	Symbol string
+	Bids   []Level
+	Asks   []Level
+	Time   time.Time
+}
+
+// 下单请求，价格和数量已经按交易对的精度格式化。
+// 市价单没有价格，市价买单的Amount为花费的quote currency数量，其他订单为base currency数量
+type OrderRequest struct {
+	Symbol string
+	Side   string
+	Type   string
+	Price  string
+	Amount string
+	// 为空时为GTC，市价单只能为空或者IOC
+	TimeInForce string
+	// 只做maker，会立即成交时拒绝
+	PostOnly bool
+	// 客户端订单号，交易所支持时随订单提交
+	ClientOrderId string
+}
+
+//
+func (p *OrderRequest) Validate() error {
+	if p.Symbol == "" {
+		return fmt.Errorf("symbol is empty")
+	}
+	if p.Side != "buy" && p.Side != "sell" {
+		return fmt.Errorf("side need be buy or sell")
+	}
+
+	amount, err := strconv.ParseFloat(p.Amount, 64)
+	if err != nil || amount <= 0 {
+		return fmt.Errorf("amount %q need be a number greater than 0", p.Amount)
+	}
+
+	switch p.TimeInForce {
+	case "", GTC, IOC, FOK:
+	default:
+		return fmt.Errorf("time in force need be GTC, IOC or FOK")
+	}
+
+	switch p.Type {
+	case TypeLimit:
+		price, err := strconv.ParseFloat(p.Price, 64)
+		if err != nil || price <= 0 {
+			return fmt.Errorf("limit order price %q need be a number greater than 0", p.Price)
+		}
+		if p.PostOnly && p.TimeInForce != "" && p.TimeInForce != GTC {
+			return fmt.Errorf("post-only order can not be %s", p.TimeInForce)
+		}
+	case TypeMarket:
+		if p.Price != "" {
+			return fmt.Errorf("market order can not have price")
+		}
+		if p.PostOnly {
+			return fmt.Errorf("market order can not be post-only")
+		}
+		if p.TimeInForce != "" && p.TimeInForce != IOC {
+			return fmt.Errorf("market order can not be %s", p.TimeInForce)
+		}
+	default:
+		return fmt.Errorf("type need be limit or market")
+	}
+	return nil
+}
+
+// 订单提交后是否会留在订单簿上
+func (p *OrderRequest) Resting() bool {
+	return p.Type == TypeLimit && (p.TimeInForce == "" || p.TimeInForce == GTC)
+}
+
+// 交易所的时钟，用于计算订单的存在时间
+type Clock interface {
+	// 毫秒时间戳
+	Millis() int64
+	// 服务器时间与本地时间的差，未同步过时为0
+	Offset() time.Duration
+	// 最后一次同步时的请求往返时间
+	RTT() time.Duration
+	// 是否已经与服务器同步过，以及最后一次同步的时间
+	SyncedAt() (time.Time, bool)
+}
+
+// 交易所接口。
+// 交易逻辑只通过这个接口访问交易所，交易对使用fcoin的格式，例如btcusdt，
+// 订单状态使用fcoin的状态：submitted、partial_filled、partial_canceled、filled、canceled
+type Venue interface {
+	Name() string
+
+	// 交易对的base currency、quote currency和精度
+	Symbols() ([]*model.Symbol, error)
+	Ticker(symbol string) (*model.Quote, error)
+	Depth(symbol string, limit int) (*Depth, error)
+
+	Balances() ([]*model.BalanceContext, error)
+
+	// 返回订单号
+	PlaceOrder(req *OrderRequest) (string, error)
+	CancelOrder(symbol, id string) error
+	Order(symbol, id string) (*model.OrderInfo, error)
+	// 所有未完成的订单
+	OpenOrders(symbol string) ([]*model.OrderInfo, error)
+	// since之后创建的订单，包括已经结束的订单，用于确认结果不确定的下单请求
+	RecentOrders(symbol string, since time.Time) ([]*model.OrderInfo, error)
+	// 交易所是否拒绝重复的客户端订单号，为true时结果不确定的下单可以使用同一个客户端订单号重试
+	DedupClientOrderId() bool
+
+	Clock() Clock
+	// 采样samples次服务器时间并校正Clock，多个账户共用同一个Clock时只需同步一次
+	SyncClock(samples int) error
+}
+
+// 本地时钟，用于不需要与服务器同步时间的交易所
+type LocalClock struct{}
+
+//
+func (p LocalClock) Millis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+//
+func (p LocalClock) Offset() time.Duration {
+	return 0
+}
+
+//
+func (p LocalClock) RTT() time.Duration {
+	return 0
+}
+
+//
+func (p LocalClock) SyncedAt() (time.Time, bool) {
+	return time.Time{}, false
+}